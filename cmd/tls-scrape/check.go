@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Exit codes of the --check mode.
+const (
+	checkOK     = 0
+	checkFailed = 1
+	checkError  = 2
+)
+
+// evaluateCheck decides the outcome of a --check run: the certificate must be
+// valid and must not expire within the given window.
+func evaluateCheck(detail *scraper.CertDetails, err error, within time.Duration, now time.Time) (int, string) {
+	if err != nil {
+		return checkError, fmt.Sprintf("failed to scrape: %v", err)
+	}
+	if !detail.Valid {
+		var codes []string
+		for _, e := range detail.ValidationErrors {
+			if !e.Warning {
+				codes = append(codes, e.Code)
+			}
+		}
+		return checkFailed, fmt.Sprintf("certificate is invalid: %s", strings.Join(codes, ","))
+	}
+	if len(detail.CertChain) > 0 {
+		notAfter := detail.GetLeafCert().NotAfter
+		if notAfter.Before(now.Add(within)) {
+			return checkFailed, fmt.Sprintf("certificate expires within %s at %s", within, notAfter)
+		}
+	}
+	return checkOK, "certificate is valid"
+}
+
+// Exit codes of a scan with fail-on-invalid, fail-expiring-within or
+// fail-on-error set. They follow those of --check so that a script can tell
+// the modes apart.
+const (
+	failInvalid  = 3
+	failExpiring = 4
+	failError    = 5
+)
+
+// failConditions are the conditions under which a scan exits with a non-zero
+// code, for use as a CI gate.
+type failConditions struct {
+	invalid        bool
+	expiringWithin int
+	errors         bool
+}
+
+// set reports whether any condition is set.
+func (c failConditions) set() bool {
+	return c.invalid || c.expiringWithin > 0 || c.errors
+}
+
+// evaluateScan maps the results of a scan to its exit code: failInvalid if
+// any certificate is invalid, otherwise failExpiring if any expires within
+// the window, including those already expired, otherwise failError if any
+// target couldn't be scraped, and checkOK when none violates the conditions
+// set.
+func evaluateScan(details []*scraper.CertDetails, errs map[string]error, conditions failConditions) (int, string) {
+	var invalid, expiring []string
+	for _, detail := range details {
+		if conditions.invalid && !detail.Valid {
+			invalid = append(invalid, detail.Domain)
+		}
+		if conditions.expiringWithin > 0 && detail.DaysUntilExpiry <= conditions.expiringWithin {
+			expiring = append(expiring, detail.Domain)
+		}
+	}
+	if len(invalid) > 0 {
+		return failInvalid, fmt.Sprintf("invalid certificates: %s", strings.Join(invalid, ","))
+	}
+	if len(expiring) > 0 {
+		return failExpiring, fmt.Sprintf("certificates expiring within %d days: %s", conditions.expiringWithin, strings.Join(expiring, ","))
+	}
+	if conditions.errors && len(errs) > 0 {
+		failed := make([]string, 0, len(errs))
+		for domain := range errs {
+			failed = append(failed, domain)
+		}
+		sort.Strings(failed)
+		return failError, fmt.Sprintf("targets that couldn't be scraped: %s", strings.Join(failed, ","))
+	}
+	return checkOK, "no certificates are invalid or expiring"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"testing"
+	"time"
+)
+
+func TestEvaluateCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	detailExpiring := func(notAfter time.Time, valid bool, errs ...scraper.ValidationError) *scraper.CertDetails {
+		return &scraper.CertDetails{
+			Domain:           "example.com",
+			Valid:            valid,
+			ValidationErrors: errs,
+			CertChain:        []*x509.Certificate{{NotAfter: notAfter}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		detail   *scraper.CertDetails
+		err      error
+		expected int
+	}{
+		{"scrape error", nil, errors.New("connection refused"), checkError},
+		{"invalid", detailExpiring(now.Add(365*24*time.Hour), false, scraper.ValidationError{Code: scraper.CodeHostnameMismatch}), nil, checkFailed},
+		{"expiring", detailExpiring(now.Add(24*time.Hour), true), nil, checkFailed},
+		{"valid with warning", detailExpiring(now.Add(365*24*time.Hour), true, scraper.ValidationError{Code: scraper.CodeRedundantSAN, Warning: true}), nil, checkOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason := evaluateCheck(tt.detail, tt.err, 30*24*time.Hour, now)
+			if code != tt.expected {
+				t.Errorf("expected exit code %d, got %d (%s)", tt.expected, code, reason)
+			}
+		})
+	}
+}
+
+func TestEvaluateScan(t *testing.T) {
+	valid := &scraper.CertDetails{Domain: "valid.example.com", Valid: true, DaysUntilExpiry: 90}
+	expiring := &scraper.CertDetails{Domain: "expiring.example.com", Valid: true, DaysUntilExpiry: 10}
+	invalid := &scraper.CertDetails{Domain: "invalid.example.com", DaysUntilExpiry: 90}
+	expired := &scraper.CertDetails{Domain: "expired.example.com", DaysUntilExpiry: -1, Expired: true}
+	unreachable := map[string]error{"gone.example.com": errors.New("connection refused")}
+
+	tests := []struct {
+		name       string
+		details    []*scraper.CertDetails
+		errs       map[string]error
+		conditions failConditions
+		expected   int
+	}{
+		{"no conditions", []*scraper.CertDetails{invalid, expiring}, unreachable, failConditions{}, checkOK},
+		{"no results", nil, unreachable, failConditions{invalid: true, expiringWithin: 30}, checkOK},
+		{"all valid", []*scraper.CertDetails{valid, expiring}, unreachable, failConditions{invalid: true}, checkOK},
+		{"invalid", []*scraper.CertDetails{valid, invalid}, unreachable, failConditions{invalid: true}, failInvalid},
+		{"invalid only expiring checked", []*scraper.CertDetails{valid, invalid}, unreachable, failConditions{expiringWithin: 30}, checkOK},
+		{"expiring", []*scraper.CertDetails{valid, expiring}, unreachable, failConditions{expiringWithin: 30}, failExpiring},
+		{"expiring outside window", []*scraper.CertDetails{valid, expiring}, unreachable, failConditions{expiringWithin: 7}, checkOK},
+		{"expired", []*scraper.CertDetails{expired}, unreachable, failConditions{expiringWithin: 7}, failExpiring},
+		{"invalid before expiring", []*scraper.CertDetails{expiring, invalid}, unreachable, failConditions{invalid: true, expiringWithin: 30}, failInvalid},
+		{"error", []*scraper.CertDetails{valid}, unreachable, failConditions{errors: true}, failError},
+		{"no errors", []*scraper.CertDetails{valid}, nil, failConditions{errors: true}, checkOK},
+		{"invalid before error", []*scraper.CertDetails{invalid}, unreachable, failConditions{invalid: true, errors: true}, failInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason := evaluateScan(tt.details, tt.errs, tt.conditions)
+			if code != tt.expected {
+				t.Errorf("expected exit code %d, got %d (%s)", tt.expected, code, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+	"os"
+)
+
+// scanConfig is the effective configuration of a scan, written alongside its
+// results so that anyone reading them later knows how they were produced.
+// Only settings that can change the results are included. Nothing that may
+// hold a secret is: the SSLKEYLOGFILE path is reduced to whether keys were
+// logged, a proxy URL to its scheme and address, and cloud credentials, which
+// only come from the environment, are left out.
+type scanConfig struct {
+	Concurrency        int      `json:"concurrency"`
+	ChunkSize          int      `json:"chunk_size"`
+	RampUp             string   `json:"ramp_up"`
+	Rate               float64  `json:"rate,omitempty"`
+	Port               int      `json:"port"`
+	AllowedPorts       string   `json:"allowed_ports,omitempty"`
+	DeniedPorts        string   `json:"denied_ports,omitempty"`
+	Timeout            string   `json:"timeout"`
+	AdaptiveTimeout    bool     `json:"adaptive_timeout"`
+	AdaptiveTimeoutMin string   `json:"adaptive_timeout_min,omitempty"`
+	AdaptiveTimeoutMax string   `json:"adaptive_timeout_max,omitempty"`
+	Timings            bool     `json:"timings"`
+	PreResolve         bool     `json:"pre_resolve"`
+	Resolve            []string `json:"resolve,omitempty"`
+	WithWWW            bool     `json:"with_www"`
+	Subnets            []string `json:"subnets,omitempty"`
+	MaxSubnetSize      int      `json:"max_subnet_size,omitempty"`
+	Sample             int      `json:"sample,omitempty"`
+	AWSELBRegion       string   `json:"aws_elbs,omitempty"`
+	Pins               []string `json:"pins,omitempty"`
+	PinsFile           string   `json:"pins_file,omitempty"`
+	DistrustFile       string   `json:"distrust_file,omitempty"`
+	CABundle           string   `json:"ca_bundle,omitempty"`
+	CABundleOnly       bool     `json:"ca_bundle_only,omitempty"`
+	Proxy              string   `json:"proxy,omitempty"`
+	ProxyProtocol      int      `json:"proxy_protocol,omitempty"`
+	StartTLS           string   `json:"starttls,omitempty"`
+	SNI                string   `json:"sni,omitempty"`
+	StrictSNI          bool     `json:"strict_sni"`
+	TLSFallback        bool     `json:"tls_fallback"`
+	MaxChainDepth      int      `json:"max_chain_depth"`
+	ConsistencyCheck   int      `json:"consistency_check,omitempty"`
+	OCSP               bool     `json:"ocsp"`
+	OCSPNonce          bool     `json:"ocsp_nonce"`
+	CRL                bool     `json:"crl"`
+	AIA                bool     `json:"aia"`
+	CircuitBreaker     int      `json:"circuit_breaker,omitempty"`
+	CircuitCooldown    string   `json:"circuit_breaker_cooldown,omitempty"`
+	CircuitBreakerV4   int      `json:"circuit_breaker_v4_prefix,omitempty"`
+	CircuitBreakerV6   int      `json:"circuit_breaker_v6_prefix,omitempty"`
+	RevocationNotes    bool     `json:"revocation_notes"`
+	DTLS               bool     `json:"dtls"`
+	CacheTTL           string   `json:"cache_ttl"`
+	Deterministic      bool     `json:"deterministic"`
+	MinimalOutput      bool     `json:"minimal_output"`
+	Stream             bool     `json:"stream"`
+	KeyLog             bool     `json:"key_log"`
+}
+
+// newScanConfig returns the effective scan configuration from the flags and
+// environment.
+func newScanConfig() scanConfig {
+	config := scanConfig{
+		Concurrency:      viper.GetInt("concurrency"),
+		ChunkSize:        effectiveChunkSize(viper.GetInt("chunk-size"), viper.GetInt("concurrency")),
+		RampUp:           viper.GetDuration("ramp-up").String(),
+		Rate:             viper.GetFloat64("rate"),
+		Port:             viper.GetInt("port"),
+		AllowedPorts:     viper.GetString("allowed-ports"),
+		DeniedPorts:      viper.GetString("denied-ports"),
+		Timeout:          viper.GetDuration("timeout").String(),
+		AdaptiveTimeout:  viper.GetBool("adaptive-timeout"),
+		Timings:          viper.GetBool("timings"),
+		PreResolve:       viper.GetBool("pre-resolve"),
+		Resolve:          viper.GetStringSlice("resolve"),
+		WithWWW:          viper.GetBool("with-www"),
+		Subnets:          viper.GetStringSlice("subnet"),
+		MaxSubnetSize:    viper.GetInt("max-subnet-size"),
+		Sample:           viper.GetInt("sample"),
+		AWSELBRegion:     viper.GetString("aws-elbs"),
+		Pins:             viper.GetStringSlice("pin"),
+		PinsFile:         viper.GetString("pins-file"),
+		DistrustFile:     viper.GetString("distrust-file"),
+		CABundle:         viper.GetString("ca-bundle"),
+		CABundleOnly:     viper.GetBool("ca-bundle-only"),
+		ProxyProtocol:    viper.GetInt("proxy-protocol"),
+		StartTLS:         viper.GetString("starttls"),
+		SNI:              viper.GetString("sni"),
+		StrictSNI:        viper.GetBool("strict-sni"),
+		TLSFallback:      viper.GetBool("tls-fallback"),
+		MaxChainDepth:    viper.GetInt("max-chain-depth"),
+		ConsistencyCheck: viper.GetInt("consistency-check"),
+		OCSP:             viper.GetBool("ocsp"),
+		OCSPNonce:        viper.GetBool("ocsp-nonce"),
+		CRL:              viper.GetBool("crl"),
+		AIA:              viper.GetBool("aia"),
+		CircuitBreaker:   viper.GetInt("circuit-breaker"),
+		RevocationNotes:  viper.GetBool("revocation-notes"),
+		DTLS:             viper.GetBool("dtls"),
+		CacheTTL:         viper.GetDuration("cache-ttl").String(),
+		Deterministic:    viper.GetBool("deterministic"),
+		MinimalOutput:    viper.GetBool("minimal-output"),
+		Stream:           viper.GetBool("stream"),
+		KeyLog:           os.Getenv("SSLKEYLOGFILE") != "",
+	}
+	// Only the scheme and address of a proxy are recorded, never its
+	// credentials.
+	if proxyURL, err := effectiveProxy(); err == nil && proxyURL != nil {
+		config.Proxy = proxyURL.Scheme + "://" + proxyURL.Host
+	}
+	if config.AdaptiveTimeout {
+		config.AdaptiveTimeoutMin = viper.GetDuration("adaptive-timeout-min").String()
+		config.AdaptiveTimeoutMax = viper.GetDuration("adaptive-timeout-max").String()
+	}
+	if config.CircuitBreaker > 0 {
+		config.CircuitCooldown = viper.GetDuration("circuit-breaker-cooldown").String()
+		config.CircuitBreakerV4 = viper.GetInt("circuit-breaker-v4-prefix")
+		config.CircuitBreakerV6 = viper.GetInt("circuit-breaker-v6-prefix")
+	}
+	return config
+}
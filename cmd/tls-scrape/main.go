@@ -1,19 +1,40 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"github.com/prometheus/common/model"
 	"github.com/scotta01/tls-scrape/internal/helper"
+	"github.com/scotta01/tls-scrape/pkg/inventory"
+	"github.com/scotta01/tls-scrape/pkg/proto"
 	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"github.com/scotta01/tls-scrape/pkg/server"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"io"
 	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// bindEnvWithFallback sets key from the environment, accepting either the
+// upper or lower case form. Dashes in the key map to underscores, so the
+// "allowed-ports" key is read from ALLOWED_PORTS.
 func bindEnvWithFallback(key string) {
-	if value, exists := os.LookupEnv(strings.ToUpper(key)); exists {
+	envKey := strings.ReplaceAll(key, "-", "_")
+	if value, exists := os.LookupEnv(strings.ToUpper(envKey)); exists {
 		viper.Set(key, value)
-	} else if value, exists := os.LookupEnv(strings.ToLower(key)); exists {
+	} else if value, exists := os.LookupEnv(strings.ToLower(envKey)); exists {
 		viper.Set(key, value)
 	}
 }
@@ -21,17 +42,170 @@ func bindEnvWithFallback(key string) {
 func init() {
 	bindEnvWithFallback("fqdn")
 	bindEnvWithFallback("filepath")
+	bindEnvWithFallback("hostsfile")
+	bindEnvWithFallback("jsonlfile")
+	bindEnvWithFallback("json-input")
 	bindEnvWithFallback("header")
 	bindEnvWithFallback("outdir")
 	bindEnvWithFallback("concurrency")
+	bindEnvWithFallback("chunk-size")
 	bindEnvWithFallback("prettyjson")
+	bindEnvWithFallback("minimal-output")
+	bindEnvWithFallback("san-inventory")
+	bindEnvWithFallback("timeout")
+	bindEnvWithFallback("adaptive-timeout")
+	bindEnvWithFallback("adaptive-timeout-min")
+	bindEnvWithFallback("adaptive-timeout-max")
+	bindEnvWithFallback("timings")
+	bindEnvWithFallback("port")
+	bindEnvWithFallback("allowed-ports")
+	bindEnvWithFallback("denied-ports")
+	bindEnvWithFallback("watch")
+	bindEnvWithFallback("metrics-addr")
+	bindEnvWithFallback("expiry-warning")
+	bindEnvWithFallback("pin")
+	bindEnvWithFallback("pins-file")
+	bindEnvWithFallback("distrust-file")
+	bindEnvWithFallback("ca-bundle")
+	bindEnvWithFallback("ca-bundle-only")
+	bindEnvWithFallback("pre-resolve")
+	bindEnvWithFallback("format")
+	bindEnvWithFallback("shutdown-grace")
+	bindEnvWithFallback("proxy")
+	bindEnvWithFallback("proxy-protocol")
+	bindEnvWithFallback("proxy-protocol-src")
+	bindEnvWithFallback("proxy-protocol-dst")
+	bindEnvWithFallback("starttls")
+	bindEnvWithFallback("check")
+	bindEnvWithFallback("dump-connstate")
+	bindEnvWithFallback("verbose")
+	bindEnvWithFallback("cache-ttl")
+	bindEnvWithFallback("cache-size")
+	bindEnvWithFallback("resolve")
+	bindEnvWithFallback("subnet")
+	bindEnvWithFallback("max-subnet-size")
+	bindEnvWithFallback("sample")
+	bindEnvWithFallback("aws-elbs")
+	bindEnvWithFallback("socket")
+	bindEnvWithFallback("serve")
+	bindEnvWithFallback("exemplars")
+	bindEnvWithFallback("metric-labels")
+	bindEnvWithFallback("domain-metrics")
+	bindEnvWithFallback("strict-sni")
+	bindEnvWithFallback("sni")
+	bindEnvWithFallback("tls-fallback")
+	bindEnvWithFallback("max-chain-depth")
+	bindEnvWithFallback("consistency-check")
+	bindEnvWithFallback("ocsp")
+	bindEnvWithFallback("ocsp-nonce")
+	bindEnvWithFallback("crl")
+	bindEnvWithFallback("aia")
+	bindEnvWithFallback("circuit-breaker")
+	bindEnvWithFallback("circuit-breaker-cooldown")
+	bindEnvWithFallback("circuit-breaker-v4-prefix")
+	bindEnvWithFallback("circuit-breaker-v6-prefix")
+	bindEnvWithFallback("meta-columns")
+	bindEnvWithFallback("dtls")
+	bindEnvWithFallback("ramp-up")
+	bindEnvWithFallback("rate")
+	bindEnvWithFallback("with-www")
+	bindEnvWithFallback("deterministic")
+	bindEnvWithFallback("flatten-bundle")
+	bindEnvWithFallback("csv")
+	bindEnvWithFallback("split-by")
+	bindEnvWithFallback("summary-only")
+	bindEnvWithFallback("only-invalid")
+	bindEnvWithFallback("expiring-within")
+	bindEnvWithFallback("fail-on-invalid")
+	bindEnvWithFallback("fail-expiring-within")
+	bindEnvWithFallback("fail-on-error")
+	bindEnvWithFallback("probe-only")
+	bindEnvWithFallback("stream")
+	bindEnvWithFallback("revocation-notes")
 
 	pflag.String("fqdn", "", "Fully Qualified Domain Name")
 	pflag.String("filepath", "", "Path to the websites CSV file")
+	pflag.String("hostsfile", "", "Path to a text file of websites, one host or host:port per line")
+	pflag.String("jsonlfile", "", "Path to a JSON Lines file of targets, one object with a host and optional overrides per line")
+	pflag.String("json-input", "", "Path to a JSON array of host names, or of objects with a host field")
+	pflag.StringSlice("subnet", nil, "CIDR or start-end range of IP addresses to scan, repeatable")
+	pflag.Int("max-subnet-size", helper.MaxRangeSize, "Largest number of addresses a subnet may expand to")
+	pflag.Int("sample", 0, "Scan this many randomly picked addresses from each larger subnet instead of all of them")
+	pflag.String("aws-elbs", "", "Scan the DNS names of the AWS load balancers in this region")
 	pflag.String("header", "url", "Column header to look for in the CSV")
 	pflag.String("outdir", "", "Output path for JSON file")
 	pflag.Int("concurrency", 10, "Maximum number of concurrent TLS connections")
+	pflag.Int("chunk-size", 0, "Number of targets scanned before their results are written, defaults to concurrency")
+	pflag.Duration("ramp-up", 0, "Raise concurrency gradually from one to its maximum over this period")
+	pflag.Float64("rate", 0, "Maximum number of new connections a second, however high the concurrency, 0 for unlimited")
 	pflag.Bool("prettyjson", false, "Pretty print JSON output")
+	pflag.Bool("minimal-output", false, "Leave empty and zero-value fields out of the JSON output")
+	pflag.Bool("deterministic", false, "Sort results and their validation errors so repeated scans give identical output")
+	pflag.Bool("flatten-bundle", false, "Also write every domain and IP result to a single bundle.json in outdir")
+	pflag.Bool("csv", false, "Write the results to results.csv in outdir instead of one JSON file per domain")
+	pflag.String("split-by", "", "Also write the results to one bundle per group in outdir, grouped by: issuer")
+	pflag.String("summary-only", "", "Write only the scan summary, as text or json, instead of per-certificate output")
+	pflag.Lookup("summary-only").NoOptDefVal = "text"
+	pflag.Bool("only-invalid", false, "Only output certificates that failed validation")
+	pflag.Int("expiring-within", 0, "Only output certificates that expire within this many days, or have expired")
+	pflag.Bool("fail-on-invalid", false, "Exit with code 3 after writing the output if any certificate is invalid")
+	pflag.Int("fail-expiring-within", 0, "Exit with code 4 after writing the output if any certificate expires within this many days")
+	pflag.Bool("fail-on-error", false, "Exit with code 5 after writing the output if any target couldn't be scraped")
+	pflag.Bool("probe-only", false, "Only report whether each target completes a TLS handshake, without parsing certificates")
+	pflag.Bool("stream", false, "Write each result to stdout as a line of JSON as soon as it is scraped")
+	pflag.Bool("san-inventory", false, "Collect a deduplicated inventory of DNS SANs across all scraped certificates")
+	pflag.Duration("timeout", scraper.DefaultTimeout, "Timeout for each dial, including the TLS handshake, 0 for no timeout")
+	pflag.Bool("adaptive-timeout", false, "Derive dial timeouts from observed handshake latencies")
+	pflag.Duration("adaptive-timeout-min", time.Second, "Lower bound for the adaptive dial timeout")
+	pflag.Duration("adaptive-timeout-max", 30*time.Second, "Upper bound for the adaptive dial timeout")
+	pflag.Bool("timings", false, "Record DNS, connect and handshake timings for each domain")
+	pflag.Int("port", scraper.DefaultPort, "Port to connect to")
+	pflag.String("allowed-ports", "", "Comma separated list of the only ports that may be scanned")
+	pflag.String("denied-ports", "", "Comma separated list of ports that may never be scanned")
+	pflag.Duration("watch", 0, "Re-run the scan on this interval, serving metrics between runs")
+	pflag.String("metrics-addr", ":9090", "Address to serve Prometheus metrics on in watch mode")
+	pflag.String("socket", "", "Listen on this Unix socket for newline-delimited targets and reply with NDJSON results")
+	pflag.String("serve", "", "Serve scans over HTTP on this address, such as :8080, at /scan alongside /metrics")
+	pflag.Duration("expiry-warning", 30*24*time.Hour, "Log certificates that start expiring within this window in watch mode, and fail --check for them")
+	pflag.StringSlice("pin", nil, "Expected sha256:HEX leaf certificate fingerprint, repeatable")
+	pflag.String("pins-file", "", "Path to a file of per-domain sha256:HEX pins")
+	pflag.String("distrust-file", "", "Path to a file of sha256:HEX fingerprints of distrusted roots, one per line")
+	pflag.String("ca-bundle", "", "Path to a PEM bundle of extra root certificates to trust, such as a private CA")
+	pflag.Bool("ca-bundle-only", false, "Trust only the roots in ca-bundle instead of adding them to the system roots")
+	pflag.Bool("pre-resolve", false, "Resolve all domains before starting TLS handshakes")
+	pflag.String("format", "json", "Output format: json, html or protobuf")
+	pflag.Duration("shutdown-grace", server.DefaultGracePeriod, "Time allowed for in-flight HTTP requests to finish on shutdown")
+	pflag.String("proxy", "", "Connect through this socks5:// or http:// proxy, defaults to HTTPS_PROXY or ALL_PROXY")
+	pflag.Int("proxy-protocol", 0, "Send a PROXY protocol header of this version (1 or 2) before the TLS handshake")
+	pflag.String("proxy-protocol-src", "", "Claimed source ip:port in the PROXY header, defaults to the local address")
+	pflag.String("proxy-protocol-dst", "", "Claimed destination ip:port in the PROXY header, defaults to the remote address")
+	pflag.String("starttls", "", "Upgrade connections with STARTTLS before the TLS handshake: smtp, imap or pop3")
+	pflag.String("check", "", "Check a single domain and exit non-zero if its certificate is invalid or expiring")
+	pflag.Bool("verbose", false, "Print the reason for the --check result")
+	pflag.String("dump-connstate", "", "Print the TLS connection details of a single domain for debugging and exit")
+	pflag.Duration("cache-ttl", 0, "Reuse successful results for this long instead of scanning again")
+	pflag.Int("cache-size", scraper.DefaultCacheSize, "Maximum number of results kept when cache-ttl is set")
+	pflag.StringSlice("resolve", nil, "Connect to ip instead of resolving host, as host:ip, repeatable")
+	pflag.Bool("exemplars", false, "Attach target and serial exemplars to the scrape latency histogram and serve OpenMetrics")
+	pflag.StringSlice("metric-labels", nil, "Comma separated meta-columns to add as labels to the certificate expiry metric")
+	pflag.Bool("domain-metrics", true, "Record the metrics that have a series per domain; disable when scanning large ranges")
+	pflag.Bool("revocation-notes", false, "Note certificates that offer only one of OCSP and CRLs for revocation checking")
+	pflag.String("sni", "", "Server name to send as SNI, and validate against, for the fqdn, check or dump-connstate target, such as when it is an IP address")
+	pflag.Bool("strict-sni", false, "Make an extra handshake with a bogus SNI and fail domains whose server ignores SNI")
+	pflag.Bool("tls-fallback", false, "Retry failed handshakes offering TLS versions down to 1.0 and note servers that need it")
+	pflag.Int("max-chain-depth", scraper.DefaultMaxChainDepth, "Keep at most this many certificates of each chain, 0 for no limit")
+	pflag.Int("consistency-check", 0, "Handshake this many times with each target and fail those that return different certificates")
+	pflag.Bool("ocsp", false, "Check the revocation status of each leaf certificate with its OCSP responder")
+	pflag.Bool("ocsp-nonce", false, "Send a nonce with each OCSP request and reject responses that echo a different one")
+	pflag.Bool("crl", false, "Check the revocation status of each leaf certificate against the CRL it names")
+	pflag.Bool("aia", false, "Download intermediates a server didn't send from the AIA URLs in its certificates")
+	pflag.Int("circuit-breaker", 0, "Skip the rest of a network's targets after this many consecutive timeouts in it, 0 to disable")
+	pflag.Duration("circuit-breaker-cooldown", scraper.DefaultCircuitCooldown, "How long the circuit breaker skips a network before trying one of its targets again")
+	pflag.Int("circuit-breaker-v4-prefix", scraper.DefaultCircuitPrefixV4, "Prefix length grouping IPv4 targets into networks for the circuit breaker")
+	pflag.Int("circuit-breaker-v6-prefix", scraper.DefaultCircuitPrefixV6, "Prefix length grouping IPv6 targets into networks for the circuit breaker")
+	pflag.StringSlice("meta-columns", nil, "Comma separated CSV columns to attach to each result as metadata")
+	pflag.Bool("with-www", false, "Also scan the www variant of each domain, or the apex of www domains")
+	pflag.Bool("dtls", false, "Experimental: scan UDP ports with a DTLS handshake instead of TLS over TCP")
 	pflag.Parse()
 	err := viper.BindPFlags(pflag.CommandLine)
 	if err != nil {
@@ -40,71 +214,842 @@ func init() {
 
 }
 
-func chunkSlice(slice []string, chunkSize int) [][]string {
-	var chunks [][]string
-	for i := 0; i < len(slice); i += chunkSize {
-		end := i + chunkSize
-		if end > len(slice) {
-			end = len(slice)
+// effectiveChunkSize returns the number of completed targets whose results
+// are written together. A chunk size that is not set falls back to the
+// concurrency.
+func effectiveChunkSize(chunkSize, concurrency int) int {
+	if chunkSize <= 0 {
+		chunkSize = concurrency
+	}
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	return chunkSize
+}
+
+// parseTCPAddr parses an optional ip:port address.
+func parseTCPAddr(address string) (*net.TCPAddr, error) {
+	if address == "" {
+		return nil, nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return nil, err
+	}
+	return net.TCPAddrFromAddrPort(addrPort), nil
+}
+
+// parsePorts parses a comma separated list of port numbers.
+func parsePorts(list string) ([]int, error) {
+	var ports []int
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		port, err := strconv.Atoi(field)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", field)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// checkMetricLabels checks that every metric label is a valid Prometheus label
+// name, other than the domain label that is always present, and names one of
+// the CSV metadata columns its values are taken from.
+func checkMetricLabels(labels, metaColumns []string) error {
+	for _, label := range labels {
+		if label == "domain" || !model.LabelName(label).IsValid() || strings.HasPrefix(label, "__") {
+			return fmt.Errorf("%q is not a usable label name", label)
+		}
+		found := false
+		for _, column := range metaColumns {
+			if column == label {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("label %q must also be listed in meta-columns", label)
 		}
-		chunks = append(chunks, slice[i:end])
 	}
-	return chunks
+	return nil
+}
+
+// parseResolveOverrides parses host:ip overrides. IPv6 addresses may be
+// given with or without brackets, as in example.com:[2001:db8::1].
+func parseResolveOverrides(entries []string) (map[string]net.IP, error) {
+	overrides := make(map[string]net.IP, len(entries))
+	for _, entry := range entries {
+		host, address, found := strings.Cut(entry, ":")
+		if !found || host == "" {
+			return nil, fmt.Errorf("invalid resolve override %q, expected host:ip", entry)
+		}
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address in resolve override %q", entry)
+		}
+		overrides[host] = ip
+	}
+	return overrides, nil
 }
 
 func main() {
 	fqdn := viper.GetString("fqdn")
 	filepath := viper.GetString("filepath")
+	hostsFile := viper.GetString("hostsfile")
+	jsonlFile := viper.GetString("jsonlfile")
+	jsonInput := viper.GetString("json-input")
 	csvHeader := viper.GetString("header")
 	output := viper.GetString("outdir")
 	concurrency := viper.GetInt("concurrency")
+	chunkSize := effectiveChunkSize(viper.GetInt("chunk-size"), concurrency)
 	prettyPrint := viper.GetBool("prettyjson")
+	sanInventory := viper.GetBool("san-inventory")
+	adaptiveTimeout := viper.GetBool("adaptive-timeout")
+	port := viper.GetInt("port")
+	check := viper.GetString("check")
+	dumpConnState := viper.GetString("dump-connstate")
+	// The single target modes don't read any other targets.
+	single := check != "" || dumpConnState != ""
+	socket := viper.GetString("socket")
+	serve := viper.GetString("serve")
+	// The service modes take their targets from requests.
+	service := socket != "" || serve != ""
 
-	if fqdn != "" && filepath != "" {
-		log.Fatal("You can only pass either fqdn or filepath and header, but not both.")
+	if !single && !service {
+		sources := 0
+		for _, source := range []string{fqdn, filepath, hostsFile, jsonlFile, jsonInput} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			log.Fatal("You can only pass one of fqdn, filepath and header, hostsfile, jsonlfile, or json-input.")
+		}
+		if sources == 0 && len(viper.GetStringSlice("subnet")) == 0 && viper.GetString("aws-elbs") == "" {
+			log.Fatal("You must pass either fqdn, filepath, hostsfile, jsonlfile, json-input, subnet or aws-elbs.")
+		}
 	}
-	if fqdn == "" && filepath == "" {
-		log.Fatal("You must pass either fqdn or filepath.")
+
+	startTLS, err := scraper.ParseStartTLS(viper.GetString("starttls"))
+	if err != nil {
+		log.Fatalf("error parsing starttls: %v", err)
 	}
 
-	var websites []string
-	var err error
+	limits := subnetLimits{
+		max:    viper.GetInt("max-subnet-size"),
+		sample: viper.GetInt("sample"),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if limits.sample > limits.max {
+		log.Fatalf("sample must be no larger than max-subnet-size, got %d and %d", limits.sample, limits.max)
+	}
+	subnetWebsites, err := expandSubnets(viper.GetStringSlice("subnet"), limits)
+	if err != nil {
+		log.Fatalf("error parsing subnet: %v", err)
+	}
 
-	if fqdn != "" {
-		websites = []string{fqdn}
-	} else {
-		websites, err = helper.ReadCSV(filepath, csvHeader)
+	var cloudWebsites []string
+	if region := viper.GetString("aws-elbs"); region != "" && !single && !service {
+		cloudWebsites, err = listAWSLoadBalancers(region)
+		if err != nil {
+			log.Fatalf("error listing AWS load balancers: %v", err)
+		}
+		log.Printf("Found %d load balancers in %s", len(cloudWebsites), region)
+	}
+
+	var hostsFileWebsites []string
+	var domainPorts map[string]int
+	if hostsFile != "" && !single {
+		targets, err := helper.ReadHostsFile(hostsFile)
+		if err != nil {
+			log.Fatalf("error reading hosts file: %v", err)
+		}
+		hostsFileWebsites = targetWebsites(targets)
+	}
+
+	var csvWebsites []string
+	var metadata map[string]map[string]string
+	if filepath != "" && !single {
+		targets, csvMetadata, err := helper.ReadCSVTargets(filepath, csvHeader, viper.GetStringSlice("meta-columns"))
 		if err != nil {
 			log.Fatalf("error reading CSV: %v", err)
 		}
+		csvWebsites = targetWebsites(targets)
+		// Results are keyed by the website scanned, which for a row that
+		// gave a port is host:port.
+		for _, target := range targets {
+			if meta, ok := csvMetadata[target.Host]; ok && target.Port != 0 {
+				csvMetadata[targetWebsite(target)] = meta
+			}
+		}
+		metadata = csvMetadata
 	}
 
-	chunks := chunkSlice(websites, concurrency)
+	var jsonlWebsites []string
+	var jsonlOverrides targetOverrides
+	if jsonlFile != "" && !single {
+		targets, lineErrs, err := helper.ReadTargetsJSONL(jsonlFile)
+		if err != nil {
+			log.Fatalf("error reading JSON Lines file: %v", err)
+		}
+		for _, err := range lineErrs {
+			log.Printf("Skipping target in %s: %v", jsonlFile, err)
+		}
+		var targetErrs []error
+		jsonlWebsites, jsonlOverrides, targetErrs = jsonlTargets(targets)
+		for _, err := range targetErrs {
+			log.Printf("Skipping target in %s: %v", jsonlFile, err)
+		}
+		domainPorts = jsonlOverrides.ports
+	}
+	if sni := viper.GetString("sni"); sni != "" {
+		target := fqdn
+		if check != "" {
+			target = check
+		} else if dumpConnState != "" {
+			target = dumpConnState
+		}
+		if target == "" {
+			log.Fatal("sni can only be used with fqdn, check or dump-connstate.")
+		}
+		if jsonlOverrides.sni == nil {
+			jsonlOverrides.sni = make(map[string]string)
+		}
+		jsonlOverrides.sni[target] = sni
+	}
 
-	for _, chunk := range chunks {
-		details, err := scraper.ScrapeTLS(chunk, concurrency)
+	var jsonWebsites []string
+	if jsonInput != "" && !single {
+		jsonWebsites, err = helper.ReadJSON(jsonInput)
 		if err != nil {
-			if multiErr, ok := err.(*scraper.MultiError); ok {
-				for domain, e := range multiErr.Errors {
-					log.Printf("Failed to scrape domain %s with error: %s", domain, e.Error())
-				}
-			} else {
-				log.Printf("Error scraping TLS: %v", err)
+			log.Fatalf("error reading JSON input %s: %v", jsonInput, err)
+		}
+	}
+
+	allowedPorts, err := parsePorts(viper.GetString("allowed-ports"))
+	if err != nil {
+		log.Fatalf("error parsing allowed-ports: %v", err)
+	}
+	deniedPorts, err := parsePorts(viper.GetString("denied-ports"))
+	if err != nil {
+		log.Fatalf("error parsing denied-ports: %v", err)
+	}
+	portPolicy := scraper.PortPolicy{Allowed: allowedPorts, Denied: deniedPorts}
+	if err := portPolicy.Check(port); err != nil {
+		log.Fatalf("refusing to scan: %v", err)
+	}
+
+	pins := viper.GetStringSlice("pin")
+	for _, pin := range pins {
+		if _, err := scraper.NormalizePin(pin); err != nil {
+			log.Fatalf("error parsing pin: %v", err)
+		}
+	}
+	overrides, err := parseResolveOverrides(viper.GetStringSlice("resolve"))
+	if err != nil {
+		log.Fatalf("error parsing resolve: %v", err)
+	}
+
+	var domainPins map[string][]string
+	if pinsFile := viper.GetString("pins-file"); pinsFile != "" {
+		domainPins, err = helper.ReadPinsFile(pinsFile)
+		if err != nil {
+			log.Fatalf("error reading pins file: %v", err)
+		}
+	}
+
+	var distrusted []string
+	if distrustFile := viper.GetString("distrust-file"); distrustFile != "" {
+		distrusted, err = helper.ReadDistrustFile(distrustFile)
+		if err != nil {
+			log.Fatalf("error reading distrust file: %v", err)
+		}
+	}
+
+	var roots *x509.CertPool
+	if caBundle := viper.GetString("ca-bundle"); caBundle != "" {
+		roots, err = helper.ReadCABundle(caBundle, !viper.GetBool("ca-bundle-only"))
+		if err != nil {
+			log.Fatalf("error reading CA bundle: %v", err)
+		}
+	}
+
+	metricLabels := viper.GetStringSlice("metric-labels")
+	if err := checkMetricLabels(metricLabels, viper.GetStringSlice("meta-columns")); err != nil {
+		log.Fatalf("error parsing metric-labels: %v", err)
+	}
+	// Filled in from the metadata once the targets have been read.
+	targetLabels := make(map[string]map[string]string)
+
+	filter := helper.FilterOptions{
+		OnlyInvalid:    viper.GetBool("only-invalid"),
+		ExpiringWithin: viper.GetInt("expiring-within"),
+	}
+	if filter.ExpiringWithin < 0 {
+		log.Fatalf("expiring-within must not be negative, got %d", filter.ExpiringWithin)
+	}
+
+	conditions := failConditions{
+		invalid:        viper.GetBool("fail-on-invalid"),
+		expiringWithin: viper.GetInt("fail-expiring-within"),
+		errors:         viper.GetBool("fail-on-error"),
+	}
+	if conditions.expiringWithin < 0 {
+		log.Fatalf("fail-expiring-within must not be negative, got %d", conditions.expiringWithin)
+	}
+	if conditions.set() && viper.GetDuration("watch") > 0 {
+		log.Fatal("fail-on-invalid, fail-expiring-within and fail-on-error can't be used with watch, which never exits.")
+	}
+
+	scraper.ConfigureMetrics(scraper.MetricsOptions{DisableDomainLabels: !viper.GetBool("domain-metrics")})
+
+	opts := []scraper.Option{
+		scraper.WithConcurrency(concurrency),
+		scraper.WithTimings(viper.GetBool("timings")),
+		scraper.WithPort(port),
+		scraper.WithTimeout(viper.GetDuration("timeout")),
+		scraper.WithDomainPorts(domainPorts),
+		scraper.WithDomainSNI(jsonlOverrides.sni),
+		scraper.WithDomainTimeouts(jsonlOverrides.timeouts),
+		scraper.WithStartTLS(startTLS),
+		scraper.WithDomainStartTLS(jsonlOverrides.startTLS),
+		scraper.WithPortPolicy(portPolicy),
+		scraper.WithPins(pins...),
+		scraper.WithDomainPins(domainPins),
+		scraper.WithDistrustedRoots(distrusted...),
+		scraper.WithRoots(roots),
+		scraper.WithPreResolve(viper.GetBool("pre-resolve")),
+		scraper.WithCache(viper.GetDuration("cache-ttl"), viper.GetInt("cache-size")),
+		scraper.WithResolveOverrides(overrides),
+		scraper.WithExemplars(viper.GetBool("exemplars")),
+		scraper.WithMetricLabels(metricLabels, targetLabels),
+		scraper.WithStrictSNI(viper.GetBool("strict-sni")),
+		scraper.WithTLSFallback(viper.GetBool("tls-fallback")),
+		scraper.WithMaxChainDepth(viper.GetInt("max-chain-depth")),
+		scraper.WithConsistencyCheck(viper.GetInt("consistency-check")),
+		scraper.WithOCSP(viper.GetBool("ocsp")),
+		scraper.WithOCSPNonce(viper.GetBool("ocsp-nonce")),
+		scraper.WithCRL(viper.GetBool("crl")),
+		scraper.WithAIA(viper.GetBool("aia")),
+		scraper.WithCircuitBreaker(viper.GetInt("circuit-breaker"), viper.GetInt("circuit-breaker-v4-prefix"), viper.GetInt("circuit-breaker-v6-prefix")),
+		scraper.WithCircuitCooldown(viper.GetDuration("circuit-breaker-cooldown")),
+		scraper.WithPartialRevocationNotes(viper.GetBool("revocation-notes")),
+		scraper.WithDTLS(viper.GetBool("dtls")),
+		scraper.WithRampUp(viper.GetDuration("ramp-up")),
+		scraper.WithRateLimit(viper.GetFloat64("rate")),
+	}
+	proxyURL, err := effectiveProxy()
+	if err != nil {
+		log.Fatalf("error parsing proxy: %v", err)
+	}
+	if proxyURL != nil {
+		if viper.GetBool("dtls") {
+			log.Fatal("proxy can't be used with dtls, which doesn't connect over TCP.")
+		}
+		proxy, err := scraper.NewProxyDialer(proxyURL)
+		if err != nil {
+			log.Fatalf("error parsing proxy: %v", err)
+		}
+		opts = append(opts, scraper.WithProxy(proxy))
+	}
+	if version := viper.GetInt("proxy-protocol"); version != 0 {
+		src, err := parseTCPAddr(viper.GetString("proxy-protocol-src"))
+		if err != nil {
+			log.Fatalf("error parsing proxy-protocol-src: %v", err)
+		}
+		dst, err := parseTCPAddr(viper.GetString("proxy-protocol-dst"))
+		if err != nil {
+			log.Fatalf("error parsing proxy-protocol-dst: %v", err)
+		}
+		opts = append(opts, scraper.WithProxyProtocol(scraper.ProxyHeader{Version: version, Source: src, Destination: dst}))
+	}
+	if adaptiveTimeout {
+		opts = append(opts, scraper.WithAdaptiveTimeout(
+			viper.GetDuration("adaptive-timeout-min"),
+			viper.GetDuration("adaptive-timeout-max"),
+		))
+	}
+	if viper.GetBool("stream") {
+		opts = append(opts, scraper.WithResultHandler(streamResults(os.Stdout, targetLabels, filter)))
+	}
+	if keyLogFile := os.Getenv("SSLKEYLOGFILE"); keyLogFile != "" {
+		file, err := os.OpenFile(keyLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Printf("Not logging TLS keys, can't open SSLKEYLOGFILE: %v", err)
+		} else {
+			defer file.Close()
+			opts = append(opts, scraper.WithKeyLogWriter(file))
+		}
+	}
+	scanner := scraper.NewScanner(opts...)
+
+	if check != "" {
+		detail, err := scanner.ScrapeDomain(check)
+		code, reason := evaluateCheck(detail, err, viper.GetDuration("expiry-warning"), time.Now())
+		if viper.GetBool("verbose") {
+			fmt.Printf("%s: %s\n", check, reason)
+		}
+		os.Exit(code)
+	}
+
+	if dumpConnState != "" {
+		detail, err := scanner.ScrapeDomain(dumpConnState)
+		if err != nil {
+			log.Fatalf("error scraping %s: %v", dumpConnState, err)
+		}
+		state, _ := detail.ConnectionState()
+		if err := helper.WriteConnectionState(os.Stdout, state); err != nil {
+			log.Fatalf("error writing connection state: %v", err)
+		}
+		return
+	}
+
+	if socket != "" {
+		serveSocket(socket, scanner, concurrency)
+		return
+	}
+
+	if serve != "" {
+		serveScans(serve, opts)
+		return
+	}
+
+	var websites []string
+
+	if fqdn != "" {
+		websites = []string{fqdn}
+	} else if hostsFile != "" {
+		websites = hostsFileWebsites
+	} else if jsonlFile != "" {
+		websites = jsonlWebsites
+	} else if jsonInput != "" {
+		websites = jsonWebsites
+	} else if filepath != "" {
+		websites = csvWebsites
+	}
+	websites = append(websites, subnetWebsites...)
+	websites = append(websites, cloudWebsites...)
+
+	if viper.GetBool("with-www") {
+		websites = expandWWW(websites)
+		// Let the added variants share the metadata of the name they came from.
+		for _, website := range websites {
+			if _, ok := metadata[website]; ok || metadata == nil {
+				continue
+			}
+			if meta, ok := metadata[wwwVariant(website)]; ok {
+				metadata[website] = meta
 			}
 		}
+		// And the port given for it in a JSON Lines file.
+		for _, website := range websites {
+			if _, ok := domainPorts[website]; ok {
+				continue
+			}
+			if port, ok := domainPorts[wwwVariant(website)]; ok {
+				domainPorts[website] = port
+			}
+		}
+	}
+
+	for website, meta := range metadata {
+		targetLabels[website] = meta
+	}
+
+	if viper.GetBool("probe-only") {
+		// SIGINT and SIGTERM stop the probe, keeping the results collected
+		// so far.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := writeProbeResults(output, scanner.ProbeTLSContext(ctx, websites)); err != nil {
+			log.Fatalf("error writing probe results: %v", err)
+		}
+		return
+	}
+
+	format := viper.GetString("format")
+	if format != "json" && format != "html" && format != "protobuf" {
+		log.Fatalf("unknown format %q, expected json, html or protobuf", format)
+	}
+
+	splitBy := viper.GetString("split-by")
+	if splitBy != "" && splitBy != "issuer" {
+		log.Fatalf("unknown split-by %q, expected issuer", splitBy)
+	}
+
+	summaryOnly := viper.GetString("summary-only")
+	if summaryOnly != "" && summaryOnly != "json" && summaryOnly != "text" {
+		log.Fatalf("unknown summary-only format %q, expected json or text", summaryOnly)
+	}
+
+	if viper.GetBool("csv") && (output == "" || format != "json") {
+		log.Fatalf("csv needs outdir, and can't be used with %s output", format)
+	}
+
+	// Streamed results go to stdout, so nothing else may be written there.
+	if viper.GetBool("stream") && (summaryOnly != "" || (format != "json" && output == "")) {
+		log.Fatalf("stream writes to stdout, so it needs outdir for %s output and can't be used with summary-only", format)
+	}
+
+	out := scanOutput{
+		format:        format,
+		directory:     output,
+		prettyPrint:   prettyPrint,
+		minimal:       viper.GetBool("minimal-output"),
+		sanInventory:  sanInventory,
+		deterministic: viper.GetBool("deterministic"),
+		flattenBundle: viper.GetBool("flatten-bundle"),
+		csv:           viper.GetBool("csv"),
+		splitBy:       splitBy,
+		config:        newScanConfig(),
+		summaryOnly:   summaryOnly,
+		metadata:      metadata,
+		filter:        filter,
+	}
+
+	// SIGINT and SIGTERM stop the scan, keeping the results collected so far.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	interval := viper.GetDuration("watch")
+	if interval <= 0 {
+		details, errs := runScan(ctx, scanner, websites, chunkSize, out)
+		if code, reason := evaluateScan(details, errs, conditions); code != checkOK {
+			log.Printf("Failing scan, %s", reason)
+			stop()
+			os.Exit(code)
+		}
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler())
+	metricsServer := server.New(viper.GetString("metrics-addr"), mux)
+	metricsServer.GracePeriod = viper.GetDuration("shutdown-grace")
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		log.Printf("Serving metrics on %s/metrics", viper.GetString("metrics-addr"))
+		if err := metricsServer.ListenAndServe(ctx); err != nil {
+			log.Fatalf("error serving metrics: %v", err)
+		}
+	}()
+
+	watch(ctx, interval, viper.GetDuration("expiry-warning"), func() ([]*scraper.CertDetails, map[string]error) {
+		return runScan(ctx, scanner, websites, chunkSize, out)
+	})
+	<-served
+}
+
+// effectiveProxy returns the proxy URL from the proxy flag or, failing that,
+// the HTTPS_PROXY or ALL_PROXY environment variables, and nil when there is
+// none.
+func effectiveProxy() (*url.URL, error) {
+	if proxy := viper.GetString("proxy"); proxy != "" {
+		return url.Parse(proxy)
+	}
+	return scraper.ProxyFromEnvironment()
+}
+
+// listAWSLoadBalancers returns the DNS names of the load balancers in an AWS
+// region, using credentials from the environment.
+func listAWSLoadBalancers(region string) ([]string, error) {
+	creds, err := inventory.AWSCredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	lister := &inventory.ELBLister{Region: region, Credentials: creds}
+	return lister.DNSNames(ctx)
+}
+
+// serveSocket scrapes targets sent to a Unix socket at path until SIGINT or
+// SIGTERM.
+func serveSocket(path string, scanner *scraper.Scanner, concurrency int) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		log.Fatalf("error listening on socket: %v", err)
+	}
+	log.Printf("Accepting targets on %s", path)
+	if err := server.NewTargetServer(scanner, concurrency).Serve(ctx, ln); err != nil {
+		log.Fatalf("error serving socket: %v", err)
+	}
+}
+
+// serveScans serves scans over HTTP on addr until SIGINT or SIGTERM. Requests
+// share a scanner made from opts for each port and concurrency they ask for,
+// so that the cache, rate limit and circuit breaker apply across requests.
+func serveScans(addr string, opts []scraper.Option) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	newScanner := func(port, concurrency int) server.BatchScraper {
+		// Copied so that requests don't append to each other's options.
+		scannerOpts := append([]scraper.Option(nil), opts...)
+		if port != 0 {
+			scannerOpts = append(scannerOpts, scraper.WithPort(port))
+		}
+		if concurrency != 0 {
+			scannerOpts = append(scannerOpts, scraper.WithConcurrency(concurrency))
+		}
+		return scraper.NewScanner(scannerOpts...)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/scan", server.NewScanHandler(newScanner))
+	mux.Handle("/metrics", metricsHandler())
+	scanServer := server.New(addr, mux)
+	scanServer.GracePeriod = viper.GetDuration("shutdown-grace")
 
-		if output != "" {
-			for _, detail := range details {
-				err = helper.WriteJSON(output, detail, prettyPrint)
+	log.Printf("Serving scans on %s/scan", addr)
+	if err := scanServer.ListenAndServe(ctx); err != nil {
+		log.Fatalf("error serving scans: %v", err)
+	}
+}
+
+// metricsHandler returns the handler for /metrics, serving OpenMetrics when
+// exemplars are enabled.
+func metricsHandler() http.Handler {
+	if viper.GetBool("exemplars") {
+		return scraper.GetOpenMetricsHandler()
+	}
+	return scraper.GetMetricsHandler()
+}
+
+// scanOutput holds the settings that control where scan results are written.
+type scanOutput struct {
+	format       string
+	directory    string
+	prettyPrint  bool
+	sanInventory bool
+
+	// minimal leaves empty and zero values out of the JSON output.
+	minimal bool
+
+	// summaryOnly, when "json" or "text", writes only the scan summary in
+	// that format instead of any per-certificate output.
+	summaryOnly string
+
+	// flattenBundle writes every result, from domain and IP targets alike,
+	// to a single bundle.json in directory.
+	flattenBundle bool
+
+	// csv writes the results to results.csv in directory instead of one
+	// JSON file per domain.
+	csv bool
+
+	// splitBy, when "issuer", writes the results to one bundle per issuer
+	// in directory.
+	splitBy string
+
+	// config is the effective scan configuration, written to the bundles.
+	config scanConfig
+
+	// deterministic sorts results, and the lists within them, before they
+	// are written.
+	deterministic bool
+
+	// metadata is attached to the result for each domain before it is written.
+	metadata map[string]map[string]string
+
+	// filter selects the results that are written. Every result is still
+	// returned from runScan.
+	filter helper.FilterOptions
+}
+
+// runScan scrapes the websites, writing the results in chunks as they
+// complete. It returns every result and error from the scan. Once ctx is done
+// no further websites are dialled, and the results collected so far are
+// written as usual.
+func runScan(ctx context.Context, scanner *scraper.Scanner, websites []string, chunkSize int, out scanOutput) ([]*scraper.CertDetails, map[string]error) {
+	start := time.Now()
+	results := newScanResults()
+	if ctx.Err() != nil {
+		log.Printf("Scan interrupted, skipping %d targets", len(websites))
+		return nil, nil
+	}
+
+	scanner.ScrapeTLSBatches(ctx, websites, chunkSize, func(details []*scraper.CertDetails, errs map[string]error) {
+		for domain, e := range errs {
+			log.Printf("Failed to scrape domain %s with error: %s", domain, e.Error())
+		}
+
+		for _, detail := range details {
+			if meta, ok := out.metadata[detail.Domain]; ok {
+				detail.Metadata = meta
+			}
+			if out.deterministic {
+				detail.Canonicalize()
+			}
+		}
+		if out.deterministic {
+			scraper.SortDetails(details)
+		}
+
+		results.add(details, errs)
+		if out.summaryOnly != "" {
+			return
+		}
+
+		reported := helper.FilterCertDetails(details, out.filter)
+		if out.format == "json" && out.directory != "" && !out.csv {
+			for _, detail := range reported {
+				var err error
+				if out.minimal {
+					err = helper.WriteMinimalJSON(out.directory, detail, out.prettyPrint)
+				} else {
+					err = helper.WriteJSON(out.directory, detail, out.prettyPrint)
+				}
 				if err != nil {
 					log.Printf("Error writing JSON for domain %s: %v", detail.Domain, err)
 				}
 			}
 		}
 
-		err = helper.WriteLog(details)
-		if err != nil {
+		if err := helper.WriteLog(reported); err != nil {
 			log.Printf("Error writing log: %v", err)
 		}
+	})
+
+	allDetails, allErrors := results.snapshot()
+	if out.deterministic {
+		scraper.SortDetails(allDetails)
+	}
+	reported := helper.FilterCertDetails(allDetails, out.filter)
+
+	if out.summaryOnly != "" {
+		if err := writeSummary(out.summaryOnly, scraper.Summarize(reported, allErrors, time.Since(start))); err != nil {
+			log.Printf("Error writing summary: %v", err)
+		}
+		return allDetails, allErrors
+	}
+
+	if out.sanInventory {
+		inventory := scraper.CollectSANs(reported)
+		if out.directory != "" {
+			err := helper.WriteSANInventory(out.directory, inventory, out.prettyPrint)
+			if err != nil {
+				log.Printf("Error writing SAN inventory: %v", err)
+			}
+		}
+		helper.WriteSANLog(inventory)
+	}
+
+	if out.csv && out.directory != "" {
+		if err := helper.WriteCSV(out.directory, reported); err != nil {
+			log.Printf("Error writing CSV: %v", err)
+		}
+	}
+
+	bundle := helper.Bundle{Config: out.config, Results: reported}
+	// The start time would differ between otherwise identical scans.
+	if !out.deterministic {
+		bundle.GeneratedAt = start.UTC()
+	}
+
+	if out.flattenBundle && out.directory != "" {
+		if err := helper.WriteBundle(out.directory, bundle, out.prettyPrint); err != nil {
+			log.Printf("Error writing bundle: %v", err)
+		}
+	}
+
+	if out.splitBy == "issuer" && out.directory != "" {
+		if err := helper.WriteBundlesByIssuer(out.directory, bundle, out.prettyPrint); err != nil {
+			log.Printf("Error writing bundles by issuer: %v", err)
+		}
 	}
+
+	if out.format == "html" {
+		summary := scraper.Summarize(reported, allErrors, time.Since(start))
+		if err := writeHTMLReport(out.directory, reported, summary); err != nil {
+			log.Printf("Error writing HTML report: %v", err)
+		}
+	}
+
+	if out.format == "protobuf" {
+		if err := writeProtobuf(out.directory, reported); err != nil {
+			log.Printf("Error writing protobuf results: %v", err)
+		}
+	}
+
+	return allDetails, allErrors
+}
+
+// writeSummary writes the scan summary to stdout as JSON or text.
+func writeSummary(format string, summary scraper.ScanSummary) error {
+	if format == "json" {
+		return helper.WriteSummaryJSON(os.Stdout, summary)
+	}
+	return helper.WriteSummaryText(os.Stdout, summary)
+}
+
+// writeProbeResults writes the probe results as JSON lines to probe.jsonl in
+// directory, or to stdout when no directory is set.
+func writeProbeResults(directory string, results []*scraper.ProbeResult) error {
+	if directory == "" {
+		return helper.WriteProbeResults(os.Stdout, results)
+	}
+
+	return helper.WriteFileAtomic(fmt.Sprintf("%s/probe.jsonl", directory), func(w io.Writer) error {
+		return helper.WriteProbeResults(w, results)
+	})
+}
+
+// streamResults returns a result handler that writes each result to w as a
+// line of JSON as soon as it is scraped, with the metadata read for its
+// domain attached. Results the filter doesn't select are dropped; failures
+// are always written.
+func streamResults(w io.Writer, metadata map[string]map[string]string, filter helper.FilterOptions) func(string, *scraper.CertDetails, error) {
+	return func(domain string, details *scraper.CertDetails, err error) {
+		if details != nil {
+			if !filter.Match(details) {
+				return
+			}
+			if meta, ok := metadata[domain]; ok {
+				details.Metadata = meta
+			}
+		}
+		if err := helper.WriteStreamResult(w, domain, details, err); err != nil {
+			log.Printf("Error streaming result for domain %s: %v", domain, err)
+		}
+	}
+}
+
+// writeHTMLReport writes the HTML report to report.html in directory, or to
+// stdout when no directory is set.
+func writeHTMLReport(directory string, details []*scraper.CertDetails, summary scraper.ScanSummary) error {
+	if directory == "" {
+		return helper.WriteHTML(os.Stdout, details, summary)
+	}
+
+	return helper.WriteFileAtomic(fmt.Sprintf("%s/report.html", directory), func(w io.Writer) error {
+		return helper.WriteHTML(w, details, summary)
+	})
+}
+
+// writeProtobuf writes the results as length-delimited protobuf records to
+// results.pb in directory, or to stdout when no directory is set.
+func writeProtobuf(directory string, details []*scraper.CertDetails) error {
+	write := func(w io.Writer) error {
+		pw := proto.NewWriter(w)
+		for _, detail := range details {
+			if err := pw.Write(detail); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if directory == "" {
+		return write(os.Stdout)
+	}
+
+	return helper.WriteFileAtomic(fmt.Sprintf("%s/results.pb", directory), write)
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/scotta01/tls-scrape/internal/helper"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"net"
+	"testing"
+)
+
+func TestParseResolveOverrides(t *testing.T) {
+	overrides, err := parseResolveOverrides([]string{
+		"example.com:192.0.2.10",
+		"v6.example.com:2001:db8::1",
+		"bracketed.example.com:[2001:db8::2]",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	expected := map[string]string{
+		"example.com":           "192.0.2.10",
+		"v6.example.com":        "2001:db8::1",
+		"bracketed.example.com": "2001:db8::2",
+	}
+	for host, ip := range expected {
+		if !overrides[host].Equal(net.ParseIP(ip)) {
+			t.Errorf("expected %s to map to %s, got %s", host, ip, overrides[host])
+		}
+	}
+
+	for _, entry := range []string{"example.com", ":192.0.2.10", "example.com:not-an-ip"} {
+		if _, err := parseResolveOverrides([]string{entry}); err == nil {
+			t.Errorf("expected an error for %q", entry)
+		}
+	}
+}
+
+func TestCheckMetricLabels(t *testing.T) {
+	metaColumns := []string{"owner", "env", "domain", "cost centre", "__name__"}
+	if err := checkMetricLabels([]string{"owner", "env"}, metaColumns); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+	}{
+		{"not a meta column", []string{"owner", "team"}},
+		{"domain label", []string{"domain"}},
+		{"invalid name", []string{"cost centre"}},
+		{"reserved name", []string{"__name__"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkMetricLabels(tt.labels, metaColumns); err == nil {
+				t.Errorf("expected an error for %v", tt.labels)
+			}
+		})
+	}
+}
+
+func TestChunkSizeIndependentOfConcurrency(t *testing.T) {
+	tests := []struct {
+		chunkSize, concurrency int
+		expected               int
+	}{
+		{chunkSize: 0, concurrency: 10, expected: 10},
+		{chunkSize: 25, concurrency: 10, expected: 25},
+		{chunkSize: 30, concurrency: 10, expected: 30},
+		{chunkSize: 5, concurrency: 50, expected: 5},
+		{chunkSize: 0, concurrency: 0, expected: 1},
+	}
+	for _, tt := range tests {
+		if got := effectiveChunkSize(tt.chunkSize, tt.concurrency); got != tt.expected {
+			t.Errorf("chunk size %d, concurrency %d: expected %d, got %d", tt.chunkSize, tt.concurrency, tt.expected, got)
+		}
+	}
+}
+
+func TestRunScanCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The targets would fail to resolve if they were dialled.
+	details, errs := runScan(ctx, scraper.NewScanner(), []string{"a.invalid", "b.invalid"}, 1, scanOutput{format: "json"})
+	if len(details) != 0 || len(errs) != 0 {
+		t.Errorf("expected no targets to be scanned once cancelled, got %d results and %d errors", len(details), len(errs))
+	}
+}
+
+func TestStreamResults(t *testing.T) {
+	var buf bytes.Buffer
+	handle := streamResults(&buf, map[string]map[string]string{"example.com": {"owner": "web"}}, helper.FilterOptions{OnlyInvalid: true})
+	handle("example.com", &scraper.CertDetails{Domain: "example.com", Serial: "1"}, nil)
+	handle("valid.example.com", &scraper.CertDetails{Domain: "valid.example.com", Serial: "2", Valid: true}, nil)
+	handle("broken.example.com", nil, errors.New("connection refused"))
+
+	var lines []map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var line map[string]interface{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("expected a line of JSON, got: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0]["serial"] != "1" || lines[0]["metadata"].(map[string]interface{})["owner"] != "web" {
+		t.Errorf("expected the details with their metadata, got %v", lines[0])
+	}
+	if lines[1]["domain"] != "broken.example.com" || lines[1]["error"] != "connection refused" {
+		t.Errorf("expected the failed domain and its error, got %v", lines[1])
+	}
+}
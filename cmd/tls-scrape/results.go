@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"sync"
+)
+
+// scanResults accumulates the results and errors of a scan. It is safe for
+// concurrent use, so chunks of a scan can be collected as they complete.
+type scanResults struct {
+	mu      sync.Mutex
+	details []*scraper.CertDetails
+	errors  map[string]error
+}
+
+func newScanResults() *scanResults {
+	return &scanResults{errors: make(map[string]error)}
+}
+
+// add records the results of a chunk and the errors of the domains that
+// failed.
+func (r *scanResults) add(details []*scraper.CertDetails, errs map[string]error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.details = append(r.details, details...)
+	for domain, err := range errs {
+		r.errors[domain] = err
+	}
+}
+
+// snapshot returns copies of the results and errors recorded so far.
+func (r *scanResults) snapshot() ([]*scraper.CertDetails, map[string]error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	details := append([]*scraper.CertDetails(nil), r.details...)
+	errs := make(map[string]error, len(r.errors))
+	for domain, err := range r.errors {
+		errs[domain] = err
+	}
+	return details, errs
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestScanResultsConcurrent collects chunks of a scan from many goroutines at
+// once. Run it with -race to check the accumulator.
+func TestScanResultsConcurrent(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse port: %v", err)
+	}
+
+	// Distinct names for the one target, since repeats are only scraped once.
+	const chunks, chunkSize = 8, 5
+	overrides := make(map[string]net.IP, chunks*chunkSize)
+	for i := 0; i < chunks*chunkSize; i++ {
+		overrides["target"+strconv.Itoa(i)+".example.com"] = net.ParseIP(host)
+	}
+	scanner := scraper.NewScanner(scraper.WithPort(port), scraper.WithResolveOverrides(overrides))
+	results := newScanResults()
+
+	var wg sync.WaitGroup
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			targets := make([]string, chunkSize)
+			for j := range targets {
+				targets[j] = "target" + strconv.Itoa(i*chunkSize+j) + ".example.com"
+			}
+			// One unresolvable name per chunk, so errors are collected too.
+			targets = append(targets, "chunk"+strconv.Itoa(i)+".invalid")
+
+			details, err := scanner.ScrapeTLS(targets)
+			var errs map[string]error
+			if multiErr, ok := err.(*scraper.MultiError); ok {
+				errs = multiErr.Errors
+			}
+			results.add(details, errs)
+		}(i)
+	}
+	wg.Wait()
+
+	details, errs := results.snapshot()
+	if len(details) != chunks*chunkSize {
+		t.Errorf("expected %d results, got %d", chunks*chunkSize, len(details))
+	}
+	if len(errs) != chunks {
+		t.Errorf("expected %d errors, got %d", chunks, len(errs))
+	}
+}
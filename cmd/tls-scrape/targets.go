@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"github.com/scotta01/tls-scrape/internal/helper"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wwwVariant returns domain with a leading "www." label removed, or added when
+// it has none.
+func wwwVariant(domain string) string {
+	if len(domain) > 4 && strings.EqualFold(domain[:4], "www.") {
+		return domain[4:]
+	}
+	return "www." + domain
+}
+
+// expandWWW adds the www variant of each domain straight after it, so that
+// both the apex and www names are scanned. IP addresses are left alone and
+// names already in the list are not added twice.
+func expandWWW(websites []string) []string {
+	seen := make(map[string]bool, len(websites))
+	for _, website := range websites {
+		seen[strings.ToLower(website)] = true
+	}
+
+	expanded := make([]string, 0, 2*len(websites))
+	for _, website := range websites {
+		expanded = append(expanded, website)
+		host := website
+		if h, _, err := net.SplitHostPort(website); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) != nil {
+			continue
+		}
+		variant := wwwVariant(website)
+		if seen[strings.ToLower(variant)] {
+			continue
+		}
+		seen[strings.ToLower(variant)] = true
+		expanded = append(expanded, variant)
+	}
+	return expanded
+}
+
+// targetWebsite returns the website to scan for a target of a hosts or CSV
+// file: its host, or host:port when it gave a port, so that a host listed
+// with several ports is scanned on each.
+func targetWebsite(target helper.Target) string {
+	if target.Port == 0 {
+		return target.Host
+	}
+	return net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+}
+
+// targetWebsites turns the targets of a hosts or CSV file into the websites
+// to scan. A target listed more than once is only scanned once, as described
+// for scraper.UniqueTargets.
+func targetWebsites(targets []helper.Target) []string {
+	websites := make([]string, len(targets))
+	for i, target := range targets {
+		websites[i] = targetWebsite(target)
+	}
+	return scraper.UniqueTargets(websites)
+}
+
+// subnetLimits bounds how many addresses a subnet expands to.
+type subnetLimits struct {
+	// max is the largest range that may be expanded in full.
+	max int
+
+	// sample, when positive, picks this many addresses at random from
+	// each larger range, using rng, instead of expanding it.
+	sample int
+	rng    *rand.Rand
+}
+
+// expandSubnets returns the addresses in the given CIDR, dash or single
+// address ranges: every address, or a sample for ranges larger than
+// limits.sample. A range too large to expand is an error naming it and its
+// size. Addresses covered by more than one range, including as an
+// IPv4-mapped IPv6 address, are only returned once.
+func expandSubnets(subnets []string, limits subnetLimits) ([]string, error) {
+	var addresses []string
+	for _, subnet := range subnets {
+		r, err := helper.ParseIPRange(subnet)
+		if err != nil {
+			return nil, err
+		}
+		if limits.sample > 0 {
+			addresses = append(addresses, r.Sample(limits.sample, limits.rng)...)
+			continue
+		}
+		expanded, err := r.Expand(limits.max)
+		if err != nil {
+			return nil, fmt.Errorf("subnet %s: %w", subnet, err)
+		}
+		addresses = append(addresses, expanded...)
+	}
+	return scraper.UniqueTargets(addresses), nil
+}
+
+// targetOverrides holds the per-target settings read from a JSON Lines
+// targets file, keyed by host.
+type targetOverrides struct {
+	ports    map[string]int
+	sni      map[string]string
+	timeouts map[string]time.Duration
+	startTLS map[string]scraper.StartTLS
+}
+
+// jsonlTargets turns the targets of a JSON Lines file into the websites to
+// scan and their overrides. Targets that can't be scanned as given, such as
+// those naming an unknown STARTTLS protocol or listing a host again with
+// different overrides, are left out and reported, so that one bad target
+// doesn't stop the rest.
+func jsonlTargets(targets []helper.Target) ([]string, targetOverrides, []error) {
+	var websites []string
+	var errs []error
+	overrides := targetOverrides{
+		ports:    make(map[string]int),
+		sni:      make(map[string]string),
+		timeouts: make(map[string]time.Duration),
+		startTLS: make(map[string]scraper.StartTLS),
+	}
+	seen := make(map[string]helper.Target, len(targets))
+	for _, target := range targets {
+		startTLS, err := scraper.ParseStartTLS(target.StartTLS)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Host, err))
+			continue
+		}
+		if listed, ok := seen[target.Host]; ok {
+			if listed != target {
+				errs = append(errs, fmt.Errorf("%s is listed more than once with different overrides", target.Host))
+			}
+			continue
+		}
+		seen[target.Host] = target
+		websites = append(websites, target.Host)
+		if target.Port != 0 {
+			overrides.ports[target.Host] = target.Port
+		}
+		if target.SNI != "" {
+			overrides.sni[target.Host] = target.SNI
+		}
+		if target.Timeout != 0 {
+			overrides.timeouts[target.Host] = target.Timeout
+		}
+		if target.StartTLS != "" {
+			overrides.startTLS[target.Host] = startTLS
+		}
+	}
+	return websites, overrides, errs
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/scotta01/tls-scrape/internal/helper"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandWWW(t *testing.T) {
+	tests := []struct {
+		name     string
+		websites []string
+		expected []string
+	}{
+		{"apex", []string{"example.com"}, []string{"example.com", "www.example.com"}},
+		{"www", []string{"www.example.com"}, []string{"www.example.com", "example.com"}},
+		{"both present", []string{"example.com", "WWW.example.com"}, []string{"example.com", "WWW.example.com"}},
+		{"duplicates", []string{"example.com", "example.org", "www.example.org"}, []string{"example.com", "www.example.com", "example.org", "www.example.org"}},
+		{"ip address", []string{"192.0.2.1"}, []string{"192.0.2.1"}},
+		{"port", []string{"example.com:8443"}, []string{"example.com:8443", "www.example.com:8443"}},
+		{"ip address with port", []string{"192.0.2.1:443"}, []string{"192.0.2.1:443"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandWWW(tt.websites); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTargetWebsites(t *testing.T) {
+	websites := targetWebsites([]helper.Target{
+		{Host: "example.com"},
+		{Host: "mail.example.com", Port: 993},
+		{Host: "example.com", Annotation: "listed twice"},
+		{Host: "app.example.com", Port: 443},
+		{Host: "app.example.com", Port: 8443},
+		{Host: "2001:db8::1", Port: 443},
+	})
+	expected := []string{"example.com", "mail.example.com:993", "app.example.com:443", "app.example.com:8443", "[2001:db8::1]:443"}
+	if !reflect.DeepEqual(websites, expected) {
+		t.Errorf("expected websites %v, got %v", expected, websites)
+	}
+}
+
+func TestExpandSubnets(t *testing.T) {
+	addresses, err := expandSubnets([]string{"192.0.2.0/31", "192.0.2.1-192.0.2.2", "::ffff:192.0.2.2", "2001:db8::1"}, subnetLimits{max: helper.MaxRangeSize})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if expected := []string{"192.0.2.0", "192.0.2.1", "192.0.2.2", "2001:db8::1"}; !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected %v, got %v", expected, addresses)
+	}
+
+	if _, err := expandSubnets([]string{"192.0.2.0/24", "192.0.2.9-192.0.2.1"}, subnetLimits{max: helper.MaxRangeSize}); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+	for _, subnet := range []string{"192.0.2.0/33", "not-a-subnet", "192.0.2.1-2001:db8::1"} {
+		if _, err := expandSubnets([]string{subnet}, subnetLimits{max: helper.MaxRangeSize}); err == nil {
+			t.Errorf("expected an error for %q", subnet)
+		}
+	}
+
+	_, err = expandSubnets([]string{"2001:db8::/64"}, subnetLimits{max: helper.MaxRangeSize})
+	if expected := "subnet 2001:db8::/64: range 2001:db8::-2001:db8::ffff:ffff:ffff:ffff has 18446744073709551616 addresses, more than the limit of 65536"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	limits := subnetLimits{max: helper.MaxRangeSize, sample: 100, rng: rand.New(rand.NewSource(1))}
+	addresses, err = expandSubnets([]string{"2001:db8::/64", "192.0.2.0/30"}, limits)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(addresses) != 104 {
+		t.Errorf("expected 100 sampled addresses and the 4 of the small range, got %d", len(addresses))
+	}
+}
+
+func TestJSONLTargets(t *testing.T) {
+	targets := []helper.Target{
+		{Host: "example.com"},
+		{Host: "api.example.com", Port: 8443, Timeout: 5 * time.Second},
+		{Host: "192.0.2.10", SNI: "www.example.com"},
+		{Host: "mail.example.com", Port: 25, StartTLS: "smtp"},
+		{Host: "ldap.example.com", StartTLS: "ldap"},
+		{Host: "example.com"},
+		{Host: "api.example.com", Port: 9443},
+		{Host: "edge.example.com", Port: 443, SNI: "cdn.example.com", Timeout: time.Second},
+	}
+
+	websites, overrides, errs := jsonlTargets(targets)
+	expected := []string{"example.com", "api.example.com", "192.0.2.10", "mail.example.com", "edge.example.com"}
+	if !reflect.DeepEqual(websites, expected) {
+		t.Errorf("expected websites %v, got %v", expected, websites)
+	}
+	if expected := map[string]int{"api.example.com": 8443, "mail.example.com": 25, "edge.example.com": 443}; !reflect.DeepEqual(overrides.ports, expected) {
+		t.Errorf("expected ports %v, got %v", expected, overrides.ports)
+	}
+	if expected := map[string]string{"192.0.2.10": "www.example.com", "edge.example.com": "cdn.example.com"}; !reflect.DeepEqual(overrides.sni, expected) {
+		t.Errorf("expected SNI overrides %v, got %v", expected, overrides.sni)
+	}
+	if expected := map[string]time.Duration{"api.example.com": 5 * time.Second, "edge.example.com": time.Second}; !reflect.DeepEqual(overrides.timeouts, expected) {
+		t.Errorf("expected timeouts %v, got %v", expected, overrides.timeouts)
+	}
+	if expected := map[string]scraper.StartTLS{"mail.example.com": scraper.StartTLSSMTP}; !reflect.DeepEqual(overrides.startTLS, expected) {
+		t.Errorf("expected STARTTLS protocols %v, got %v", expected, overrides.startTLS)
+	}
+
+	// The unknown STARTTLS protocol and the conflicting repeat of
+	// api.example.com are reported; the identical repeat of example.com is
+	// not.
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "ldap.example.com") || !strings.Contains(errs[1].Error(), "api.example.com") {
+		t.Errorf("expected errors for ldap.example.com and api.example.com, got %v", errs)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"log"
+	"sort"
+	"time"
+)
+
+// domainState is the outcome of a scan for a single domain, kept between
+// watch runs so that changes can be reported.
+type domainState struct {
+	serial   string
+	valid    bool
+	notAfter time.Time
+	err      string
+}
+
+// snapshot reduces the results of a scan to the state of each domain.
+func snapshot(details []*scraper.CertDetails, errs map[string]error) map[string]domainState {
+	states := make(map[string]domainState, len(details)+len(errs))
+	for _, detail := range details {
+		state := domainState{serial: detail.Serial, valid: detail.Valid}
+		if len(detail.CertChain) > 0 {
+			state.notAfter = detail.GetLeafCert().NotAfter
+		}
+		states[detail.Domain] = state
+	}
+	for domain, err := range errs {
+		states[domain] = domainState{err: err.Error()}
+	}
+	return states
+}
+
+// diffSnapshots describes what changed between two watch runs: new errors,
+// recoveries, certificate rotations, validity changes and certificates that
+// have newly entered the expiry warning window.
+func diffSnapshots(prev, curr map[string]domainState, expiryWarning time.Duration, now time.Time) []string {
+	domains := make([]string, 0, len(curr))
+	for domain := range curr {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	var changes []string
+	for _, domain := range domains {
+		c := curr[domain]
+		p, seen := prev[domain]
+		if !seen {
+			continue
+		}
+
+		switch {
+		case c.err != "" && p.err == "":
+			changes = append(changes, fmt.Sprintf("domain %s started failing: %s", domain, c.err))
+			continue
+		case c.err == "" && p.err != "":
+			changes = append(changes, fmt.Sprintf("domain %s recovered", domain))
+		case c.err != "":
+			continue
+		}
+
+		if p.serial != "" && c.serial != p.serial {
+			changes = append(changes, fmt.Sprintf("domain %s rotated certificate from serial %s to %s", domain, p.serial, c.serial))
+		}
+		if p.err == "" && c.valid != p.valid {
+			changes = append(changes, fmt.Sprintf("domain %s certificate validity changed to %t", domain, c.valid))
+		}
+
+		deadline := now.Add(expiryWarning)
+		if !c.notAfter.IsZero() && c.notAfter.Before(deadline) && (p.notAfter.IsZero() || !p.notAfter.Before(deadline)) {
+			changes = append(changes, fmt.Sprintf("domain %s certificate expires within %s at %s", domain, expiryWarning, c.notAfter))
+		}
+	}
+	return changes
+}
+
+// watch calls scan every interval and logs what changed since the previous
+// run, until ctx is cancelled. Scans run back to back on the calling
+// goroutine, so a scan that takes longer than the interval delays the next
+// one instead of overlapping it.
+func watch(ctx context.Context, interval, expiryWarning time.Duration, scan func() ([]*scraper.CertDetails, map[string]error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev map[string]domainState
+	for {
+		curr := snapshot(scan())
+		if prev != nil {
+			for _, change := range diffSnapshots(prev, curr, expiryWarning, time.Now()) {
+				log.Printf("tls-scrape change: %s", change)
+			}
+		}
+		prev = curr
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	farExpiry := now.Add(365 * 24 * time.Hour)
+	nearExpiry := now.Add(10 * 24 * time.Hour)
+
+	prev := map[string]domainState{
+		"failing.example.com":   {serial: "1", valid: true, notAfter: farExpiry},
+		"recovered.example.com": {err: "dial tcp: connection refused"},
+		"rotated.example.com":   {serial: "1", valid: true, notAfter: farExpiry},
+		"expiring.example.com":  {serial: "1", valid: true, notAfter: nearExpiry.Add(30 * 24 * time.Hour)},
+		"steady.example.com":    {serial: "1", valid: true, notAfter: farExpiry},
+	}
+	curr := map[string]domainState{
+		"failing.example.com":   {err: "dial tcp: i/o timeout"},
+		"recovered.example.com": {serial: "2", valid: true, notAfter: farExpiry},
+		"rotated.example.com":   {serial: "2", valid: true, notAfter: farExpiry},
+		"expiring.example.com":  {serial: "1", valid: true, notAfter: nearExpiry},
+		"steady.example.com":    {serial: "1", valid: true, notAfter: farExpiry},
+		"new.example.com":       {serial: "1", valid: true, notAfter: nearExpiry},
+	}
+
+	changes := diffSnapshots(prev, curr, 30*24*time.Hour, now)
+	expected := []string{
+		"domain expiring.example.com certificate expires within 720h0m0s at " + nearExpiry.String(),
+		"domain failing.example.com started failing: dial tcp: i/o timeout",
+		"domain recovered.example.com recovered",
+		"domain rotated.example.com rotated certificate from serial 1 to 2",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v \n got %v", expected, changes)
+	}
+}
@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"io"
+)
+
+// WriteConnectionState writes the fields of a TLS connection state that are
+// most useful when debugging a handshake to w, one "Name: value" line each.
+func WriteConnectionState(w io.Writer, state tls.ConnectionState) error {
+	protocol := state.NegotiatedProtocol
+	if protocol == "" {
+		protocol = "none"
+	}
+	serverName := state.ServerName
+	if serverName == "" {
+		serverName = "none"
+	}
+	_, err := fmt.Fprintf(w, "Version: %s\nCipher suite: %s\nNegotiated protocol: %s\nServer name: %s\nResumed: %t\nPeer certificates: %d\n",
+		scraper.TLSVersionName(state.Version),
+		tls.CipherSuiteName(state.CipherSuite),
+		protocol,
+		serverName,
+		state.DidResume,
+		len(state.PeerCertificates),
+	)
+	return err
+}
@@ -0,0 +1,41 @@
+package helper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func TestWriteConnectionState(t *testing.T) {
+	var buf strings.Builder
+	state := tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		NegotiatedProtocol: "h2",
+		ServerName:         "example.com",
+		DidResume:          true,
+		PeerCertificates:   []*x509.Certificate{{}, {}},
+	}
+	if err := WriteConnectionState(&buf, state); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	expected := "Version: TLS 1.3\n" +
+		"Cipher suite: TLS_AES_128_GCM_SHA256\n" +
+		"Negotiated protocol: h2\n" +
+		"Server name: example.com\n" +
+		"Resumed: true\n" +
+		"Peer certificates: 2\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteConnectionState(&buf, tls.ConnectionState{Version: tls.VersionTLS12}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Negotiated protocol: none\nServer name: none\n") {
+		t.Errorf("expected unset fields to read none, got:\n%s", buf.String())
+	}
+}
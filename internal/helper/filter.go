@@ -0,0 +1,38 @@
+package helper
+
+import "github.com/scotta01/tls-scrape/pkg/scraper"
+
+// FilterOptions selects the certificates worth reporting. The zero value
+// selects every certificate. When more than one filter is set, a certificate
+// must pass all of them.
+type FilterOptions struct {
+	// OnlyInvalid selects certificates that failed validation.
+	OnlyInvalid bool
+
+	// ExpiringWithin, when positive, selects certificates that expire
+	// within this many days, including those already expired.
+	ExpiringWithin int
+}
+
+// Match reports whether detail passes the filters.
+func (opts FilterOptions) Match(detail *scraper.CertDetails) bool {
+	if opts.OnlyInvalid && detail.Valid {
+		return false
+	}
+	if opts.ExpiringWithin > 0 && detail.DaysUntilExpiry > opts.ExpiringWithin {
+		return false
+	}
+	return true
+}
+
+// FilterCertDetails returns the details that pass the filters, in their
+// original order. details itself is left unchanged.
+func FilterCertDetails(details []*scraper.CertDetails, opts FilterOptions) []*scraper.CertDetails {
+	filtered := make([]*scraper.CertDetails, 0, len(details))
+	for _, detail := range details {
+		if opts.Match(detail) {
+			filtered = append(filtered, detail)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"reflect"
+	"testing"
+)
+
+func TestFilterCertDetails(t *testing.T) {
+	details := []*scraper.CertDetails{
+		{Domain: "valid-later.example.com", Valid: true, DaysUntilExpiry: 90},
+		{Domain: "valid-soon.example.com", Valid: true, DaysUntilExpiry: 10},
+		{Domain: "invalid-later.example.com", Valid: false, DaysUntilExpiry: 200},
+		{Domain: "invalid-soon.example.com", Valid: false, DaysUntilExpiry: 30},
+		{Domain: "expired.example.com", Valid: false, DaysUntilExpiry: -3},
+	}
+
+	tests := []struct {
+		name     string
+		opts     FilterOptions
+		expected []string
+	}{
+		{"no filters", FilterOptions{}, []string{"valid-later.example.com", "valid-soon.example.com", "invalid-later.example.com", "invalid-soon.example.com", "expired.example.com"}},
+		{"only invalid", FilterOptions{OnlyInvalid: true}, []string{"invalid-later.example.com", "invalid-soon.example.com", "expired.example.com"}},
+		{"expiring within", FilterOptions{ExpiringWithin: 30}, []string{"valid-soon.example.com", "invalid-soon.example.com", "expired.example.com"}},
+		{"combined", FilterOptions{OnlyInvalid: true, ExpiringWithin: 30}, []string{"invalid-soon.example.com", "expired.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, detail := range FilterCertDetails(details, tt.opts) {
+				got = append(got, detail.Domain)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+	if len(details) != 5 {
+		t.Errorf("expected the input to be left unchanged, got %d details", len(details))
+	}
+}
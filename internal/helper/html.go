@@ -0,0 +1,128 @@
+package helper
+
+import (
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"html/template"
+	"io"
+	"time"
+)
+
+// htmlReport is the data rendered by reportTemplate.
+type htmlReport struct {
+	Generated time.Time
+	Summary   scraper.ScanSummary
+	Rows      []htmlRow
+}
+
+// htmlRow is a single certificate in the HTML report.
+type htmlRow struct {
+	Domain   string
+	Valid    bool
+	Errors   []scraper.ValidationError
+	NotAfter string
+	Expiry   time.Time
+	DaysLeft int
+	Issuer   string
+}
+
+// ExpiryClass returns the CSS class used to colour the expiry column.
+func (r htmlRow) ExpiryClass() string {
+	switch {
+	case r.DaysLeft < 0:
+		return "expired"
+	case r.DaysLeft < 30:
+		return "expiring"
+	default:
+		return "ok"
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>TLS Scrape Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.6em; text-align: left; }
+th { background: #f0f0f0; cursor: pointer; }
+.summary td { border: none; padding: 0.2em 1em 0.2em 0; }
+.expired { background: #f8d7da; }
+.expiring { background: #fff3cd; }
+.ok { background: #d4edda; }
+.invalid { color: #a00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>TLS Scrape Report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+<table class="summary">
+<tr><td>Total</td><td>{{.Summary.Total}}</td></tr>
+<tr><td>Succeeded</td><td>{{.Summary.Succeeded}}</td></tr>
+<tr><td>Failed</td><td>{{.Summary.Failed}}</td></tr>
+<tr><td>Valid</td><td>{{.Summary.Valid}}</td></tr>
+<tr><td>Invalid</td><td>{{.Summary.Invalid}}</td></tr>
+<tr><td>Elapsed</td><td>{{.Summary.Elapsed}}</td></tr>
+{{- if .Summary.EarliestExpiryDomain}}
+<tr><td>Earliest expiry</td><td>{{.Summary.EarliestExpiryDomain}} ({{.Summary.EarliestExpiry.Format "2006-01-02"}})</td></tr>
+{{- end}}
+</table>
+<table id="results">
+<thead>
+<tr><th>Domain</th><th>Valid</th><th>Expiry</th><th>Days left</th><th>Issuer</th></tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr>
+<td>{{.Domain}}</td>
+<td{{if not .Valid}} class="invalid"{{end}}>{{if .Valid}}yes{{else}}no{{range .Errors}}<br>{{.Code}}{{end}}{{end}}</td>
+<td class="{{.ExpiryClass}}" data-sort="{{.Expiry.Unix}}">{{.NotAfter}}</td>
+<td class="{{.ExpiryClass}}" data-sort="{{.DaysLeft}}">{{.DaysLeft}}</td>
+<td>{{.Issuer}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+document.querySelectorAll("#results th").forEach(function (th, column) {
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#results tbody");
+    var ascending = th.dataset.order !== "asc";
+    th.dataset.order = ascending ? "asc" : "desc";
+    var key = function (row) {
+      var cell = row.children[column];
+      return cell.dataset.sort !== undefined ? Number(cell.dataset.sort) : cell.textContent.toLowerCase();
+    };
+    Array.from(tbody.rows).sort(function (a, b) {
+      var x = key(a), y = key(b);
+      return (x < y ? -1 : x > y ? 1 : 0) * (ascending ? 1 : -1);
+    }).forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// WriteHTML renders a self-contained HTML report of the scraped certificates
+// and the scan summary to w.
+func WriteHTML(w io.Writer, details []*scraper.CertDetails, summary scraper.ScanSummary) error {
+	now := time.Now()
+	report := htmlReport{Generated: now, Summary: summary}
+	for _, detail := range details {
+		row := htmlRow{
+			Domain:   detail.Domain,
+			Valid:    detail.Valid,
+			Errors:   detail.ValidationErrors,
+			NotAfter: detail.NotAfter,
+			Issuer:   detail.Issuer,
+		}
+		if len(detail.CertChain) > 0 {
+			row.Expiry = detail.GetLeafCert().NotAfter
+			row.DaysLeft = int(row.Expiry.Sub(now).Hours() / 24)
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return reportTemplate.Execute(w, report)
+}
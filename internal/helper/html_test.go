@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"bytes"
+	"crypto/x509"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHTML(t *testing.T) {
+	details := []*scraper.CertDetails{
+		{
+			Domain:    "expired.example.com",
+			Issuer:    "CN=<Test CA>",
+			CertChain: []*x509.Certificate{{NotAfter: time.Now().Add(-48 * time.Hour)}},
+			ValidationErrors: []scraper.ValidationError{
+				{Code: scraper.CodeExpired, Message: "certificate expired"},
+			},
+		},
+		{
+			Domain:    "ok.example.com",
+			Valid:     true,
+			CertChain: []*x509.Certificate{{NotAfter: time.Now().Add(365 * 24 * time.Hour)}},
+		},
+	}
+	summary := scraper.Summarize(details, nil, time.Second)
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, details, summary); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	report := buf.String()
+
+	for _, want := range []string{
+		"expired.example.com",
+		"ok.example.com",
+		`class="expired"`,
+		`class="ok"`,
+		scraper.CodeExpired,
+		"CN=&lt;Test CA&gt;",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected report to contain %q", want)
+		}
+	}
+}
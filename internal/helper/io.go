@@ -1,65 +1,502 @@
 package helper
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"io"
 	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func ReadCSV(filename string, csvheader string) ([]string, error) {
+	websites, _, err := ReadCSVWithMetadata(filename, csvheader, nil)
+	return websites, err
+}
+
+// ReadCSVWithMetadata reads the websites in the csvheader column like ReadCSV,
+// and for each website the values of the metaColumns, keyed by column name.
+// When a website appears on more than one row, the first row's values are
+// used.
+func ReadCSVWithMetadata(filename string, csvheader string, metaColumns []string) ([]string, map[string]map[string]string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	lines, err := reader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Return an error if the CSV is empty
 	if len(lines) == 0 {
-		return nil, errors.New("empty CSV file")
+		return nil, nil, errors.New("empty CSV file")
 	}
 
-	// Identify the column index based on the header
+	// Identify the column indexes based on the header
 	colIndex := -1
+	metaIndexes := make(map[string]int, len(metaColumns))
 	for index, header := range lines[0] {
-		if header == csvheader {
+		if header == csvheader && colIndex == -1 {
 			colIndex = index
-			break
+		}
+		for _, column := range metaColumns {
+			if header == column {
+				metaIndexes[column] = index
+			}
 		}
 	}
 
-	// Return an error if the column header isn't found
+	// Return an error if a column header isn't found
 	if colIndex == -1 {
-		return nil, fmt.Errorf("column header '%s' not found", csvheader)
+		return nil, nil, fmt.Errorf("column header '%s' not found", csvheader)
+	}
+	for _, column := range metaColumns {
+		if _, ok := metaIndexes[column]; !ok {
+			return nil, nil, fmt.Errorf("column header '%s' not found", column)
+		}
 	}
 
 	var websites []string
+	var metadata map[string]map[string]string
+	if len(metaColumns) > 0 {
+		metadata = make(map[string]map[string]string)
+	}
 	// Start from index 1 to skip the header row
 	for _, line := range lines[1:] {
-		if len(line) > colIndex {
-			websites = append(websites, line[colIndex])
+		if len(line) <= colIndex {
+			continue
+		}
+		website := line[colIndex]
+		websites = append(websites, website)
+
+		if metadata == nil {
+			continue
+		}
+		if _, seen := metadata[website]; seen {
+			continue
+		}
+		values := make(map[string]string, len(metaIndexes))
+		for column, index := range metaIndexes {
+			if index < len(line) {
+				values[column] = line[index]
+			}
+		}
+		metadata[website] = values
+	}
+	return websites, metadata, nil
+}
+
+// ReadCSVTargets reads the websites in the csvheader column like
+// ReadCSVWithMetadata, parsing each as a host with an optional port, e.g.
+// example.com:8443. The metadata is keyed by host.
+func ReadCSVTargets(filename string, csvheader string, metaColumns []string) ([]Target, map[string]map[string]string, error) {
+	websites, metadata, err := ReadCSVWithMetadata(filename, csvheader, metaColumns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := make([]Target, 0, len(websites))
+	var hostMetadata map[string]map[string]string
+	if metadata != nil {
+		hostMetadata = make(map[string]map[string]string, len(metadata))
+	}
+	for i, website := range websites {
+		target, err := parseTarget(strings.TrimSpace(website))
+		if err != nil {
+			// Row 1 is the header.
+			return nil, nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+		targets = append(targets, target)
+		if _, seen := hostMetadata[target.Host]; metadata != nil && !seen {
+			hostMetadata[target.Host] = metadata[website]
+		}
+	}
+	return targets, hostMetadata, nil
+}
+
+// ReadPinsFile reads per-domain certificate pins. Each non-empty line holds a
+// domain followed by one or more whitespace separated "sha256:HEX" pins.
+// Lines starting with # are ignored.
+func ReadPinsFile(filename string) (map[string][]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pins := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a domain followed by at least one pin", lineNumber)
+		}
+		for _, pin := range fields[1:] {
+			if _, err := scraper.NormalizePin(pin); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+		}
+		pins[fields[0]] = append(pins[fields[0]], fields[1:]...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// ReadDistrustFile reads the fingerprints of distrusted roots. Each non-empty
+// line holds a "sha256:HEX" fingerprint, optionally followed by whitespace and
+// a description such as the name of the CA. Lines starting with # are ignored.
+func ReadDistrustFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fingerprints []string
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fingerprint := strings.Fields(line)[0]
+		if _, err := scraper.NormalizePin(fingerprint); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fingerprints, nil
+}
+
+// ReadCABundle reads a bundle of PEM encoded root certificates into a pool.
+// With system set, the certificates are added to a copy of the system pool
+// rather than replacing it.
+func ReadCABundle(filename string, system bool) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if system {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return nil, fmt.Errorf("loading system roots: %w", err)
+		}
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", filename)
+	}
+	return pool, nil
+}
+
+// Target is a host to scan, as read from a hosts file.
+type Target struct {
+	Host string
+
+	// Port is the port given for the host, or 0 when the default port should
+	// be used.
+	Port int
+
+	// Annotation is any text following the host after a tab.
+	Annotation string
+
+	// SNI is the server name to request instead of the host, StartTLS the
+	// protocol to upgrade with before the handshake, and Timeout the dial
+	// timeout for the host. They are only set by ReadTargetsJSONL.
+	SNI      string
+	StartTLS string
+	Timeout  time.Duration
+}
+
+// ReadJSON reads host names from a JSON file holding either an array of
+// strings, e.g. ["example.com","192.0.2.10"], or an array of objects with a
+// host field, e.g. [{"host":"example.com"}], as emitted by other tools. Other
+// fields of the objects are ignored.
+func ReadJSON(filename string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("expected an array of host names or of objects with a host: %w", err)
+	}
+
+	hosts := make([]string, 0, len(elements))
+	for i, element := range elements {
+		var host string
+		if err := json.Unmarshal(element, &host); err != nil {
+			var object struct {
+				Host string `json:"host"`
+			}
+			if err := json.Unmarshal(element, &object); err != nil {
+				return nil, fmt.Errorf("element %d: expected a host name or an object with a host, got %s", i, element)
+			}
+			host = object.Host
+		}
+		host = strings.TrimSpace(host)
+		if host == "" {
+			return nil, fmt.Errorf("element %d: missing host", i)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// ReadHostsFile reads targets from a hosts.txt style file: one host or
+// host:port per line, optionally followed by a tab and a free text
+// annotation. Blank lines and lines starting with # are ignored. IPv6
+// addresses with a port must be written in brackets, e.g. [2001:db8::1]:8443.
+func ReadHostsFile(filename string) ([]Target, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []Target
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hostPort, annotation, _ := strings.Cut(line, "\t")
+		target, err := parseTarget(strings.TrimSpace(hostPort))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		target.Annotation = strings.TrimSpace(annotation)
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// parseTarget parses a host with an optional port.
+func parseTarget(hostPort string) (Target, error) {
+	if strings.ContainsAny(hostPort, " \t") {
+		return Target{}, fmt.Errorf("unexpected whitespace in %q, annotations must follow a tab", hostPort)
+	}
+	// A bare IPv6 address has more than one colon and no port.
+	if !strings.HasPrefix(hostPort, "[") && strings.Count(hostPort, ":") != 1 {
+		return Target{Host: hostPort}, nil
+	}
+	host, portString, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return Target{}, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil || port < 1 || port > 65535 {
+		return Target{}, fmt.Errorf("invalid port %q", portString)
+	}
+	return Target{Host: host, Port: port}, nil
+}
+
+// jsonTarget is a line of a JSON Lines targets file.
+type jsonTarget struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	SNI      string `json:"sni"`
+	StartTLS string `json:"starttls"`
+	Timeout  string `json:"timeout"`
+}
+
+// ReadTargetsJSONL reads targets from a JSON Lines file, where each line is
+// an object with a host and optional per-target overrides, e.g.
+// {"host":"example.com","port":8443,"sni":"www.example.com","timeout":"5s"}.
+// Each line is parsed on its own, so a malformed line is reported in the
+// returned line errors without failing the rest of the file. Blank lines and
+// lines starting with # are ignored. Only failing to read the file at all is
+// returned as an error.
+func ReadTargetsJSONL(filename string) ([]Target, []error, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var targets []Target
+	var lineErrs []error
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target, err := parseJSONTarget(line)
+		if err != nil {
+			lineErrs = append(lineErrs, fmt.Errorf("line %d: %w", lineNumber, err))
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return targets, lineErrs, nil
+}
+
+// parseJSONTarget parses a single line of a JSON Lines targets file.
+func parseJSONTarget(line string) (Target, error) {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	// Catch misspelt overrides rather than silently ignoring them.
+	decoder.DisallowUnknownFields()
+	var jt jsonTarget
+	if err := decoder.Decode(&jt); err != nil {
+		return Target{}, err
+	}
+	if decoder.More() {
+		return Target{}, errors.New("unexpected data after the target object")
+	}
+
+	target := Target{
+		Host:     strings.TrimSpace(jt.Host),
+		Port:     jt.Port,
+		SNI:      strings.TrimSpace(jt.SNI),
+		StartTLS: strings.ToLower(strings.TrimSpace(jt.StartTLS)),
+	}
+	if target.Host == "" {
+		return Target{}, errors.New("missing host")
+	}
+	if target.Port < 0 || target.Port > 65535 {
+		return Target{}, fmt.Errorf("invalid port %d", target.Port)
+	}
+	if jt.Timeout != "" {
+		timeout, err := time.ParseDuration(jt.Timeout)
+		if err != nil {
+			return Target{}, fmt.Errorf("invalid timeout: %w", err)
 		}
+		if timeout <= 0 {
+			return Target{}, fmt.Errorf("invalid timeout %q, must be positive", jt.Timeout)
+		}
+		target.Timeout = timeout
 	}
-	return websites, nil
+	return target, nil
 }
 
 func WriteJSON(directory string, details *scraper.CertDetails, prettyPrint bool) error {
+	return writeDetailsJSON(directory, details, details, prettyPrint)
+}
+
+// minimalKeys are the keys WriteMinimalJSON always writes, even when their
+// value is empty.
+var minimalKeys = map[string]bool{
+	"domain":                 true,
+	"serial":                 true,
+	"not_before":             true,
+	"not_after":              true,
+	"issuer":                 true,
+	"valid":                  true,
+	"lifetime_remaining_pct": true,
+}
+
+// WriteMinimalJSON writes details like WriteJSON, but leaves out every empty
+// or zero value, at any depth, to keep records small for large scans. The keys
+// in minimalKeys are always written. Keys are written in alphabetical order.
+func WriteMinimalJSON(directory string, details *scraper.CertDetails, prettyPrint bool) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value map[string]interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	for key, v := range value {
+		if !minimalKeys[key] && isEmptyJSON(pruneJSON(v)) {
+			delete(value, key)
+		}
+	}
+	return writeDetailsJSON(directory, details, value, prettyPrint)
+}
+
+// pruneJSON removes empty values from the objects within a decoded JSON value
+// and returns it. Array elements are pruned but never removed, as their
+// position may matter.
+func pruneJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isEmptyJSON(pruneJSON(child)) {
+				delete(v, key)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = pruneJSON(child)
+		}
+	}
+	return value
+}
+
+// zeroTimeJSON is how the zero time.Time is encoded in JSON.
+const zeroTimeJSON = "0001-01-01T00:00:00Z"
+
+// isEmptyJSON reports whether a decoded JSON value is null, false, zero, an
+// empty string or zero time, or an empty array or object.
+func isEmptyJSON(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == "" || v == zeroTimeJSON
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// writeDetailsJSON writes value as the JSON file for details.
+func writeDetailsJSON(directory string, details *scraper.CertDetails, value interface{}, prettyPrint bool) error {
 	var data []byte
 	var err error
 
 	if prettyPrint {
-		data, err = json.MarshalIndent(details, "", "  ")
+		data, err = json.MarshalIndent(value, "", "  ")
 	} else {
-		data, err = json.Marshal(details)
+		data, err = json.Marshal(value)
 	}
 
 	if err != nil {
@@ -68,13 +505,194 @@ func WriteJSON(directory string, details *scraper.CertDetails, prettyPrint bool)
 	// Add a newline to the end of the file so that commands like tail can read it.
 	data = append(data, '\n')
 	filename := fmt.Sprintf("%s/%s.json", directory, details.Domain)
-	err = os.WriteFile(filename, data, 0644)
+	return writeFile(filename, data)
+}
+
+// BundleSchemaVersion is the version of the JSON written by WriteBundle,
+// recorded in each bundle so that consumers can tell when it changes. Bump it
+// whenever the fields of Bundle or scraper.CertDetails change.
+const BundleSchemaVersion = "1"
+
+// Bundle is the JSON object written by WriteBundle.
+type Bundle struct {
+	// SchemaVersion is set to BundleSchemaVersion when the bundle is written.
+	SchemaVersion string `json:"schema_version"`
+
+	// GeneratedAt is when the scan that produced the results started.
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// Config is the configuration the results were scanned with.
+	Config interface{} `json:"config,omitempty"`
+
+	Results []*scraper.CertDetails `json:"results"`
+}
+
+// WriteBundle writes every result in bundle, whether from a domain or an IP
+// address target, to bundle.json in the given directory, along with the scan
+// configuration.
+func WriteBundle(directory string, bundle Bundle, prettyPrint bool) error {
+	return writeBundle(fmt.Sprintf("%s/bundle.json", directory), bundle, prettyPrint)
+}
+
+// writeBundle writes bundle to filename, stamped with the schema version.
+func writeBundle(filename string, bundle Bundle, prettyPrint bool) error {
+	bundle.SchemaVersion = BundleSchemaVersion
+	if bundle.Results == nil {
+		bundle.Results = []*scraper.CertDetails{}
+	}
+
+	var data []byte
+	var err error
+
+	if prettyPrint {
+		data, err = json.MarshalIndent(bundle, "", "  ")
+	} else {
+		data, err = json.Marshal(bundle)
+	}
+
 	if err != nil {
 		return err
 	}
+	data = append(data, '\n')
+	return writeFile(filename, data)
+}
+
+// csvHeader names the columns written by WriteCSV.
+var csvHeader = []string{"domain", "serial", "not_before", "not_after", "issuer", "valid", "validation_errors", "crl", "ocsp"}
+
+// WriteCSV writes one row per certificate to results.csv in the given
+// directory, for fleet reports in a spreadsheet. Validation errors are joined
+// with "; ", and CRL and OCSP URLs with spaces.
+func WriteCSV(directory string, details []*scraper.CertDetails) error {
+	return WriteFileAtomic(fmt.Sprintf("%s/results.csv", directory), func(w io.Writer) error {
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, detail := range details {
+			validationErrors := make([]string, 0, len(detail.ValidationErrors))
+			for _, ve := range detail.ValidationErrors {
+				validationErrors = append(validationErrors, ve.Error())
+			}
+			err := writer.Write([]string{
+				detail.Domain,
+				detail.Serial,
+				detail.NotBefore,
+				detail.NotAfter,
+				detail.Issuer,
+				strconv.FormatBool(detail.Valid),
+				strings.Join(validationErrors, "; "),
+				strings.Join(detail.CRL, " "),
+				strings.Join(detail.OCSPServer, " "),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+// WriteProbeResults writes each probe result to w as a line of JSON.
+func WriteProbeResults(w io.Writer, results []*scraper.ProbeResult) error {
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// streamError is written in place of the details of a target that could not
+// be scraped.
+type streamError struct {
+	Domain string `json:"domain"`
+	Error  string `json:"error"`
+}
+
+// WriteStreamResult writes the result of scraping a single target to w as a
+// line of JSON, for use with scraper.WithResultHandler: the certificate
+// details, or the domain and error if the scrape failed. The line is written
+// with a single call to w.
+func WriteStreamResult(w io.Writer, domain string, details *scraper.CertDetails, err error) error {
+	var result interface{} = details
+	if err != nil {
+		result = streamError{Domain: domain, Error: err.Error()}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteSANInventory writes the SAN inventory built by scraper.CollectSANs to
+// san_inventory.json in the given directory.
+func WriteSANInventory(directory string, inventory map[string][]string, prettyPrint bool) error {
+	var data []byte
+	var err error
+
+	if prettyPrint {
+		data, err = json.MarshalIndent(inventory, "", "  ")
+	} else {
+		data, err = json.Marshal(inventory)
+	}
+
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	filename := fmt.Sprintf("%s/san_inventory.json", directory)
+	return writeFile(filename, data)
+}
+
+// writeFile atomically writes data to filename.
+func writeFile(filename string, data []byte) error {
+	return WriteFileAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteFileAtomic creates or replaces filename with the output of write.
+// The output goes to a temporary file in the same directory, which is renamed
+// into place only once write has succeeded, so readers never see a partially
+// written file.
+func WriteFileAtomic(filename string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temporary file unless it has been renamed into place.
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// WriteSANLog logs each SAN in the inventory along with the domains that asserted it.
+func WriteSANLog(inventory map[string][]string) {
+	for _, san := range scraper.SortedSANs(inventory) {
+		log.Printf("tls-scrape SAN:%s AssertedBy:%s", san, strings.Join(inventory[san], ","))
+	}
+}
+
 func WriteLog(details []*scraper.CertDetails) error {
 	var logString []string
 	for _, detail := range details {
@@ -86,7 +704,8 @@ func WriteLog(details []*scraper.CertDetails) error {
 				"NotAfter:%s "+
 				"Issuer:%s "+
 				"CRL:%s "+
-				"OCSPServer:%s ",
+				"OCSPServer:%s "+
+				"Valid:%t ",
 			detail.Domain,
 			detail.Serial,
 			detail.NotBefore,
@@ -94,6 +713,7 @@ func WriteLog(details []*scraper.CertDetails) error {
 			detail.Issuer,
 			detail.CRL,
 			detail.OCSPServer,
+			detail.Valid,
 		))
 	}
 
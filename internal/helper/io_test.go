@@ -0,0 +1,541 @@
+package helper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSVWithMetadata(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "websites.csv")
+	data := "url,owner,team,environment\n" +
+		"example.com,alice,payments,prod\n" +
+		"example.org,bob,search,staging\n" +
+		"example.com,carol,identity,dev\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+
+	websites, metadata, err := ReadCSVWithMetadata(filename, "url", []string{"owner", "team"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(websites) != 3 {
+		t.Errorf("expected 3 websites, got %d", len(websites))
+	}
+
+	expected := map[string]map[string]string{
+		"example.com": {"owner": "alice", "team": "payments"},
+		"example.org": {"owner": "bob", "team": "search"},
+	}
+	for website, values := range expected {
+		for column, value := range values {
+			if got := metadata[website][column]; got != value {
+				t.Errorf("expected %s %s to be %s, got %s", website, column, value, got)
+			}
+		}
+		if _, ok := metadata[website]["environment"]; ok {
+			t.Errorf("expected only the requested columns for %s", website)
+		}
+	}
+
+	if _, _, err := ReadCSVWithMetadata(filename, "url", []string{"missing"}); err == nil {
+		t.Errorf("expected an error for a missing metadata column")
+	}
+
+	websites, metadata, err = ReadCSVWithMetadata(filename, "url", nil)
+	if err != nil || len(websites) != 3 || metadata != nil {
+		t.Errorf("expected websites and no metadata without meta columns, got %v, %v, %v", websites, metadata, err)
+	}
+}
+
+func TestReadCSVTargets(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "websites.csv")
+	data := "url,owner\n" +
+		"example.com,alice\n" +
+		"api.example.com:8443,bob\n" +
+		"[2001:db8::1]:993,carol\n" +
+		"2001:db8::2,dave\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+
+	targets, metadata, err := ReadCSVTargets(filename, "url", []string{"owner"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := []Target{
+		{Host: "example.com"},
+		{Host: "api.example.com", Port: 8443},
+		{Host: "2001:db8::1", Port: 993},
+		{Host: "2001:db8::2"},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+	if owner := metadata["api.example.com"]["owner"]; owner != "bob" {
+		t.Errorf("expected metadata keyed by host, got %v", metadata)
+	}
+
+	if err := os.WriteFile(filename, []byte("url\nexample.com:https\n"), 0644); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+	if _, _, err := ReadCSVTargets(filename, "url", nil); err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("expected an error for row 2, got: %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "example.com.json")
+	if err := os.WriteFile(filename, []byte(`{"domain":"example.com"}`), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// Simulate a crash part way through writing the replacement.
+	err := WriteFileAtomic(filename, func(w io.Writer) error {
+		if _, err := io.WriteString(w, `{"domain":"exa`); err != nil {
+			return err
+		}
+		return errors.New("write interrupted")
+	})
+	if err == nil {
+		t.Fatalf("expected the write error to be returned")
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != `{"domain":"example.com"}` {
+		t.Errorf("expected the original file to be untouched, got %s", data)
+	}
+
+	if err := WriteFileAtomic(filename, func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"domain":"example.org"}`)
+		return err
+	}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != `{"domain":"example.org"}` {
+		t.Errorf("expected the file to be replaced, got %s", data)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestReadHostsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hosts.txt")
+	data := "# production\n" +
+		"example.com\n" +
+		"\n" +
+		"   \n" +
+		"mail.example.com:993\tIMAP, owned by the mail team\n" +
+		"  # indented comment\n" +
+		"[2001:db8::1]:8443\n" +
+		"2001:db8::2\n" +
+		"api.example.com \t  trailing whitespace  \n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write hosts file: %v", err)
+	}
+
+	targets, err := ReadHostsFile(filename)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := []Target{
+		{Host: "example.com"},
+		{Host: "mail.example.com", Port: 993, Annotation: "IMAP, owned by the mail team"},
+		{Host: "2001:db8::1", Port: 8443},
+		{Host: "2001:db8::2"},
+		{Host: "api.example.com", Annotation: "trailing whitespace"},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("expected %d targets, got %d: %v", len(expected), len(targets), targets)
+	}
+	for i, target := range targets {
+		if target != expected[i] {
+			t.Errorf("expected target %d to be %+v, got %+v", i, expected[i], target)
+		}
+	}
+}
+
+func TestReadHostsFileErrors(t *testing.T) {
+	for _, line := range []string{"example.com:https", "example.com:0", "example.com:70000", "example.com annotation"} {
+		filename := filepath.Join(t.TempDir(), "hosts.txt")
+		if err := os.WriteFile(filename, []byte("# header\n"+line+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write hosts file: %v", err)
+		}
+		if _, err := ReadHostsFile(filename); err == nil {
+			t.Errorf("expected an error for %q", line)
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+		wantErr  bool
+	}{
+		{"strings", `["example.com", " 192.0.2.10 "]`, []string{"example.com", "192.0.2.10"}, false},
+		{"objects", `[{"host":"example.com","owner":"web"},{"host":"api.example.com"}]`, []string{"example.com", "api.example.com"}, false},
+		{"mixed", `["example.com",{"host":"api.example.com"}]`, []string{"example.com", "api.example.com"}, false},
+		{"empty", `[]`, []string{}, false},
+		{"malformed", `["example.com",`, nil, true},
+		{"not an array", `{"host":"example.com"}`, nil, true},
+		{"missing host", `[{"name":"example.com"}]`, nil, true},
+		{"wrong type", `[42]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "targets.json")
+			if err := os.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("failed to write targets file: %v", err)
+			}
+			hosts, err := ReadJSON(filename)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected an error: %v, got: %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(hosts, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, hosts)
+			}
+		})
+	}
+}
+
+func TestReadTargetsJSONL(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "targets.jsonl")
+	data := `# fleet
+{"host":"example.com"}
+{"host":"api.example.com","port":8443}
+{"host":"192.0.2.10","sni":"www.example.com","timeout":"5s"}
+
+{"host":"mail.example.com","port":25,"starttls":"SMTP","timeout":"1m"}
+{"host":"broken.example.com",
+{"port":443}
+{"host":"slow.example.com","timeout":"soon"}
+{"host":"negative.example.com","timeout":"-1s"}
+{"host":"typo.example.com","prot":443}
+{"host":"range.example.com","port":70000}
+{"host":"extra.example.com"} {"host":"second.example.com"}
+{"host":"last.example.com","port":853}
+`
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write targets file: %v", err)
+	}
+
+	targets, lineErrs, err := ReadTargetsJSONL(filename)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := []Target{
+		{Host: "example.com"},
+		{Host: "api.example.com", Port: 8443},
+		{Host: "192.0.2.10", SNI: "www.example.com", Timeout: 5 * time.Second},
+		{Host: "mail.example.com", Port: 25, StartTLS: "smtp", Timeout: time.Minute},
+		{Host: "last.example.com", Port: 853},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected targets %+v, got %+v", expected, targets)
+	}
+
+	// Every malformed line is reported, by line number, without stopping
+	// the lines after it from being read.
+	var lines []string
+	for _, e := range lineErrs {
+		line, _, _ := strings.Cut(e.Error(), ":")
+		lines = append(lines, line)
+	}
+	expectedLines := []string{"line 7", "line 8", "line 9", "line 10", "line 11", "line 12", "line 13"}
+	if !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("expected errors for %v, got %v", expectedLines, lineErrs)
+	}
+
+	if _, _, err := ReadTargetsJSONL(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestReadDistrustFile(t *testing.T) {
+	fingerprint := "sha256:" + strings.Repeat("ab", 32)
+	filename := filepath.Join(t.TempDir(), "distrust.txt")
+	data := "# roots distrusted by browsers\n" +
+		fingerprint + "  Example Root CA 2009\n" +
+		"\n" +
+		strings.ToUpper(fingerprint) + "\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write distrust file: %v", err)
+	}
+
+	fingerprints, err := ReadDistrustFile(filename)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(fingerprints) != 2 || fingerprints[0] != fingerprint {
+		t.Errorf("expected 2 fingerprints starting with %s, got %v", fingerprint, fingerprints)
+	}
+
+	if err := os.WriteFile(filename, []byte("sha256:abcd\n"), 0644); err != nil {
+		t.Fatalf("failed to write distrust file: %v", err)
+	}
+	if _, err := ReadDistrustFile(filename); err == nil {
+		t.Errorf("expected an error for an invalid fingerprint")
+	}
+}
+
+func TestReadCABundle(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Private CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("failed to write bundle: %v", err)
+	}
+	pool, err := ReadCABundle(filename, false)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+		t.Errorf("expected the bundled root to be trusted, got: %v", err)
+	}
+
+	if err := os.WriteFile(filename, []byte("not a certificate\n"), 0644); err != nil {
+		t.Fatalf("failed to write bundle: %v", err)
+	}
+	if _, err := ReadCABundle(filename, false); err == nil {
+		t.Error("expected an error for a bundle without certificates")
+	}
+}
+
+func TestWriteMinimalJSON(t *testing.T) {
+	dir := t.TempDir()
+	details := &scraper.CertDetails{
+		Domain:     "example.com",
+		Serial:     "42",
+		CRL:        []string{},
+		OCSPServer: []string{"http://ocsp.example.com"},
+		RawSubject: []scraper.DNAttribute{{RDN: 0, OID: "2.5.4.3", Name: "CN", Value: "example.com"}},
+		Timings:    &scraper.Timings{},
+		ValidationErrors: []scraper.ValidationError{
+			{Code: scraper.CodeExpired, Message: "certificate expired"},
+		},
+	}
+	if err := WriteMinimalJSON(dir, details, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "example.com.json"))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	var written map[string]interface{}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("failed to decode %s: %v", data, err)
+	}
+
+	var keys []string
+	for key := range written {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	expected := []string{"domain", "issuer", "lifetime_remaining_pct", "not_after", "not_before", "ocsp_server", "raw_subject", "serial", "valid", "validation_errors"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys %v, got %v", expected, keys)
+	}
+
+	// Zero values within objects are dropped too, but array elements are kept.
+	subject := written["raw_subject"].([]interface{})
+	if attr := subject[0].(map[string]interface{}); len(subject) != 1 || attr["rdn"] != nil || attr["value"] != "example.com" {
+		t.Errorf("expected the rdn of the subject attribute to be dropped, got %v", subject)
+	}
+	if e := written["validation_errors"].([]interface{})[0].(map[string]interface{}); e["warning"] != nil {
+		t.Errorf("expected the warning flag to be dropped, got %v", e)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	dir := t.TempDir()
+	details := []*scraper.CertDetails{
+		{
+			Domain:     "example.com",
+			Serial:     "1",
+			NotBefore:  "2024-01-01 00:00:00 +0000 UTC",
+			NotAfter:   "2025-01-01 00:00:00 +0000 UTC",
+			Issuer:     "CN=Example CA,O=Example, Inc.",
+			Valid:      true,
+			CRL:        []string{"http://crl.example.com/a.crl", "http://crl.example.com/b.crl"},
+			OCSPServer: []string{"http://ocsp.example.com"},
+		},
+		{
+			Domain: "192.0.2.1",
+			Serial: "2",
+			Issuer: `CN="Quoted" CA`,
+			ValidationErrors: []scraper.ValidationError{
+				{Code: "EXPIRED", Message: "certificate has expired"},
+				{Code: "HOSTNAME_MISMATCH", Message: "no SAN for 192.0.2.1"},
+			},
+		},
+	}
+	if err := WriteCSV(dir, details); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "results.csv"))
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"domain", "serial", "not_before", "not_after", "issuer", "valid", "validation_errors", "crl", "ocsp"},
+		{"example.com", "1", "2024-01-01 00:00:00 +0000 UTC", "2025-01-01 00:00:00 +0000 UTC", "CN=Example CA,O=Example, Inc.", "true", "", "http://crl.example.com/a.crl http://crl.example.com/b.crl", "http://ocsp.example.com"},
+		{"192.0.2.1", "2", "", "", `CN="Quoted" CA`, "false", "EXPIRED: certificate has expired; HOSTNAME_MISMATCH: no SAN for 192.0.2.1", "", ""},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, rows)
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	details := []*scraper.CertDetails{
+		{Domain: "example.com", Serial: "1"},
+		{Domain: "192.0.2.1", Serial: "2"},
+	}
+	config := map[string]int{"concurrency": 10}
+	generatedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	if err := WriteBundle(dir, Bundle{GeneratedAt: generatedAt, Config: config, Results: details}, true); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "bundle.json"))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	var written struct {
+		SchemaVersion string                `json:"schema_version"`
+		GeneratedAt   time.Time             `json:"generated_at"`
+		Config        map[string]int        `json:"config"`
+		Results       []scraper.CertDetails `json:"results"`
+	}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("failed to decode %s: %v", data, err)
+	}
+	if written.SchemaVersion != BundleSchemaVersion {
+		t.Errorf("expected schema version %q, got %q", BundleSchemaVersion, written.SchemaVersion)
+	}
+	if !written.GeneratedAt.Equal(generatedAt) {
+		t.Errorf("expected generated at %s, got %s", generatedAt, written.GeneratedAt)
+	}
+	if len(written.Results) != 2 || written.Results[0].Domain != "example.com" || written.Results[1].Domain != "192.0.2.1" {
+		t.Errorf("expected the domain and IP results in order, got %+v", written.Results)
+	}
+	if !reflect.DeepEqual(written.Config, config) {
+		t.Errorf("expected config %v, got %v", config, written.Config)
+	}
+
+	// An empty scan still writes a valid bundle with an empty result list.
+	if err := WriteBundle(dir, Bundle{}, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "bundle.json"))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if expected := `{"schema_version":"1","generated_at":"0001-01-01T00:00:00Z","results":[]}` + "\n"; string(data) != expected {
+		t.Errorf("expected an empty result list, got %q", data)
+	}
+}
+
+func TestWriteStreamResult(t *testing.T) {
+	var buf strings.Builder
+	if err := WriteStreamResult(&buf, "example.com", &scraper.CertDetails{Domain: "example.com", Port: 443, Serial: "1"}, nil); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := WriteStreamResult(&buf, "broken.example.com", nil, errors.New("connection refused")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	var details scraper.CertDetails
+	if err := json.Unmarshal([]byte(lines[0]), &details); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", lines[0], err)
+	}
+	if details.Domain != "example.com" || details.Port != 443 || details.Serial != "1" {
+		t.Errorf("unexpected details: %+v", details)
+	}
+	if expected := `{"domain":"broken.example.com","error":"connection refused"}`; lines[1] != expected {
+		t.Errorf("expected %s, got %s", expected, lines[1])
+	}
+}
+
+func TestWriteProbeResults(t *testing.T) {
+	var buf strings.Builder
+	results := []*scraper.ProbeResult{
+		{Target: "example.com", Port: 443, Reachable: true, TLSVersion: "TLS 1.3"},
+		{Target: "192.0.2.1", Port: 443, Error: "connection refused"},
+	}
+	if err := WriteProbeResults(&buf, results); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	expected := `{"target":"example.com","port":443,"reachable":true,"tls_version":"TLS 1.3"}
+{"target":"192.0.2.1","port":443,"reachable":false,"error":"connection refused"}
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,174 @@
+package helper
+
+import (
+	"math/rand"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIPRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"192.0.2.7", []string{"192.0.2.7"}},
+		{"192.0.2.10-192.0.2.13", []string{"192.0.2.10", "192.0.2.11", "192.0.2.12", "192.0.2.13"}},
+		{"192.0.2.255 - 192.0.3.0", []string{"192.0.2.255", "192.0.3.0"}},
+		{"192.0.2.5-192.0.2.5", []string{"192.0.2.5"}},
+		{"192.0.2.9/30", []string{"192.0.2.8", "192.0.2.9", "192.0.2.10", "192.0.2.11"}},
+		{"2001:db8::fe-2001:db8::101", []string{"2001:db8::fe", "2001:db8::ff", "2001:db8::100", "2001:db8::101"}},
+		{"2001:db8::/127", []string{"2001:db8::", "2001:db8::1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			r, err := ParseIPRange(tt.input)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			addresses, err := r.Addresses()
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(addresses, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestParseIPRangeErrors(t *testing.T) {
+	for _, input := range []string{
+		"192.0.2.50-192.0.2.10",
+		"2001:db8::2-2001:db8::1",
+		"192.0.2.1-2001:db8::1",
+		"192.0.2.1-",
+		"example.com",
+		"192.0.2.0/33",
+	} {
+		if _, err := ParseIPRange(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestIPRangeAddressesLimit(t *testing.T) {
+	r, err := ParseIPRange("10.0.0.0/15")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := r.Addresses(); err == nil {
+		t.Errorf("expected an error for a range of more than %d addresses", MaxRangeSize)
+	}
+
+	r, err = ParseIPRange("255.255.255.254/31")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if addresses, err := r.Addresses(); err != nil || len(addresses) != 2 {
+		t.Errorf("expected the range to end at the last address, got %v, %v", addresses, err)
+	}
+}
+
+func TestIPRangeExpandTooLarge(t *testing.T) {
+	r, err := ParseIPRange("2001:db8::/32")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if size := r.Size().String(); size != "79228162514264337593543950336" {
+		t.Errorf("expected 2^96 addresses, got %s", size)
+	}
+	_, err = r.Expand(MaxRangeSize)
+	if err == nil || !strings.Contains(err.Error(), "has 79228162514264337593543950336 addresses, more than the limit of 65536") {
+		t.Errorf("expected an error naming the size and limit, got: %v", err)
+	}
+
+	r, err = ParseIPRange("192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := r.Expand(255); err == nil {
+		t.Error("expected an error for a range one over the limit")
+	}
+	if addresses, err := r.Expand(256); err != nil || len(addresses) != 256 {
+		t.Errorf("expected a range at the limit to expand, got %d addresses, %v", len(addresses), err)
+	}
+}
+
+func TestIPRangeSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	r, err := ParseIPRange("2001:db8::/48")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	prefix := netip.MustParsePrefix("2001:db8::/48")
+	sample := r.Sample(50, rng)
+	if len(sample) != 50 {
+		t.Fatalf("expected 50 addresses, got %d", len(sample))
+	}
+	seen := make(map[string]bool)
+	for i, address := range sample {
+		addr, err := netip.ParseAddr(address)
+		if err != nil || !prefix.Contains(addr) {
+			t.Errorf("expected an address in %s, got %s", prefix, address)
+		}
+		if seen[address] {
+			t.Errorf("expected distinct addresses, got %s twice", address)
+		}
+		seen[address] = true
+		if i > 0 && !netip.MustParseAddr(sample[i-1]).Less(addr) {
+			t.Errorf("expected the sample in order, got %s after %s", address, sample[i-1])
+		}
+	}
+
+	r, err = ParseIPRange("192.0.2.0/30")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := []string{"192.0.2.0", "192.0.2.1", "192.0.2.2", "192.0.2.3"}
+	if got := r.Sample(10, rng); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected every address of a small range, got %v", got)
+	}
+	if got := r.Sample(3, rng); len(got) != 3 {
+		t.Errorf("expected 3 of the 4 addresses, got %v", got)
+	}
+}
+
+func TestIPRangeAll(t *testing.T) {
+	for _, input := range []string{"192.0.2.7", "192.0.2.0/29", "192.0.2.250-192.0.3.5", "255.255.255.252/30", "2001:db8::fffe-2001:db8::1:1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/126"} {
+		r, err := ParseIPRange(input)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", input, err)
+		}
+		expected, err := r.Addresses()
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", input, err)
+		}
+
+		var got []string
+		r.All()(func(addr netip.Addr) bool {
+			got = append(got, addr.String())
+			return true
+		})
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", input, expected, got)
+		}
+	}
+
+	// Stopping early must not generate the rest of a huge range.
+	r, err := ParseIPRange("2001:db8::/32")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var got []string
+	r.All()(func(addr netip.Addr) bool {
+		got = append(got, addr.String())
+		return len(got) < 3
+	})
+	if expected := []string{"2001:db8::", "2001:db8::1", "2001:db8::2"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,74 @@
+package helper
+
+import (
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"strings"
+)
+
+// unknownIssuer names the group of results whose issuer has no common name.
+const unknownIssuer = "unknown-issuer"
+
+// WriteBundlesByIssuer partitions the results in bundle by the common name of
+// their issuer and writes each group as a bundle, like WriteBundle, to
+// bundle-<issuer>.json in the given directory. Issuer names are reduced to
+// lower case letters, digits, dots and dashes for the filename, so issuers
+// that differ only in punctuation or case share a file.
+func WriteBundlesByIssuer(directory string, bundle Bundle, prettyPrint bool) error {
+	groups := make(map[string][]*scraper.CertDetails)
+	var order []string
+	for _, detail := range bundle.Results {
+		name := issuerFilename(issuerCommonName(detail))
+		if _, ok := groups[name]; !ok {
+			order = append(order, name)
+		}
+		groups[name] = append(groups[name], detail)
+	}
+
+	for _, name := range order {
+		filename := fmt.Sprintf("%s/bundle-%s.json", directory, name)
+		group := bundle
+		group.Results = groups[name]
+		if err := writeBundle(filename, group, prettyPrint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issuerCommonName returns the common name of the issuer of the leaf
+// certificate in details, or "" if it has none.
+func issuerCommonName(details *scraper.CertDetails) string {
+	for _, attr := range details.RawIssuer {
+		if attr.Name == "CN" {
+			return attr.Value
+		}
+	}
+	if len(details.CertChain) > 0 {
+		return details.CertChain[0].Issuer.CommonName
+	}
+	return ""
+}
+
+// issuerFilename returns a name safe to use in a filename for the issuer
+// common name cn.
+func issuerFilename(cn string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(cn) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	name := strings.TrimRight(b.String(), "-")
+	// A name of only dots would refer to the directory or its parent.
+	if strings.Trim(name, ".") == "" {
+		return unknownIssuer
+	}
+	return name
+}
@@ -0,0 +1,86 @@
+package helper
+
+import (
+	"encoding/json"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWriteBundlesByIssuer(t *testing.T) {
+	dir := t.TempDir()
+	issuer := func(cn string) []scraper.DNAttribute {
+		return []scraper.DNAttribute{{OID: "2.5.4.3", Name: "CN", Value: cn}}
+	}
+	details := []*scraper.CertDetails{
+		{Domain: "a.example.com", RawIssuer: issuer("R3")},
+		{Domain: "b.example.com", RawIssuer: issuer("DigiCert TLS RSA SHA256 2020 CA1")},
+		{Domain: "c.example.com", RawIssuer: issuer("R3")},
+		{Domain: "d.example.com", RawIssuer: []scraper.DNAttribute{{OID: "2.5.4.10", Name: "O", Value: "Example"}}},
+		{Domain: "e.example.com", RawIssuer: issuer("../../etc")},
+	}
+	if err := WriteBundlesByIssuer(dir, Bundle{Results: details}, false); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	expected := map[string][]string{
+		"bundle-r3.json": {"a.example.com", "c.example.com"},
+		"bundle-digicert-tls-rsa-sha256-2020-ca1.json": {"b.example.com"},
+		"bundle-unknown-issuer.json":                   {"d.example.com"},
+		"bundle-..-..-etc.json":                        {"e.example.com"},
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	var files, expectedFiles []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	for name := range expected {
+		expectedFiles = append(expectedFiles, name)
+	}
+	sort.Strings(expectedFiles)
+	if !reflect.DeepEqual(files, expectedFiles) {
+		t.Fatalf("expected files %v, got %v", expectedFiles, files)
+	}
+
+	for name, domains := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		var bundle struct {
+			Results []scraper.CertDetails `json:"results"`
+		}
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			t.Fatalf("failed to decode %s: %v", name, err)
+		}
+		var written []string
+		for _, result := range bundle.Results {
+			written = append(written, result.Domain)
+		}
+		if !reflect.DeepEqual(written, domains) {
+			t.Errorf("%s: expected %v, got %v", name, domains, written)
+		}
+	}
+}
+
+func TestIssuerFilename(t *testing.T) {
+	tests := map[string]string{
+		"R3":                   "r3",
+		"Amazon RSA 2048 M02":  "amazon-rsa-2048-m02",
+		"  Sectigo (ECC) CA  ": "sectigo-ecc-ca",
+		"":                     unknownIssuer,
+		"..":                   unknownIssuer,
+		"/":                    unknownIssuer,
+	}
+	for cn, expected := range tests {
+		if got := issuerFilename(cn); got != expected {
+			t.Errorf("issuerFilename(%q) = %q, expected %q", cn, got, expected)
+		}
+	}
+}
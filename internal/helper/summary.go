@@ -0,0 +1,25 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"io"
+)
+
+// WriteSummaryJSON writes the scan summary to w as a single line of JSON.
+func WriteSummaryJSON(w io.Writer, summary scraper.ScanSummary) error {
+	return json.NewEncoder(w).Encode(summary)
+}
+
+// WriteSummaryText writes the scan summary to w as one "Name: value" line per
+// count, with the same names as the HTML report.
+func WriteSummaryText(w io.Writer, summary scraper.ScanSummary) error {
+	_, err := fmt.Fprintf(w, "Total: %d\nSucceeded: %d\nFailed: %d\nValid: %d\nInvalid: %d\nElapsed: %s\n",
+		summary.Total, summary.Succeeded, summary.Failed, summary.Valid, summary.Invalid, summary.Elapsed)
+	if err != nil || summary.EarliestExpiryDomain == "" {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Earliest expiry: %s (%s)\n", summary.EarliestExpiryDomain, summary.EarliestExpiry.Format("2006-01-02"))
+	return err
+}
@@ -0,0 +1,43 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"testing"
+	"time"
+)
+
+func TestWriteSummary(t *testing.T) {
+	summary := scraper.ScanSummary{
+		Total:                3,
+		Succeeded:            2,
+		Failed:               1,
+		Valid:                1,
+		Invalid:              1,
+		Elapsed:              1500 * time.Millisecond,
+		EarliestExpiry:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		EarliestExpiryDomain: "example.com",
+	}
+
+	var text bytes.Buffer
+	if err := WriteSummaryText(&text, summary); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := "Total: 3\nSucceeded: 2\nFailed: 1\nValid: 1\nInvalid: 1\nElapsed: 1.5s\nEarliest expiry: example.com (2024-03-01)\n"
+	if text.String() != expected {
+		t.Errorf("expected %q, got %q", expected, text.String())
+	}
+
+	var encoded bytes.Buffer
+	if err := WriteSummaryJSON(&encoded, summary); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var decoded scraper.ScanSummary
+	if err := json.Unmarshal(encoded.Bytes(), &decoded); err != nil {
+		t.Fatalf("failed to decode %s: %v", encoded.Bytes(), err)
+	}
+	if decoded != summary {
+		t.Errorf("expected %+v, got %+v", summary, decoded)
+	}
+}
@@ -0,0 +1,214 @@
+// Package crl provides functionality for checking whether a certificate has
+// been revoked using the Certificate Revocation Lists (CRLs) it names.
+package crl
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxCRLSize is the largest CRL read when CRLChecker.MaxCRLSize is not
+// set. CRLs of large public CAs can run to several MB.
+const DefaultMaxCRLSize = 20 << 20
+
+// DefaultMaxCachedCRLs is the most CRLs kept in memory when
+// CRLChecker.MaxCachedCRLs is not set.
+const DefaultMaxCachedCRLs = 64
+
+// DefaultTimeout bounds each CRL download when CRLChecker.Client is not set.
+const DefaultTimeout = 10 * time.Second
+
+// defaultClient is used when CRLChecker.Client is not set.
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// ErrNoDistributionPoint is returned for a certificate that names no HTTP CRL
+// distribution point.
+var ErrNoDistributionPoint = errors.New("no HTTP CRL distribution point specified in cert")
+
+// ErrCRLTooLarge is returned when a CRL exceeds the configured maximum size.
+var ErrCRLTooLarge = errors.New("CRL exceeds maximum size")
+
+// CRLChecker downloads the CRLs named by certificates and reports whether
+// they are revoked. Downloaded CRLs are cached by URL until their next
+// update is due, so certificates from the same issuer share a download, and
+// concurrent checks that need a CRL not yet cached wait for a single download
+// of it. A CRLChecker is safe for concurrent use.
+type CRLChecker struct {
+	// MaxCRLSize is the largest CRL, in bytes, that will be downloaded.
+	// DefaultMaxCRLSize is used when zero.
+	MaxCRLSize int64
+
+	// Client is used to download CRLs. A client with DefaultTimeout is used
+	// when nil.
+	Client *http.Client
+
+	// MaxCachedCRLs is the most CRLs kept in the cache. When it is full,
+	// CRLs past their next update are dropped first, then the one due for
+	// an update soonest. DefaultMaxCachedCRLs is used when zero.
+	MaxCachedCRLs int
+
+	mu      sync.Mutex
+	cache   map[string]*x509.RevocationList
+	pending map[string]*pendingCRL
+}
+
+// pendingCRL is a download in progress, which other checks needing the same
+// CRL wait on rather than downloading it again.
+type pendingCRL struct {
+	done chan struct{}
+	list *x509.RevocationList
+	err  error
+}
+
+// IsRevoked reports whether cert is listed on the CRL from its first CRL
+// distribution point that can be downloaded. The CRL's signature is checked
+// against issuer, unless issuer is nil. Distribution points other than HTTP
+// ones, such as LDAP, are skipped.
+func (c *CRLChecker) IsRevoked(cert, issuer *x509.Certificate) (bool, error) {
+	err := ErrNoDistributionPoint
+	for _, url := range cert.CRLDistributionPoints {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		var list *x509.RevocationList
+		list, err = c.getCRL(url)
+		if err != nil {
+			continue
+		}
+		if issuer != nil {
+			if err = list.CheckSignatureFrom(issuer); err != nil {
+				err = fmt.Errorf("CRL from %s is not signed by the issuer: %w", url, err)
+				continue
+			}
+		}
+		for _, revoked := range list.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, err
+}
+
+// getCRL returns the CRL at url, from the cache when it is still current, or
+// from a download already in progress for another certificate. Failed
+// downloads are not cached, so they are retried for the next certificate.
+func (c *CRLChecker) getCRL(url string) (*x509.RevocationList, error) {
+	c.mu.Lock()
+	if list, ok := c.cache[url]; ok && current(list, time.Now()) {
+		c.mu.Unlock()
+		return list, nil
+	}
+	if pending, ok := c.pending[url]; ok {
+		c.mu.Unlock()
+		<-pending.done
+		return pending.list, pending.err
+	}
+	pending := &pendingCRL{done: make(chan struct{})}
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingCRL)
+	}
+	c.pending[url] = pending
+	c.mu.Unlock()
+
+	pending.list, pending.err = c.download(url)
+
+	c.mu.Lock()
+	delete(c.pending, url)
+	if pending.err == nil {
+		c.store(url, pending.list)
+	}
+	c.mu.Unlock()
+	close(pending.done)
+	return pending.list, pending.err
+}
+
+// current reports whether list is not yet due for an update at now.
+func current(list *x509.RevocationList, now time.Time) bool {
+	return list.NextUpdate.IsZero() || now.Before(list.NextUpdate)
+}
+
+// store caches list for url, first making room for it if the cache is full.
+// The caller must hold c.mu.
+func (c *CRLChecker) store(url string, list *x509.RevocationList) {
+	if c.cache == nil {
+		c.cache = make(map[string]*x509.RevocationList)
+	}
+	maxCached := c.MaxCachedCRLs
+	if maxCached <= 0 {
+		maxCached = DefaultMaxCachedCRLs
+	}
+	if _, exists := c.cache[url]; !exists && len(c.cache) >= maxCached {
+		c.evict(time.Now(), maxCached)
+	}
+	c.cache[url] = list
+}
+
+// evict drops every CRL past its next update, or the CRL due for an update
+// soonest when none are, so that the cache holds fewer than maxCached. The
+// caller must hold c.mu.
+func (c *CRLChecker) evict(now time.Time, maxCached int) {
+	var soonest string
+	var soonestUpdate time.Time
+	for url, list := range c.cache {
+		if !current(list, now) {
+			delete(c.cache, url)
+			continue
+		}
+		if list.NextUpdate.IsZero() {
+			continue
+		}
+		if soonestUpdate.IsZero() || list.NextUpdate.Before(soonestUpdate) {
+			soonest, soonestUpdate = url, list.NextUpdate
+		}
+	}
+	if len(c.cache) < maxCached {
+		return
+	}
+	if soonestUpdate.IsZero() {
+		// Only CRLs without a next update remain; drop any of them.
+		for url := range c.cache {
+			soonest = url
+			break
+		}
+	}
+	delete(c.cache, soonest)
+}
+
+// download fetches and parses the DER encoded CRL at url.
+func (c *CRLChecker) download(url string) (*x509.RevocationList, error) {
+	client := c.Client
+	if client == nil {
+		client = defaultClient
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CRL server returned %s", resp.Status)
+	}
+
+	maxSize := c.MaxCRLSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxCRLSize
+	}
+	// Read one byte past the limit so an oversized body can be detected.
+	der, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(der)) > maxSize {
+		return nil, ErrCRLTooLarge
+	}
+	return x509.ParseRevocationList(der)
+}
@@ -0,0 +1,229 @@
+package crl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestCA returns a self-signed CA certificate and its key.
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA: %v", err)
+	}
+	return ca, key
+}
+
+// newTestLeaf returns a certificate with the given serial, issued by ca, that
+// names crlURLs as its CRL distribution points.
+func newTestLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, crlURLs ...string) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: crlURLs,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create leaf: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse leaf: %v", err)
+	}
+	return leaf
+}
+
+// newTestCRL returns a DER encoded CRL signed by ca that revokes the given
+// serials.
+func newTestCRL(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, revoked ...int64) []byte {
+	t.Helper()
+
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, serial := range revoked {
+		template.RevokedCertificates = append(template.RevokedCertificates, pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+	return der
+}
+
+// serveCRL serves der and counts the requests made for it.
+func serveCRL(t *testing.T, der []byte) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write(der)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestIsRevoked(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	server, requests := serveCRL(t, newTestCRL(t, ca, caKey, 10, 42))
+
+	checker := &CRLChecker{}
+	tests := []struct {
+		name     string
+		serial   int64
+		expected bool
+	}{
+		{"revoked", 42, true},
+		{"not revoked", 43, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf := newTestLeaf(t, ca, caKey, tt.serial, server.URL)
+			revoked, err := checker.IsRevoked(leaf, ca)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if revoked != tt.expected {
+				t.Errorf("expected revoked to be %v, got %v", tt.expected, revoked)
+			}
+		})
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("expected the CRL to be downloaded once and cached, got %d downloads", got)
+	}
+}
+
+func TestIsRevokedErrors(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	other, otherKey := newTestCA(t)
+
+	forged, _ := serveCRL(t, newTestCRL(t, other, otherKey, 42))
+	leaf := newTestLeaf(t, ca, caKey, 42, forged.URL)
+	if _, err := (&CRLChecker{}).IsRevoked(leaf, ca); err == nil {
+		t.Error("expected an error for a CRL signed by another CA")
+	}
+
+	large, _ := serveCRL(t, newTestCRL(t, ca, caKey, 42))
+	leaf = newTestLeaf(t, ca, caKey, 42, large.URL)
+	if _, err := (&CRLChecker{MaxCRLSize: 16}).IsRevoked(leaf, ca); !errors.Is(err, ErrCRLTooLarge) {
+		t.Errorf("expected ErrCRLTooLarge, got: %v", err)
+	}
+
+	leaf = newTestLeaf(t, ca, caKey, 42, "ldap://ldap.example.com/cn=Test%20CA")
+	if _, err := (&CRLChecker{}).IsRevoked(leaf, ca); !errors.Is(err, ErrNoDistributionPoint) {
+		t.Errorf("expected ErrNoDistributionPoint, got: %v", err)
+	}
+}
+
+func TestIsRevokedFallsBack(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up, _ := serveCRL(t, newTestCRL(t, ca, caKey, 42))
+
+	leaf := newTestLeaf(t, ca, caKey, 42, down.URL, up.URL)
+	revoked, err := (&CRLChecker{}).IsRevoked(leaf, ca)
+	if err != nil || !revoked {
+		t.Errorf("expected the second distribution point to report the revocation, got %v, %v", revoked, err)
+	}
+}
+
+func TestIsRevokedSharesDownloads(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	der := newTestCRL(t, ca, caKey, 42)
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = w.Write(der)
+	}))
+	defer server.Close()
+
+	checker := &CRLChecker{}
+	leaf := newTestLeaf(t, ca, caKey, 42, server.URL)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if revoked, err := checker.IsRevoked(leaf, ca); err != nil || !revoked {
+				t.Errorf("expected the certificate to be revoked, got %v, %v", revoked, err)
+			}
+		}()
+	}
+	// Hold the download until the other checks have had time to ask for it.
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("expected concurrent checks to share one download, got %d downloads", got)
+	}
+}
+
+func TestIsRevokedCacheBound(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	first, firstRequests := serveCRL(t, newTestCRL(t, ca, caKey))
+	second, secondRequests := serveCRL(t, newTestCRL(t, ca, caKey))
+
+	checker := &CRLChecker{MaxCachedCRLs: 1}
+	for _, url := range []string{first.URL, second.URL, first.URL, first.URL} {
+		if _, err := checker.IsRevoked(newTestLeaf(t, ca, caKey, 42, url), ca); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if len(checker.cache) != 1 {
+		t.Errorf("expected 1 cached CRL, got %d", len(checker.cache))
+	}
+	if got := atomic.LoadInt32(firstRequests); got != 2 {
+		t.Errorf("expected the evicted CRL to be downloaded again, got %d downloads", got)
+	}
+	if got := atomic.LoadInt32(secondRequests); got != 1 {
+		t.Errorf("expected 1 download of the second CRL, got %d", got)
+	}
+}
@@ -0,0 +1,194 @@
+// Package inventory discovers scan targets from cloud provider APIs. It talks
+// to the APIs directly over HTTP rather than through the provider SDKs, to
+// keep those large dependencies out of tls-scrape.
+package inventory
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// elbAPIVersion is the version of the Elastic Load Balancing API that lists
+// application, network and gateway load balancers.
+const elbAPIVersion = "2015-12-01"
+
+// AWSCredentials are the credentials used to sign AWS API requests.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is only set for temporary credentials.
+	SessionToken string
+}
+
+// AWSCredentialsFromEnv reads credentials from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+func AWSCredentialsFromEnv() (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+// ELBLister lists the DNS names of the application, network and gateway load
+// balancers in an AWS region. Classic load balancers are not listed.
+type ELBLister struct {
+	Region      string
+	Credentials AWSCredentials
+
+	// Endpoint overrides the regional API endpoint, for example to use a
+	// VPC endpoint.
+	Endpoint string
+
+	// Client is used to make requests. http.DefaultClient is used when nil.
+	Client *http.Client
+
+	now func() time.Time
+}
+
+// describeLoadBalancersResponse is the part of the DescribeLoadBalancers
+// response that is used.
+type describeLoadBalancersResponse struct {
+	DNSNames   []string `xml:"DescribeLoadBalancersResult>LoadBalancers>member>DNSName"`
+	NextMarker string   `xml:"DescribeLoadBalancersResult>NextMarker"`
+}
+
+// awsErrorResponse is the body of a failed AWS Query API request.
+type awsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// DNSNames returns the DNS name of every load balancer in the region,
+// following pagination.
+func (l *ELBLister) DNSNames(ctx context.Context) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"Action": {"DescribeLoadBalancers"}, "Version": {elbAPIVersion}}
+		if marker != "" {
+			query.Set("Marker", marker)
+		}
+
+		var page describeLoadBalancersResponse
+		if err := l.call(ctx, query, &page); err != nil {
+			return nil, err
+		}
+		names = append(names, page.DNSNames...)
+		if page.NextMarker == "" {
+			return names, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// call makes a signed GET request to the API and decodes the XML response
+// into v.
+func (l *ELBLister) call(ctx context.Context, query url.Values, v interface{}) error {
+	endpoint := l.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://elasticloadbalancing.%s.amazonaws.com", l.Region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	u.Path = "/"
+	// SigV4 requires spaces to be encoded as %20 rather than +.
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	now := time.Now
+	if l.now != nil {
+		now = l.now
+	}
+	signV4(req, l.Credentials, l.Region, "elasticloadbalancing", now().UTC())
+
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr awsErrorResponse
+		if xml.Unmarshal(body, &apiErr) == nil && apiErr.Code != "" {
+			return fmt.Errorf("%s: %s", apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// signV4 signs a request without a body with AWS Signature Version 4.
+func signV4(req *http.Request, creds AWSCredentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	signedHeaders := "host;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\n"
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + creds.SessionToken + "\n"
+	}
+
+	emptyPayload := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(emptyPayload[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signature := hmacSHA256(signingKey(creds.SecretAccessKey, date, region, service), stringToSign)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, hex.EncodeToString(signature)))
+}
+
+// signingKey derives the SigV4 signing key for a day, region and service.
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
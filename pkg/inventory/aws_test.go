@@ -0,0 +1,99 @@
+package inventory
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningKey(t *testing.T) {
+	// The example from the AWS Signature Version 4 documentation.
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != expected {
+		t.Errorf("expected signing key %s, got %s", expected, got)
+	}
+}
+
+const elbPage = `<DescribeLoadBalancersResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2015-12-01/">
+  <DescribeLoadBalancersResult>
+    <LoadBalancers>
+      <member><DNSName>%s</DNSName><LoadBalancerName>first</LoadBalancerName></member>
+      <member><DNSName>%s</DNSName><LoadBalancerName>second</LoadBalancerName></member>
+    </LoadBalancers>
+    %s
+  </DescribeLoadBalancersResult>
+</DescribeLoadBalancersResponse>`
+
+func TestELBListerDNSNames(t *testing.T) {
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("Action") != "DescribeLoadBalancers" || query.Get("Version") != elbAPIVersion {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if r.Header.Get("X-Amz-Security-Token") != "token" {
+			http.Error(w, "missing session token", http.StatusForbidden)
+			return
+		}
+
+		if query.Get("Marker") == "" {
+			fmt.Fprintf(w, elbPage, "a-1.eu-west-1.elb.amazonaws.com", "b-2.eu-west-1.elb.amazonaws.com", "<NextMarker>page+2/=</NextMarker>")
+			return
+		}
+		if query.Get("Marker") != "page+2/=" {
+			http.Error(w, "unexpected marker "+query.Get("Marker"), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, elbPage, "c-3.eu-west-1.elb.amazonaws.com", "d-4.eu-west-1.elb.amazonaws.com", "")
+	}))
+	defer server.Close()
+
+	lister := &ELBLister{
+		Region:      "eu-west-1",
+		Credentials: AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"},
+		Endpoint:    server.URL,
+		now:         func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	names, err := lister.DNSNames(context.Background())
+	if err != nil {
+		t.Fatalf("DNSNames() error = %v", err)
+	}
+	expected := []string{
+		"a-1.eu-west-1.elb.amazonaws.com",
+		"b-2.eu-west-1.elb.amazonaws.com",
+		"c-3.eu-west-1.elb.amazonaws.com",
+		"d-4.eu-west-1.elb.amazonaws.com",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	prefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/eu-west-1/elasticloadbalancing/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token, Signature="
+	for _, authorization := range authorizations {
+		if !strings.HasPrefix(authorization, prefix) {
+			t.Errorf("expected authorization to start with %q, got %q", prefix, authorization)
+		}
+	}
+}
+
+func TestELBListerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`))
+	}))
+	defer server.Close()
+
+	lister := &ELBLister{Region: "eu-west-1", Credentials: AWSCredentials{AccessKeyID: "a", SecretAccessKey: "b"}, Endpoint: server.URL}
+	if _, err := lister.DNSNames(context.Background()); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("expected an AccessDenied error, got %v", err)
+	}
+}
@@ -4,13 +4,46 @@ package ocsp
 
 import (
 	"bytes"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"golang.org/x/crypto/ocsp"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// DefaultMaxResponseSize is the largest OCSP response read when
+// OCSPChecker.MaxResponseSize is not set. Real responses are a few KB.
+const DefaultMaxResponseSize = 1 << 20
+
+// DefaultTimeout bounds each request to the OCSP server when
+// OCSPChecker.Client is not set.
+const DefaultTimeout = 10 * time.Second
+
+// defaultClient is used when OCSPChecker.Client is not set.
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// ErrResponseTooLarge is returned when an OCSP response exceeds the
+// configured maximum size.
+var ErrResponseTooLarge = errors.New("OCSP response exceeds maximum size")
+
+// ErrNonceMismatch is returned when an OCSP responder echoes a different nonce
+// to the one sent, which suggests a replayed response.
+var ErrNonceMismatch = errors.New("OCSP response nonce does not match request")
+
+// nonceSize is the length of the nonces sent, the most RFC 8954 allows.
+const nonceSize = 32
+
+// oidNonce identifies the OCSP nonce extension (RFC 8954).
+var oidNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
 // OCSPChecker holds the details of the certificate and its issuer.
 // It provides methods to retrieve and check the OCSP response for the certificate.
 type OCSPChecker struct {
@@ -19,6 +52,19 @@ type OCSPChecker struct {
 
 	// Issuer is the issuer of the certificate.
 	Issuer *x509.Certificate
+
+	// MaxResponseSize is the largest response body, in bytes, that will be
+	// read from the OCSP server. DefaultMaxResponseSize is used when zero.
+	MaxResponseSize int64
+
+	// Client is used to make requests to the OCSP server. A client with
+	// DefaultTimeout is used when nil.
+	Client *http.Client
+
+	// Nonce adds a random nonce to the request and checks that the responder
+	// echoes it back. Responders that ignore the nonce are still accepted, and
+	// the request is retried without one if the responder rejects it.
+	Nonce bool
 }
 
 // GetOCSPResp queries the OCSP server specified in the certificate and retrieves the OCSP response.
@@ -28,21 +74,85 @@ func (o *OCSPChecker) GetOCSPResp() (*ocsp.Response, error) {
 		return nil, errors.New("no OCSP server specified in cert")
 	}
 
+	// The CertID keeps the default SHA-1 hash. The lightweight profile in
+	// RFC 5019, which most public responders implement, only requires them
+	// to accept SHA-1 CertIDs, and some answer anything else with
+	// "unauthorized". The hash only identifies the certificate; replay
+	// protection comes from the nonce.
 	ocspReq, err := ocsp.CreateRequest(o.Certificate, o.Issuer, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	httpResp, err := http.Post(o.Certificate.OCSPServer[0], "application/ocsp-request", bytes.NewReader(ocspReq))
+	if o.Nonce {
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		nonceReq, err := addNonce(ocspReq, nonce)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := o.exchange(nonceReq)
+		var respErr ocsp.ResponseError
+		if errors.As(err, &respErr) {
+			// Some responders refuse requests with extensions they don't
+			// support, so ask again without the nonce.
+			return o.exchange(ocspReq)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := checkNonce(resp, nonce); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	return o.exchange(ocspReq)
+}
+
+// exchange sends a DER encoded OCSP request to the certificate's OCSP server
+// and parses the response. The request is POSTed, and sent again with GET if
+// the server refuses the POST, as some responders only support GET.
+func (o *OCSPChecker) exchange(ocspReq []byte) (*ocsp.Response, error) {
+	client := o.Client
+	if client == nil {
+		client = defaultClient
+	}
+
+	server := o.Certificate.OCSPServer[0]
+	httpResp, err := client.Post(server, "application/ocsp-request", bytes.NewReader(ocspReq))
 	if err != nil {
 		return nil, err
 	}
+	if httpResp.StatusCode != http.StatusOK {
+		httpResp.Body.Close()
+		// A GET request carries the URL encoded base64 of the request
+		// after the server URL (RFC 6960, appendix A.1).
+		getURL := strings.TrimSuffix(server, "/") + "/" + url.QueryEscape(base64.StdEncoding.EncodeToString(ocspReq))
+		httpResp, err = client.Get(getURL)
+		if err != nil {
+			return nil, err
+		}
+	}
 	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP server returned %s", httpResp.Status)
+	}
 
-	ocspResp, err := io.ReadAll(httpResp.Body)
+	maxSize := o.MaxResponseSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxResponseSize
+	}
+	// Read one byte past the limit so an oversized body can be detected.
+	ocspResp, err := io.ReadAll(io.LimitReader(httpResp.Body, maxSize+1))
 	if err != nil {
 		return nil, err
 	}
+	if int64(len(ocspResp)) > maxSize {
+		return nil, ErrResponseTooLarge
+	}
 
 	resp, err := ocsp.ParseResponse(ocspResp, o.Issuer)
 	if err != nil {
@@ -60,6 +170,63 @@ func (o *OCSPChecker) GetOCSPResp() (*ocsp.Response, error) {
 	return resp, nil
 }
 
+// request mirrors the parts of an OCSP request (RFC 6960, section 4.1.1) that
+// are needed to add request extensions.
+type request struct {
+	TBSRequest struct {
+		RequestList asn1.RawValue
+		Extensions  []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+}
+
+// responseData mirrors the signed data of a basic OCSP response (RFC 6960,
+// section 4.2.1) down to its response extensions.
+type responseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   asn1.RawValue
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// addNonce returns the DER encoded OCSP request with a nonce extension added.
+func addNonce(der, nonce []byte) ([]byte, error) {
+	var req request
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		return nil, err
+	}
+	value, err := asn1.Marshal(nonce)
+	if err != nil {
+		return nil, err
+	}
+	req.TBSRequest.Extensions = append(req.TBSRequest.Extensions, pkix.Extension{Id: oidNonce, Value: value})
+	return asn1.Marshal(req)
+}
+
+// checkNonce returns ErrNonceMismatch if the signed response carries a nonce
+// other than the one sent. A response without a nonce is accepted, as many
+// responders serve pre-signed responses and can't echo one.
+func checkNonce(resp *ocsp.Response, nonce []byte) error {
+	var data responseData
+	if _, err := asn1.Unmarshal(resp.TBSResponseData, &data); err != nil {
+		return err
+	}
+	for _, ext := range data.Extensions {
+		if !ext.Id.Equal(oidNonce) {
+			continue
+		}
+		var echoed []byte
+		if _, err := asn1.Unmarshal(ext.Value, &echoed); err != nil {
+			// Older responders send the nonce without the OCTET STRING wrapper.
+			echoed = ext.Value
+		}
+		if !bytes.Equal(echoed, nonce) {
+			return ErrNonceMismatch
+		}
+	}
+	return nil
+}
+
 // CheckOCSPStatus retrieves the OCSP response using GetOCSPResp and checks if the certificate status is good.
 // Returns an error if the OCSP response indicates an invalid status or if fetching the OCSP response fails.
 func (o *OCSPChecker) CheckOCSPStatus() error {
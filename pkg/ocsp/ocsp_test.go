@@ -0,0 +1,378 @@
+package ocsp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPair returns a self-signed issuer and a leaf certificate signed by
+// it that names ocspURL as its OCSP server.
+func newTestPair(t *testing.T, ocspURL string) (*x509.Certificate, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create issuer: %v", err)
+	}
+	issuer, err := x509.ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatalf("failed to parse issuer: %v", err)
+	}
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{ocspURL},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, issuer, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create leaf: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatalf("failed to parse leaf: %v", err)
+	}
+	return leaf, issuer, key
+}
+
+func TestGetOCSPRespTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 2048))
+	}))
+	defer server.Close()
+
+	leaf, issuer, _ := newTestPair(t, server.URL)
+	checker := &OCSPChecker{Certificate: leaf, Issuer: issuer, MaxResponseSize: 1024}
+
+	_, err := checker.GetOCSPResp()
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge, got: %v", err)
+	}
+}
+
+// signedResponse returns a successful OCSP response with a good status, signed
+// by key and carrying the given response extensions. It is built by hand as
+// ocsp.CreateResponse can't add response extensions.
+func signedResponse(t *testing.T, key *ecdsa.PrivateKey, extensions []pkix.Extension) []byte {
+	t.Helper()
+
+	type certID struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		NameHash      []byte
+		IssuerKeyHash []byte
+		SerialNumber  *big.Int
+	}
+	type singleResponse struct {
+		CertID     certID
+		Good       asn1.Flag `asn1:"tag:0,optional"`
+		ThisUpdate time.Time `asn1:"generalized"`
+	}
+	type responseData struct {
+		ResponderID asn1.RawValue
+		ProducedAt  time.Time `asn1:"generalized"`
+		Responses   []singleResponse
+		Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+	}
+	type basicResponse struct {
+		TBSResponseData    asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}
+	type responseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	}
+	type response struct {
+		Status   asn1.Enumerated
+		Response responseBytes `asn1:"explicit,tag:0"`
+	}
+
+	keyHash, err := asn1.Marshal(make([]byte, 20))
+	if err != nil {
+		t.Fatalf("failed to marshal key hash: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	tbs, err := asn1.Marshal(responseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHash},
+		ProducedAt:  now,
+		Responses: []singleResponse{{
+			CertID: certID{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+				NameHash:      make([]byte, 20),
+				IssuerKeyHash: make([]byte, 20),
+				SerialNumber:  big.NewInt(2),
+			},
+			Good:       true,
+			ThisUpdate: now,
+		}},
+		Extensions: extensions,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal response data: %v", err)
+	}
+
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign response: %v", err)
+	}
+	basic, err := asn1.Marshal(basicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal basic response: %v", err)
+	}
+	der, err := asn1.Marshal(response{
+		Response: responseBytes{
+			ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1},
+			Response:     basic,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+	return der
+}
+
+// requestNonce returns the nonce extension value of a DER encoded OCSP
+// request, or nil if it has none.
+func requestNonce(t *testing.T, der []byte) []byte {
+	t.Helper()
+	var req request
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		t.Fatalf("failed to parse request: %v", err)
+	}
+	for _, ext := range req.TBSRequest.Extensions {
+		if ext.Id.Equal(oidNonce) {
+			return ext.Value
+		}
+	}
+	return nil
+}
+
+func TestGetOCSPRespNonce(t *testing.T) {
+	tests := []struct {
+		name string
+		// respond returns the response to a request with the given nonce
+		// extension value.
+		respond      func(t *testing.T, key *ecdsa.PrivateKey, nonce []byte) []byte
+		wantErr      error
+		wantRequests int
+	}{
+		{
+			name: "echoed",
+			respond: func(t *testing.T, key *ecdsa.PrivateKey, nonce []byte) []byte {
+				return signedResponse(t, key, []pkix.Extension{{Id: oidNonce, Value: nonce}})
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "mismatched",
+			respond: func(t *testing.T, key *ecdsa.PrivateKey, nonce []byte) []byte {
+				other, _ := asn1.Marshal(bytes.Repeat([]byte{1}, nonceSize))
+				return signedResponse(t, key, []pkix.Extension{{Id: oidNonce, Value: other}})
+			},
+			wantErr:      ErrNonceMismatch,
+			wantRequests: 1,
+		},
+		{
+			name: "ignored",
+			respond: func(t *testing.T, key *ecdsa.PrivateKey, nonce []byte) []byte {
+				return signedResponse(t, key, nil)
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "rejected",
+			respond: func(t *testing.T, key *ecdsa.PrivateKey, nonce []byte) []byte {
+				if nonce != nil {
+					return ocsp.MalformedRequestErrorResponse
+				}
+				return signedResponse(t, key, nil)
+			},
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var key *ecdsa.PrivateKey
+			var nonces [][]byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("failed to read request: %v", err)
+					return
+				}
+				nonce := requestNonce(t, body)
+				nonces = append(nonces, nonce)
+				_, _ = w.Write(tt.respond(t, key, nonce))
+			}))
+			defer server.Close()
+
+			var leaf, issuer *x509.Certificate
+			leaf, issuer, key = newTestPair(t, server.URL)
+			checker := &OCSPChecker{Certificate: leaf, Issuer: issuer, Nonce: true}
+
+			resp, err := checker.GetOCSPResp()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+			if err == nil && resp.Status != ocsp.Good {
+				t.Errorf("expected good status, got %d", resp.Status)
+			}
+			if len(nonces) != tt.wantRequests {
+				t.Fatalf("expected %d requests, got %d", tt.wantRequests, len(nonces))
+			}
+			if nonces[0] == nil {
+				t.Error("expected the first request to carry a nonce")
+			}
+			if tt.wantRequests > 1 && nonces[1] != nil {
+				t.Error("expected the retry to drop the nonce")
+			}
+		})
+	}
+}
+
+func TestGetOCSPRespWithoutNonce(t *testing.T) {
+	var key *ecdsa.PrivateKey
+	var nonce []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		nonce = requestNonce(t, body)
+		_, _ = w.Write(signedResponse(t, key, nil))
+	}))
+	defer server.Close()
+
+	var leaf, issuer *x509.Certificate
+	leaf, issuer, key = newTestPair(t, server.URL)
+	checker := &OCSPChecker{Certificate: leaf, Issuer: issuer}
+
+	if _, err := checker.GetOCSPResp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if nonce != nil {
+		t.Error("expected no nonce when Nonce is not set")
+	}
+}
+
+func TestGetOCSPRespMethods(t *testing.T) {
+	tests := []struct {
+		name string
+		// allowed are the methods the responder accepts.
+		allowed     []string
+		wantMethods []string
+		wantErr     bool
+	}{
+		{"post", []string{http.MethodPost, http.MethodGet}, []string{http.MethodPost}, false},
+		{"get fallback", []string{http.MethodGet}, []string{http.MethodPost, http.MethodGet}, false},
+		{"neither", nil, []string{http.MethodPost, http.MethodGet}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var key *ecdsa.PrivateKey
+			var methods []string
+			mux := http.NewServeMux()
+			// The responder is under a path, as is common, to check the GET
+			// request is appended to it.
+			mux.HandleFunc("/ocsp/", func(w http.ResponseWriter, r *http.Request) {
+				methods = append(methods, r.Method)
+				allowed := false
+				for _, method := range tt.allowed {
+					allowed = allowed || method == r.Method
+				}
+				if !allowed {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				var body []byte
+				var err error
+				if r.Method == http.MethodGet {
+					body, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/ocsp/"))
+				} else {
+					body, err = io.ReadAll(r.Body)
+				}
+				if err != nil {
+					t.Errorf("failed to read request: %v", err)
+				}
+				if _, err := ocsp.ParseRequest(body); err != nil {
+					t.Errorf("failed to parse %s request: %v", r.Method, err)
+				}
+				_, _ = w.Write(signedResponse(t, key, nil))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			var leaf, issuer *x509.Certificate
+			leaf, issuer, key = newTestPair(t, server.URL+"/ocsp/")
+			checker := &OCSPChecker{Certificate: leaf, Issuer: issuer}
+
+			_, err := checker.GetOCSPResp()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected an error: %v, got: %v", tt.wantErr, err)
+			}
+			if strings.Join(methods, ",") != strings.Join(tt.wantMethods, ",") {
+				t.Errorf("expected requests %v, got %v", tt.wantMethods, methods)
+			}
+		})
+	}
+}
+
+func TestGetOCSPRespTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	leaf, issuer, _ := newTestPair(t, server.URL)
+	checker := &OCSPChecker{Certificate: leaf, Issuer: issuer, Client: &http.Client{Timeout: 50 * time.Millisecond}}
+
+	start := time.Now()
+	_, err := checker.GetOCSPResp()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a timeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the request to give up promptly, took %v", elapsed)
+	}
+}
@@ -0,0 +1,374 @@
+package proto
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"time"
+)
+
+// field is a single decoded field of a message. bytes holds the value of
+// length-delimited fields, and varint the value of varint and 64-bit fields.
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	varint uint64
+}
+
+// str returns the field as a string, checking its wire type.
+func (f field) str() (string, error) {
+	if f.typ != protowire.BytesType {
+		return "", f.wireTypeError()
+	}
+	return string(f.bytes), nil
+}
+
+// message returns the field as the encoding of an embedded message or bytes,
+// checking its wire type.
+func (f field) message() ([]byte, error) {
+	if f.typ != protowire.BytesType {
+		return nil, f.wireTypeError()
+	}
+	return f.bytes, nil
+}
+
+// int returns the field as a varint, checking its wire type.
+func (f field) int() (int64, error) {
+	if f.typ != protowire.VarintType {
+		return 0, f.wireTypeError()
+	}
+	return int64(f.varint), nil
+}
+
+// double returns the field as a 64-bit float, checking its wire type.
+func (f field) double() (float64, error) {
+	if f.typ != protowire.Fixed64Type {
+		return 0, f.wireTypeError()
+	}
+	return math.Float64frombits(f.varint), nil
+}
+
+func (f field) wireTypeError() error {
+	return fmt.Errorf("field %d has unexpected wire type %d", f.num, f.typ)
+}
+
+// walk calls fn for every varint, 64-bit and length-delimited field in b,
+// skipping fields of any other wire type.
+func walk(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.varint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ != protowire.VarintType && typ != protowire.Fixed64Type && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalCertDetails decodes a CertDetails message. Unknown fields are
+// ignored.
+func UnmarshalCertDetails(b []byte) (*scraper.CertDetails, error) {
+	details := &scraper.CertDetails{}
+	err := walk(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			details.Domain, err = f.str()
+		case 2:
+			details.Serial, err = f.str()
+		case 3:
+			details.NotBefore, err = f.str()
+		case 4:
+			details.NotAfter, err = f.str()
+		case 5:
+			details.Issuer, err = f.str()
+		case 6:
+			var s string
+			s, err = f.str()
+			details.CRL = append(details.CRL, s)
+		case 7:
+			var s string
+			s, err = f.str()
+			details.OCSPServer = append(details.OCSPServer, s)
+		case 8:
+			details.AKI, err = f.str()
+		case 9:
+			details.SKI, err = f.str()
+		case 10, 11:
+			var attr scraper.DNAttribute
+			attr, err = unmarshalDNAttribute(f)
+			if f.num == 10 {
+				details.RawSubject = append(details.RawSubject, attr)
+			} else {
+				details.RawIssuer = append(details.RawIssuer, attr)
+			}
+		case 12:
+			var timings scraper.Timings
+			timings, err = unmarshalTimings(f)
+			details.Timings = &timings
+		case 13:
+			var v int64
+			v, err = f.int()
+			details.Valid = v != 0
+		case 14:
+			var e scraper.ValidationError
+			e, err = unmarshalValidationError(f)
+			details.ValidationErrors = append(details.ValidationErrors, e)
+		case 15:
+			var der []byte
+			if der, err = f.message(); err != nil {
+				return err
+			}
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(der); err != nil {
+				return fmt.Errorf("parsing certificate in chain: %w", err)
+			}
+			details.CertChain = append(details.CertChain, cert)
+		case 16:
+			var summary scraper.CertSummary
+			summary, err = unmarshalCertSummary(f)
+			details.LeafCerts = append(details.LeafCerts, summary)
+		case 17:
+			var key, value string
+			key, value, err = unmarshalMapEntry(f)
+			if details.Metadata == nil {
+				details.Metadata = make(map[string]string)
+			}
+			details.Metadata[key] = value
+		case 18:
+			details.LifetimeRemainingPct, err = f.double()
+		case 19:
+			var s string
+			s, err = f.str()
+			details.CAIssuerURLs = append(details.CAIssuerURLs, s)
+		case 20:
+			var v int64
+			v, err = f.int()
+			details.EffectiveExpiry = time.Unix(0, v).UTC()
+		case 21:
+			var v int64
+			v, err = f.int()
+			details.DaysUntilChainExpiry = int(v)
+		case 22:
+			details.SPKISHA256, err = f.str()
+		case 23:
+			details.Fingerprint, err = f.str()
+		case 24:
+			details.FingerprintSHA1, err = f.str()
+		case 25:
+			var v int64
+			v, err = f.int()
+			details.DaysUntilExpiry = int(v)
+		case 26:
+			var v int64
+			v, err = f.int()
+			details.Expired = v != 0
+		case 27:
+			details.OCSPStatus, err = f.str()
+		case 28:
+			details.OCSPError, err = f.str()
+		case 29:
+			var v int64
+			v, err = f.int()
+			details.Port = int(v)
+		case 30:
+			details.PublicKeyAlgorithm, err = f.str()
+		case 31:
+			var v int64
+			v, err = f.int()
+			details.PublicKeyBits = int(v)
+		case 32:
+			details.SignatureAlgorithm, err = f.str()
+		case 33:
+			var v int64
+			v, err = f.int()
+			details.WeakSignature = v != 0
+		case 34:
+			details.Subject, err = f.str()
+		case 35:
+			details.CommonName, err = f.str()
+		case 36:
+			var s string
+			s, err = f.str()
+			details.SANs = append(details.SANs, s)
+		case 37:
+			var v int64
+			v, err = f.int()
+			details.ChainLength = int(v)
+		case 38:
+			var v int64
+			v, err = f.int()
+			details.ChainComplete = v != 0
+		case 39:
+			var v int64
+			v, err = f.int()
+			details.UsedAIA = v != 0
+		case 40:
+			details.ServerName, err = f.str()
+		case 41:
+			details.CRLStatus, err = f.str()
+		case 42:
+			details.CRLError, err = f.str()
+		case 43:
+			var v int64
+			v, err = f.int()
+			details.HasSCT = v != 0
+		case 44:
+			var v int64
+			v, err = f.int()
+			details.SCTCount = int(v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+func unmarshalDNAttribute(f field) (scraper.DNAttribute, error) {
+	var attr scraper.DNAttribute
+	b, err := f.message()
+	if err != nil {
+		return attr, err
+	}
+	err = walk(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			var rdn int64
+			rdn, err = f.int()
+			attr.RDN = int(rdn)
+		case 2:
+			attr.OID, err = f.str()
+		case 3:
+			attr.Name, err = f.str()
+		case 4:
+			attr.Value, err = f.str()
+		}
+		return err
+	})
+	return attr, err
+}
+
+func unmarshalTimings(f field) (scraper.Timings, error) {
+	var timings scraper.Timings
+	b, err := f.message()
+	if err != nil {
+		return timings, err
+	}
+	err = walk(b, func(f field) error {
+		v, err := f.int()
+		switch f.num {
+		case 1:
+			timings.DNS = time.Duration(v)
+		case 2:
+			timings.Connect = time.Duration(v)
+		case 3:
+			timings.Handshake = time.Duration(v)
+		default:
+			return nil
+		}
+		return err
+	})
+	return timings, err
+}
+
+func unmarshalValidationError(f field) (scraper.ValidationError, error) {
+	var e scraper.ValidationError
+	b, err := f.message()
+	if err != nil {
+		return e, err
+	}
+	err = walk(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			e.Code, err = f.str()
+		case 2:
+			e.Message, err = f.str()
+		case 3:
+			var v int64
+			v, err = f.int()
+			e.Warning = v != 0
+		}
+		return err
+	})
+	return e, err
+}
+
+func unmarshalCertSummary(f field) (scraper.CertSummary, error) {
+	var summary scraper.CertSummary
+	b, err := f.message()
+	if err != nil {
+		return summary, err
+	}
+	err = walk(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			summary.Subject, err = f.str()
+		case 2:
+			summary.Serial, err = f.str()
+		case 3:
+			summary.NotBefore, err = f.str()
+		case 4:
+			summary.NotAfter, err = f.str()
+		case 5:
+			summary.Issuer, err = f.str()
+		case 6:
+			summary.PublicKeyAlgorithm, err = f.str()
+		case 7:
+			var name string
+			name, err = f.str()
+			summary.DNSNames = append(summary.DNSNames, name)
+		}
+		return err
+	})
+	return summary, err
+}
+
+func unmarshalMapEntry(f field) (string, string, error) {
+	var key, value string
+	b, err := f.message()
+	if err != nil {
+		return "", "", err
+	}
+	err = walk(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			key, err = f.str()
+		case 2:
+			value, err = f.str()
+		}
+		return err
+	})
+	return key, value, err
+}
@@ -0,0 +1,83 @@
+package proto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+)
+
+// MaxRecordSize is the largest record a Reader accepts, to bound memory use
+// on corrupt input.
+const MaxRecordSize = 64 << 20
+
+// Writer writes CertDetails as a stream of length-delimited records: each
+// record is the varint encoded size of the message followed by the message,
+// the same framing as Java's writeDelimitedTo.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer that writes records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes details as a single record.
+func (w *Writer) Write(details *scraper.CertDetails) error {
+	msg := MarshalCertDetails(details)
+	record := protowire.AppendVarint(make([]byte, 0, binary.MaxVarintLen64+len(msg)), uint64(len(msg)))
+	_, err := w.w.Write(append(record, msg...))
+	return err
+}
+
+// Reader reads records written by a Writer.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader that reads records from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record. It returns io.EOF when there are no more
+// records, and io.ErrUnexpectedEOF if the stream ends part way through one.
+func (r *Reader) Read() (*scraper.CertDetails, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	if size > MaxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds the maximum of %d", size, MaxRecordSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r.r, msg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return UnmarshalCertDetails(msg)
+}
+
+// ReadAll reads every remaining record.
+func (r *Reader) ReadAll() ([]*scraper.CertDetails, error) {
+	var all []*scraper.CertDetails
+	for {
+		details, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return all, nil
+		}
+		if err != nil {
+			return all, err
+		}
+		all = append(all, details)
+	}
+}
@@ -0,0 +1,176 @@
+// Package proto encodes scraped certificate details as protocol buffers, for
+// pipelines where JSON is too verbose. The schema is in result.proto. The
+// encoding is written by hand on top of protowire so that no generated code
+// or protoc toolchain is needed.
+package proto
+
+import (
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"sort"
+)
+
+// MarshalCertDetails encodes details as a CertDetails message.
+func MarshalCertDetails(details *scraper.CertDetails) []byte {
+	var b []byte
+	b = appendString(b, 1, details.Domain)
+	b = appendString(b, 2, details.Serial)
+	b = appendString(b, 3, details.NotBefore)
+	b = appendString(b, 4, details.NotAfter)
+	b = appendString(b, 5, details.Issuer)
+	b = appendRepeatedString(b, 6, details.CRL)
+	b = appendRepeatedString(b, 7, details.OCSPServer)
+	b = appendString(b, 8, details.AKI)
+	b = appendString(b, 9, details.SKI)
+	for _, attr := range details.RawSubject {
+		b = appendMessage(b, 10, marshalDNAttribute(attr))
+	}
+	for _, attr := range details.RawIssuer {
+		b = appendMessage(b, 11, marshalDNAttribute(attr))
+	}
+	if details.Timings != nil {
+		b = appendMessage(b, 12, marshalTimings(*details.Timings))
+	}
+	b = appendBool(b, 13, details.Valid)
+	for _, e := range details.ValidationErrors {
+		b = appendMessage(b, 14, marshalValidationError(e))
+	}
+	for _, cert := range details.CertChain {
+		b = protowire.AppendTag(b, 15, protowire.BytesType)
+		b = protowire.AppendBytes(b, cert.Raw)
+	}
+	for _, summary := range details.LeafCerts {
+		b = appendMessage(b, 16, marshalCertSummary(summary))
+	}
+
+	// Map entries are written in key order so the output is deterministic.
+	keys := make([]string, 0, len(details.Metadata))
+	for key := range details.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, details.Metadata[key])
+		b = appendMessage(b, 17, entry)
+	}
+	b = appendDouble(b, 18, details.LifetimeRemainingPct)
+	b = appendRepeatedString(b, 19, details.CAIssuerURLs)
+	if !details.EffectiveExpiry.IsZero() {
+		b = appendInt(b, 20, details.EffectiveExpiry.UnixNano())
+	}
+	b = appendInt(b, 21, int64(details.DaysUntilChainExpiry))
+	b = appendString(b, 22, details.SPKISHA256)
+	b = appendString(b, 23, details.Fingerprint)
+	b = appendString(b, 24, details.FingerprintSHA1)
+	b = appendInt(b, 25, int64(details.DaysUntilExpiry))
+	b = appendBool(b, 26, details.Expired)
+	b = appendString(b, 27, details.OCSPStatus)
+	b = appendString(b, 28, details.OCSPError)
+	b = appendInt(b, 29, int64(details.Port))
+	b = appendString(b, 30, details.PublicKeyAlgorithm)
+	b = appendInt(b, 31, int64(details.PublicKeyBits))
+	b = appendString(b, 32, details.SignatureAlgorithm)
+	b = appendBool(b, 33, details.WeakSignature)
+	b = appendString(b, 34, details.Subject)
+	b = appendString(b, 35, details.CommonName)
+	b = appendRepeatedString(b, 36, details.SANs)
+	b = appendInt(b, 37, int64(details.ChainLength))
+	b = appendBool(b, 38, details.ChainComplete)
+	b = appendBool(b, 39, details.UsedAIA)
+	b = appendString(b, 40, details.ServerName)
+	b = appendString(b, 41, details.CRLStatus)
+	b = appendString(b, 42, details.CRLError)
+	b = appendBool(b, 43, details.HasSCT)
+	b = appendInt(b, 44, int64(details.SCTCount))
+	return b
+}
+
+func marshalDNAttribute(attr scraper.DNAttribute) []byte {
+	var b []byte
+	b = appendInt(b, 1, int64(attr.RDN))
+	b = appendString(b, 2, attr.OID)
+	b = appendString(b, 3, attr.Name)
+	b = appendString(b, 4, attr.Value)
+	return b
+}
+
+func marshalTimings(timings scraper.Timings) []byte {
+	var b []byte
+	b = appendInt(b, 1, int64(timings.DNS))
+	b = appendInt(b, 2, int64(timings.Connect))
+	b = appendInt(b, 3, int64(timings.Handshake))
+	return b
+}
+
+func marshalValidationError(e scraper.ValidationError) []byte {
+	var b []byte
+	b = appendString(b, 1, e.Code)
+	b = appendString(b, 2, e.Message)
+	b = appendBool(b, 3, e.Warning)
+	return b
+}
+
+func marshalCertSummary(summary scraper.CertSummary) []byte {
+	var b []byte
+	b = appendString(b, 1, summary.Subject)
+	b = appendString(b, 2, summary.Serial)
+	b = appendString(b, 3, summary.NotBefore)
+	b = appendString(b, 4, summary.NotAfter)
+	b = appendString(b, 5, summary.Issuer)
+	b = appendString(b, 6, summary.PublicKeyAlgorithm)
+	b = appendRepeatedString(b, 7, summary.DNSNames)
+	return b
+}
+
+// appendString appends a string field, leaving it out when empty as proto3
+// does.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendRepeatedString appends every element of a repeated string field.
+func appendRepeatedString(b []byte, num protowire.Number, values []string) []byte {
+	for _, s := range values {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, protowire.EncodeBool(v))
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendMessage appends an embedded message. Unlike scalar fields it is
+// written even when empty, so that repeated messages keep their count.
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,187 @@
+package proto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func testDetails(t *testing.T) *scraper.CertDetails {
+	cert := testCert(t)
+	return &scraper.CertDetails{
+		Domain:             "example.com",
+		Port:               8443,
+		Serial:             "2a",
+		NotBefore:          cert.NotBefore.String(),
+		NotAfter:           cert.NotAfter.String(),
+		Issuer:             "CN=example.com",
+		CRL:                []string{"http://crl.example.com/a.crl", "http://crl.example.com/b.crl"},
+		OCSPServer:         []string{"http://ocsp.example.com"},
+		CAIssuerURLs:       []string{"http://crt.example.com/ca.cer"},
+		AKI:                "aa:bb",
+		SKI:                "cc:dd",
+		SPKISHA256:         "b+7MjBbFVR2f6z61934tp3O/aL2e+cUpJ86yyG5WiSs=",
+		Fingerprint:        "06:29:84:32",
+		FingerprintSHA1:    "CD:1B:50:69",
+		PublicKeyAlgorithm: "ECDSA",
+		PublicKeyBits:      256,
+		SignatureAlgorithm: "SHA1-RSA",
+		WeakSignature:      true,
+		Subject:            "CN=example.com,O=Example",
+		CommonName:         "example.com",
+		SANs:               []string{"example.com", "www.example.com", "192.0.2.1"},
+		ChainLength:        3,
+		ChainComplete:      true,
+		UsedAIA:            true,
+		ServerName:         "api.internal",
+		CRLStatus:          "unknown",
+		CRLError:           "CRL server returned 503 Service Unavailable",
+		HasSCT:             true,
+		SCTCount:           2,
+		RawSubject:         []scraper.DNAttribute{{RDN: 0, OID: "2.5.4.3", Name: "CN", Value: "example.com"}},
+		RawIssuer: []scraper.DNAttribute{
+			{RDN: 0, OID: "2.5.4.6", Name: "C", Value: "GB"},
+			{RDN: 1, OID: "2.5.4.3", Name: "CN", Value: "example.com"},
+		},
+		LeafCerts: []scraper.CertSummary{{
+			Subject:            "CN=example.com",
+			Serial:             "2a",
+			Issuer:             "CN=example.com",
+			PublicKeyAlgorithm: "ECDSA",
+			DNSNames:           []string{"example.com", "www.example.com"},
+		}},
+		Timings: &scraper.Timings{DNS: time.Millisecond, Connect: 2 * time.Millisecond, Handshake: 3 * time.Millisecond},
+		Valid:   true,
+		ValidationErrors: []scraper.ValidationError{
+			{Code: scraper.CodeNoRevocationInfo, Message: "no revocation info", Warning: true},
+		},
+		OCSPStatus: scraper.OCSPStatusUnknown,
+		OCSPError:  "responder unreachable",
+		CertChain:  []*x509.Certificate{cert},
+		Metadata:   map[string]string{"owner": "platform", "team": "edge"},
+
+		LifetimeRemainingPct: 62.5,
+		EffectiveExpiry:      cert.NotAfter,
+		DaysUntilChainExpiry: -3,
+		DaysUntilExpiry:      -3,
+		Expired:              true,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := testDetails(t)
+	got, err := UnmarshalCertDetails(MarshalCertDetails(want))
+	if err != nil {
+		t.Fatalf("UnmarshalCertDetails() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	got, err := UnmarshalCertDetails(MarshalCertDetails(&scraper.CertDetails{}))
+	if err != nil {
+		t.Fatalf("UnmarshalCertDetails() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, &scraper.CertDetails{}) {
+		t.Errorf("got %+v, want empty details", got)
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	details := testDetails(t)
+	first := MarshalCertDetails(details)
+	for i := 0; i < 10; i++ {
+		if !bytes.Equal(MarshalCertDetails(details), first) {
+			t.Fatal("encoding differs between calls")
+		}
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	b := MarshalCertDetails(testDetails(t))
+	if _, err := UnmarshalCertDetails(b[:len(b)-1]); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+	// Field 1 (domain) encoded as a varint instead of a string.
+	if _, err := UnmarshalCertDetails([]byte{0x08, 0x01}); err == nil {
+		t.Error("expected an error for a field with the wrong wire type")
+	}
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	// Field 99 as a fixed32, followed by field 1 (domain).
+	b := []byte{0x9d, 0x06, 1, 2, 3, 4, 0x0a, 0x01, 'a'}
+	got, err := UnmarshalCertDetails(b)
+	if err != nil {
+		t.Fatalf("UnmarshalCertDetails() error = %v", err)
+	}
+	if got.Domain != "a" {
+		t.Errorf("Domain = %q, want %q", got.Domain, "a")
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	records := []*scraper.CertDetails{testDetails(t), {Domain: "b.example.com"}, testDetails(t)}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	got, err := NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("ReadAll() returned %d records that don't match the %d written", len(got), len(records))
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	for i := 0; i < len(records)-1; i++ {
+		if _, err := r.Read(); err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+	}
+	if _, err := r.Read(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Read() of a truncated record error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultAIATimeout bounds each download of an issuer certificate from an AIA
+// URL.
+const DefaultAIATimeout = 10 * time.Second
+
+// maxAIADepth is the most issuer certificates fetched to complete a single
+// chain, so that issuers pointing at each other can't be followed forever.
+const maxAIADepth = 4
+
+// DefaultMaxAIASize is the largest issuer certificate downloaded when
+// WithAIAMaxSize is not set. Real certificates are a few KB.
+const DefaultMaxAIASize = 1 << 20
+
+// errAIATooLarge is returned when an issuer certificate exceeds the size
+// limit.
+var errAIATooLarge = errors.New("AIA issuer certificate exceeds maximum size")
+
+// aiaFetcher downloads the issuer certificates named in the AIA caIssuers
+// URLs of certificates, caching them by URL since many servers share the same
+// intermediates. Failed downloads are not cached.
+type aiaFetcher struct {
+	client  *http.Client
+	maxSize int64
+
+	mu    sync.Mutex
+	cache map[string]*x509.Certificate
+}
+
+func newAIAFetcher() *aiaFetcher {
+	return &aiaFetcher{
+		client:  &http.Client{Timeout: DefaultAIATimeout},
+		maxSize: DefaultMaxAIASize,
+		cache:   make(map[string]*x509.Certificate),
+	}
+}
+
+// completeChain fetches the issuers missing from chain, following the AIA
+// URL of the last certificate sent and then of each issuer fetched, until
+// leaf verifies against roots. It returns the fetched issuers, or nil when
+// the chain still doesn't verify after maxAIADepth of them.
+func (f *aiaFetcher) completeChain(ctx context.Context, leaf *x509.Certificate, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) []*x509.Certificate {
+	if len(chain) == 0 {
+		return nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain {
+		if cert != leaf {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	var fetched []*x509.Certificate
+	current := chain[len(chain)-1]
+	for len(fetched) < maxAIADepth {
+		issuer := f.issuer(ctx, current)
+		if issuer == nil {
+			return nil
+		}
+		fetched = append(fetched, issuer)
+		intermediates.AddCert(issuer)
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now}); err == nil {
+			return fetched
+		}
+		current = issuer
+	}
+	return nil
+}
+
+// issuer returns the first issuer of cert that can be downloaded from its AIA
+// URLs, or nil when none can.
+func (f *aiaFetcher) issuer(ctx context.Context, cert *x509.Certificate) *x509.Certificate {
+	for _, url := range cert.IssuingCertificateURL {
+		f.mu.Lock()
+		issuer, ok := f.cache[url]
+		f.mu.Unlock()
+		if ok {
+			return issuer
+		}
+
+		issuer, err := f.download(ctx, url)
+		if err != nil {
+			continue
+		}
+		f.mu.Lock()
+		f.cache[url] = issuer
+		f.mu.Unlock()
+		return issuer
+	}
+	return nil
+}
+
+// download fetches a DER or PEM encoded certificate from url.
+func (f *aiaFetcher) download(ctx context.Context, url string) (*x509.Certificate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AIA server returned %s", resp.Status)
+	}
+	// Read one byte past the limit so an oversized body can be detected.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, errAIATooLarge
+	}
+	if block, _ := pem.Decode(data); block != nil && block.Type == "CERTIFICATE" {
+		data = block.Bytes
+	}
+	return x509.ParseCertificate(data)
+}
@@ -0,0 +1,159 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithAIA(t *testing.T) {
+	root, rootKey := newTestCA(t, "Test Root")
+	intermediate, intermediateKey := issueTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, root, rootKey)
+
+	var downloads int32
+	aiaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/intermediate.crt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		_, _ = w.Write(intermediate.Raw)
+	}))
+	t.Cleanup(aiaServer.Close)
+
+	leaf, leafKey := issueTestCert(t, &x509.Certificate{
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IssuingCertificateURL: []string{aiaServer.URL + "/missing.crt", aiaServer.URL + "/intermediate.crt"},
+	}, intermediate, intermediateKey)
+
+	// The server sends only its leaf.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"disabled", false},
+		{"enabled", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewScanner(WithPort(port), WithRoots(roots), WithAIA(tt.enabled))
+			details, err := scanner.ScrapeDomain("127.0.0.1")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if details.Valid != tt.enabled || details.UsedAIA != tt.enabled {
+				t.Errorf("expected valid and AIA used: %v, got valid: %v, AIA used: %v %v", tt.enabled, details.Valid, details.UsedAIA, validationCodes(details.ValidationErrors))
+			}
+			if details.ChainComplete {
+				t.Error("expected the chain the server sent to be incomplete")
+			}
+			if len(details.CertChain) != 1 {
+				t.Errorf("expected only the certificates sent to be kept, got %d", len(details.CertChain))
+			}
+		})
+	}
+
+	// A second target with the same issuer reuses the download. Failed
+	// downloads, like the first URL, are tried again.
+	scanner := NewScanner(WithPort(port), WithRoots(roots), WithAIA(true))
+	if _, err := scanner.ScrapeDomain("127.0.0.1"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	before := atomic.LoadInt32(&downloads)
+	if _, err := scanner.ScrapeDomain("127.0.0.1"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if after := atomic.LoadInt32(&downloads); after != before {
+		t.Errorf("expected the cached issuer to be used, got %d more downloads", after-before)
+	}
+}
+
+func TestAIADepthLimit(t *testing.T) {
+	root, _ := newTestCA(t, "Test Root")
+	other, otherKey := newTestCA(t, "Unrelated CA")
+
+	// Every download returns a CA that points back at the same URL, so the
+	// chain never completes.
+	var downloads int32
+	var looping *x509.Certificate
+	aiaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		_, _ = w.Write(looping.Raw)
+	}))
+	t.Cleanup(aiaServer.Close)
+	looping, _ = issueTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Looping CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		IssuingCertificateURL: []string{aiaServer.URL},
+	}, other, otherKey)
+	leaf, _ := issueTestCert(t, &x509.Certificate{
+		DNSNames:              []string{"example.com"},
+		IssuingCertificateURL: []string{aiaServer.URL},
+	}, other, otherKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	fetcher := newAIAFetcher()
+	if issuers := fetcher.completeChain(context.Background(), leaf, []*x509.Certificate{leaf}, roots, time.Now()); issuers != nil {
+		t.Errorf("expected no issuers for a chain that can't complete, got %d", len(issuers))
+	}
+	// The looping issuer is cached after the first download.
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("expected 1 download, got %d", n)
+	}
+}
+
+func TestAIADownloadTooLarge(t *testing.T) {
+	ca, _ := newTestCA(t, "Test CA")
+	aiaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(ca.Raw)
+		_, _ = w.Write(make([]byte, DefaultMaxAIASize))
+	}))
+	t.Cleanup(aiaServer.Close)
+
+	if _, err := newAIAFetcher().download(context.Background(), aiaServer.URL); !errors.Is(err, errAIATooLarge) {
+		t.Errorf("expected errAIATooLarge, got: %v", err)
+	}
+}
+
+func TestWithAIAMaxSize(t *testing.T) {
+	ca, _ := newTestCA(t, "Test CA")
+	aiaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(ca.Raw)
+	}))
+	t.Cleanup(aiaServer.Close)
+
+	s := NewScanner(WithAIA(true), WithAIAMaxSize(int64(len(ca.Raw)-1)))
+	if _, err := s.aia.download(context.Background(), aiaServer.URL); !errors.Is(err, errAIATooLarge) {
+		t.Errorf("expected errAIATooLarge below the configured size, got: %v", err)
+	}
+	s = NewScanner(WithAIA(true), WithAIAMaxSize(int64(len(ca.Raw))))
+	if _, err := s.aia.download(context.Background(), aiaServer.URL); err != nil {
+		t.Errorf("expected a certificate within the configured size to download, got: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ScrapeTLSBatches scrapes websites like ScrapeTLSContext, but hands the
+// results to handle in batches of up to batchSize completed websites instead
+// of returning them all at once, so that a large scan can write out and drop
+// its results as it goes. A batch holds the details of the websites that
+// were scraped and the errors of those that failed, in the order they
+// completed.
+//
+// Every website goes through a single pool of workers, one per unit of
+// concurrency, so a slow website only holds up its own worker rather than
+// the websites after it. handle is called from one goroutine at a time,
+// and the workers wait while it runs once the next batch is full.
+// ScrapeTLSBatches returns once the last batch has been handled.
+//
+// Repeated websites are only scraped once, as described for UniqueTargets.
+func (s *Scanner) ScrapeTLSBatches(ctx context.Context, websites []string, batchSize int, handle func(details []*CertDetails, errs map[string]error)) {
+	s.scrapeBatches(ctx, UniqueTargets(websites), batchSize, handle)
+}
+
+// scrapeBatches is ScrapeTLSBatches without removing repeated websites, so
+// that every entry in websites is scraped.
+func (s *Scanner) scrapeBatches(ctx context.Context, websites []string, batchSize int, handle func(details []*CertDetails, errs map[string]error)) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var details []*CertDetails
+	errs := make(map[string]error)
+	add := func(site string, certInfo *CertDetails, err error) {
+		if err != nil {
+			errs[site] = err
+		} else {
+			details = append(details, certInfo)
+		}
+		if len(details)+len(errs) >= batchSize {
+			handle(details, errs)
+			details = nil
+			errs = make(map[string]error)
+		}
+	}
+
+	var resolved map[string][]net.IP
+	if s.preResolveEnabled {
+		var failed map[string]error
+		resolved, failed = s.preResolve(ctx, websites)
+		remaining := make([]string, 0, len(websites))
+		for _, website := range websites {
+			if err, ok := failed[website]; ok {
+				totalScrapes.WithLabelValues("failed").Inc()
+				s.onResult.call(website, nil, err)
+				add(website, nil, err)
+				continue
+			}
+			remaining = append(remaining, website)
+		}
+		websites = remaining
+	}
+
+	sem := make(chan struct{}, s.concurrency)
+	stopRamp := rampUp(sem, s.rampUp)
+	defer stopRamp()
+
+	queue := make(chan string)
+	go func() {
+		defer close(queue)
+		for _, website := range websites {
+			queue <- website
+		}
+	}()
+
+	type result struct {
+		site    string
+		details *CertDetails
+		err     error
+	}
+	results := make(chan result, s.concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency && i < len(websites); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for site := range queue {
+				certInfo, err := s.scrapeQueued(ctx, sem, site, resolved[site])
+				results <- result{site: site, details: certInfo, err: err}
+			}
+		}()
+	}
+
+	// Close the results channel when all workers are done.
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		add(res.site, res.details, res.err)
+	}
+	if len(details) > 0 || len(errs) > 0 {
+		handle(details, errs)
+	}
+}
+
+// scrapeQueued scrapes site once a concurrency token is free in sem, unless
+// the scan is cancelled while waiting.
+func (s *Scanner) scrapeQueued(ctx context.Context, sem chan struct{}, site string, ips []net.IP) (*CertDetails, error) {
+	err := ctx.Err()
+	if err == nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("scan cancelled: %w", err)
+		s.onResult.call(site, nil, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	certInfo, cached, err := s.scrape(ctx, site, ips)
+
+	<-sem // Release a concurrency token
+
+	if !cached {
+		observeScrape(site, time.Since(start), certInfo, err, s.exemplars)
+	}
+	s.observeExpiry(site, certInfo)
+	if err != nil {
+		s.onResult.call(site, nil, err)
+		return nil, err
+	}
+	s.onResult.call(site, certInfo, nil)
+	return certInfo, nil
+}
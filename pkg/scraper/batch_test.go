@@ -0,0 +1,142 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSlowTarget starts a TLS server that delays the handshake by delay for
+// server names starting with "slow", and returns its port and the overrides
+// that point every name in names at it.
+func newSlowTarget(tb testing.TB, delay time.Duration, names []string) (int, map[string]net.IP) {
+	tb.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if strings.HasPrefix(hello.ServerName, "slow") {
+			time.Sleep(delay)
+		}
+		return nil, nil
+	}}
+	server.StartTLS()
+	tb.Cleanup(server.Close)
+
+	overrides := make(map[string]net.IP, len(names))
+	for _, name := range names {
+		overrides[name] = net.IPv4(127, 0, 0, 1)
+	}
+	return server.Listener.Addr().(*net.TCPAddr).Port, overrides
+}
+
+// mixedTargets returns n target names, every tenth of which is slow.
+func mixedTargets(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		if i%10 == 0 {
+			names[i] = fmt.Sprintf("slow%d.example.com", i)
+		} else {
+			names[i] = fmt.Sprintf("fast%d.example.com", i)
+		}
+	}
+	return names
+}
+
+func TestScrapeTLSBatches(t *testing.T) {
+	host, port := newTestTarget(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	websites, overrides := aliasTargets(host, 6)
+	websites = append(websites[:2], append([]string{"127.0.0.2"}, websites[2:]...)...)
+	scanner := NewScanner(
+		WithConcurrency(2),
+		WithPort(port),
+		WithResolveOverrides(overrides),
+		WithDomainPorts(map[string]int{"127.0.0.2": closedPort}),
+	)
+
+	var sizes []int
+	details, failed := 0, 0
+	scanner.ScrapeTLSBatches(context.Background(), websites, 3, func(batch []*CertDetails, errs map[string]error) {
+		sizes = append(sizes, len(batch)+len(errs))
+		details += len(batch)
+		failed += len(errs)
+	})
+
+	if got := fmt.Sprint(sizes); got != "[3 3 1]" {
+		t.Errorf("expected batches of 3, 3 and 1 targets, got %s", got)
+	}
+	if details != 6 || failed != 1 {
+		t.Errorf("expected 6 results and 1 error, got %d and %d", details, failed)
+	}
+}
+
+func TestScrapeTLSBatchesSlowTarget(t *testing.T) {
+	names := mixedTargets(20)
+	port, overrides := newSlowTarget(t, time.Second, names)
+	scanner := NewScanner(WithConcurrency(2), WithPort(port), WithResolveOverrides(overrides))
+
+	// With chunks of 2 scanned one after the other, the first chunk would
+	// wait for a slow target; with a shared pool the fast ones finish first.
+	start := time.Now()
+	var batches []time.Duration
+	scanner.ScrapeTLSBatches(context.Background(), names, 2, func(batch []*CertDetails, errs map[string]error) {
+		for domain, err := range errs {
+			t.Errorf("unexpected error for %s: %v", domain, err)
+		}
+		batches = append(batches, time.Since(start))
+	})
+	if len(batches) != 10 {
+		t.Fatalf("expected 10 batches, got %d", len(batches))
+	}
+	if batches[0] > 500*time.Millisecond {
+		t.Errorf("expected the first batch before the slow target finished, got it after %v", batches[0])
+	}
+}
+
+// benchmarkScan scrapes b.N mixed targets against a server that delays
+// every tenth handshake, with scan doing the scraping.
+func benchmarkScan(b *testing.B, scan func(scanner *Scanner, websites []string)) {
+	names := mixedTargets(b.N)
+	port, overrides := newSlowTarget(b, 20*time.Millisecond, names)
+	scanner := NewScanner(WithConcurrency(10), WithPort(port), WithResolveOverrides(overrides))
+
+	b.ResetTimer()
+	scan(scanner, names)
+}
+
+// BenchmarkScrapeTLSChunked scans one chunk after another, as scans did
+// before ScrapeTLSBatches, for comparison with BenchmarkScrapeTLSBatches.
+func BenchmarkScrapeTLSChunked(b *testing.B) {
+	benchmarkScan(b, func(scanner *Scanner, websites []string) {
+		for i := 0; i < len(websites); i += 10 {
+			end := i + 10
+			if end > len(websites) {
+				end = len(websites)
+			}
+			if _, err := scanner.ScrapeTLS(websites[i:end]); err != nil {
+				b.Fatalf("expected no error, got: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkScrapeTLSBatches(b *testing.B) {
+	benchmarkScan(b, func(scanner *Scanner, websites []string) {
+		scanner.ScrapeTLSBatches(context.Background(), websites, 10, func(details []*CertDetails, errs map[string]error) {
+			if len(errs) > 0 {
+				b.Fatalf("expected no errors, got: %v", errs)
+			}
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the number of results kept by a result cache when no
+// size is given.
+const DefaultCacheSize = 1024
+
+// cacheKey identifies a scanned target.
+type cacheKey struct {
+	host string
+	port int
+}
+
+type cacheEntry struct {
+	details *CertDetails
+	expires time.Time
+}
+
+// resultCache holds successful scan results for a fixed time to live. When
+// full, expired entries are dropped first and then the entry closest to
+// expiry.
+type resultCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[cacheKey]cacheEntry
+	now        func() time.Time
+}
+
+func newResultCache(ttl time.Duration, maxEntries int) *resultCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheSize
+	}
+	return &resultCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[cacheKey]cacheEntry),
+		now:        time.Now,
+	}
+}
+
+// get returns the cached result for key if it has not expired.
+func (c *resultCache) get(key cacheKey) (*CertDetails, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.details, true
+}
+
+// put stores details for key, evicting an entry if the cache is full.
+func (c *resultCache) put(key cacheKey, details *CertDetails) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry{details: details, expires: now.Add(c.ttl)}
+}
+
+// remove drops the entry for key.
+func (c *resultCache) remove(key cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// evict drops every expired entry, or the entry closest to expiry when none
+// have expired. The caller must hold c.mu.
+func (c *resultCache) evict(now time.Time) {
+	var oldest cacheKey
+	var oldestExpires time.Time
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestExpires.IsZero() || entry.expires.Before(oldestExpires) {
+			oldest, oldestExpires = key, entry.expires
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,122 @@
+package scraper
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestResultCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newResultCache(time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	a := cacheKey{host: "a.example.com", port: 443}
+	b := cacheKey{host: "b.example.com", port: 443}
+	c := cacheKey{host: "c.example.com", port: 443}
+
+	if _, ok := cache.get(a); ok {
+		t.Errorf("expected a miss on an empty cache")
+	}
+
+	cache.put(a, &CertDetails{Domain: a.host})
+	if details, ok := cache.get(a); !ok || details.Domain != a.host {
+		t.Errorf("expected a hit for %s, got %v", a.host, details)
+	}
+	if _, ok := cache.get(cacheKey{host: a.host, port: 8443}); ok {
+		t.Errorf("expected a miss for the same host on another port")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get(a); ok {
+		t.Errorf("expected %s to have expired", a.host)
+	}
+
+	cache.put(a, &CertDetails{Domain: a.host})
+	now = now.Add(time.Second)
+	cache.put(b, &CertDetails{Domain: b.host})
+	cache.put(c, &CertDetails{Domain: c.host})
+	if len(cache.entries) != 2 {
+		t.Errorf("expected the cache to hold 2 entries, got %d", len(cache.entries))
+	}
+	if _, ok := cache.get(a); ok {
+		t.Errorf("expected %s, the entry closest to expiry, to be evicted", a.host)
+	}
+	if _, ok := cache.get(c); !ok {
+		t.Errorf("expected a hit for %s", c.host)
+	}
+
+	cache.remove(c)
+	if _, ok := cache.get(c); ok {
+		t.Errorf("expected a miss for %s after removal", c.host)
+	}
+}
+
+func TestScannerCache(t *testing.T) {
+	var handshakes int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&handshakes, 1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse port: %v", err)
+	}
+
+	scanner := NewScanner(WithPort(port), WithCache(time.Hour, 0))
+	first, err := scanner.ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	scrapes := successfulScrapes(t)
+	second, err := scanner.ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if second != first {
+		t.Errorf("expected the second scrape to return the cached result")
+	}
+	if _, err := scanner.ScrapeTLS([]string{host}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := successfulScrapes(t); got != scrapes {
+		t.Errorf("expected cached results not to be counted as scrapes, went from %v to %v", scrapes, got)
+	}
+
+	if _, err := scanner.Refresh(host); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// The server sees new connections asynchronously, so wait for them.
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&handshakes) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&handshakes); got != 2 {
+		t.Errorf("expected 2 connections, got %d", got)
+	}
+}
+
+// successfulScrapes returns the current count of successful scrapes.
+func successfulScrapes(t *testing.T) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := totalScrapes.WithLabelValues("success").Write(&metric); err != nil {
+		t.Fatalf("failed to read the scrape counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"sort"
+	"strings"
+)
+
+// Canonicalize puts the parts of the details whose order carries no meaning
+// into a stable order, so that scans of the same certificate serialize to
+// identical JSON. Validation errors are sorted by code and message, SANs
+// alphabetically, and leaf certificate summaries by serial with their DNS
+// names sorted. The order of the certificate chain and of distinguished name
+// attributes is meaningful and left alone.
+func (cd *CertDetails) Canonicalize() {
+	sort.SliceStable(cd.ValidationErrors, func(i, j int) bool {
+		a, b := cd.ValidationErrors[i], cd.ValidationErrors[j]
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Message < b.Message
+	})
+
+	sort.Strings(cd.SANs)
+
+	for i := range cd.LeafCerts {
+		// Copy before sorting, the names are shared with the certificate.
+		names := append([]string(nil), cd.LeafCerts[i].DNSNames...)
+		sort.Strings(names)
+		cd.LeafCerts[i].DNSNames = names
+	}
+	sort.SliceStable(cd.LeafCerts, func(i, j int) bool {
+		return cd.LeafCerts[i].Serial < cd.LeafCerts[j].Serial
+	})
+}
+
+// SortDetails sorts details by domain.
+func SortDetails(details []*CertDetails) {
+	sort.SliceStable(details, func(i, j int) bool {
+		return details[i].Domain < details[j].Domain
+	})
+}
+
+// DedupDetails returns details without repeated results for the same domain,
+// port and certificate serial. A single scan never repeats a target, as
+// described for UniqueTargets, but combining the results of separate scans,
+// such as two runs of ScrapeTLS over overlapping lists, can. The first
+// result wins and the order is otherwise kept. Domains are compared
+// case-insensitively. A domain serving different certificates on different
+// connections, or the same certificate on different ports, keeps one result
+// per certificate and port.
+func DedupDetails(details []*CertDetails) []*CertDetails {
+	type key struct {
+		domain string
+		port   int
+		serial string
+	}
+	seen := make(map[key]bool, len(details))
+	deduped := make([]*CertDetails, 0, len(details))
+	for _, detail := range details {
+		k := key{domain: strings.ToLower(detail.Domain), port: detail.Port, serial: detail.Serial}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		deduped = append(deduped, detail)
+	}
+	return deduped
+}
@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	leaf := &x509.Certificate{DNSNames: []string{"www.example.com", "example.com"}}
+	build := func(errs []ValidationError, leaves []CertSummary) *CertDetails {
+		return &CertDetails{Domain: "example.com", ValidationErrors: errs, LeafCerts: leaves}
+	}
+
+	first := build(
+		[]ValidationError{
+			{Code: CodeRedundantSAN, Message: "SAN b is listed more than once", Warning: true},
+			{Code: CodeHostnameMismatch, Message: "mismatch"},
+			{Code: CodeRedundantSAN, Message: "SAN a is listed more than once", Warning: true},
+		},
+		[]CertSummary{{Serial: "2", DNSNames: leaf.DNSNames}, {Serial: "1"}},
+	)
+	second := build(
+		[]ValidationError{
+			{Code: CodeRedundantSAN, Message: "SAN a is listed more than once", Warning: true},
+			{Code: CodeRedundantSAN, Message: "SAN b is listed more than once", Warning: true},
+			{Code: CodeHostnameMismatch, Message: "mismatch"},
+		},
+		[]CertSummary{{Serial: "1"}, {Serial: "2", DNSNames: []string{"example.com", "www.example.com"}}},
+	)
+
+	first.SANs = []string{"www.example.com", "example.com", "192.0.2.1"}
+	second.SANs = []string{"192.0.2.1", "example.com", "www.example.com"}
+
+	first.Canonicalize()
+	second.Canonicalize()
+
+	a, err := json.Marshal(first)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	b, err := json.Marshal(second)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("expected identical JSON, got\n%s\n%s", a, b)
+	}
+
+	if codes := validationCodes(first.ValidationErrors); !reflect.DeepEqual(codes, []string{CodeHostnameMismatch, CodeRedundantSAN, CodeRedundantSAN}) {
+		t.Errorf("expected sorted codes, got %v", codes)
+	}
+	if !reflect.DeepEqual(leaf.DNSNames, []string{"www.example.com", "example.com"}) {
+		t.Errorf("expected the certificate's own DNS names to be left alone, got %v", leaf.DNSNames)
+	}
+}
+
+func TestSortDetails(t *testing.T) {
+	details := []*CertDetails{{Domain: "c.example.com"}, {Domain: "a.example.com"}, {Domain: "b.example.com"}}
+	SortDetails(details)
+	for i, expected := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		if details[i].Domain != expected {
+			t.Errorf("expected %s at %d, got %s", expected, i, details[i].Domain)
+		}
+	}
+}
+
+func TestDedupDetails(t *testing.T) {
+	first := &CertDetails{Domain: "a.example.com", Serial: "1"}
+	details := []*CertDetails{
+		first,
+		{Domain: "b.example.com", Serial: "1"},
+		{Domain: "A.example.com", Serial: "1"},
+		{Domain: "a.example.com", Serial: "2"},
+		{Domain: "a.example.com", Port: 8443, Serial: "1"},
+	}
+	deduped := DedupDetails(details)
+	if len(deduped) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(deduped))
+	}
+	if deduped[0] != first {
+		t.Errorf("expected the first result for a domain and serial to win")
+	}
+	if deduped[2].Serial != "2" {
+		t.Errorf("expected a different certificate for the same domain to be kept, got serial %s", deduped[2].Serial)
+	}
+	if deduped[3].Port != 8443 {
+		t.Errorf("expected the same certificate on a different port to be kept, got port %d", deduped[3].Port)
+	}
+}
+
+func TestDedupDetailsSeparateScans(t *testing.T) {
+	host, port := newTestTarget(t)
+	scanner := NewScanner(WithPort(port))
+	// A repeated target is only scraped once within a scan, so repeats only
+	// come from combining scans.
+	var details []*CertDetails
+	for i := 0; i < 2; i++ {
+		scanned, err := scanner.ScrapeTLS([]string{host, host})
+		if err != nil {
+			t.Fatalf("ScrapeTLS() error = %v", err)
+		}
+		if len(scanned) != 1 {
+			t.Fatalf("expected one result for the repeated target, got %d", len(scanned))
+		}
+		details = append(details, scanned...)
+	}
+	if deduped := DedupDetails(details); len(deduped) != 1 {
+		t.Errorf("expected 1 result after dedup, got %d", len(deduped))
+	}
+}
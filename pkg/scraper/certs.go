@@ -3,25 +3,105 @@
 package scraper
 
 import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"github.com/prometheus/client_golang/prometheus"
 	"net"
-	"sync"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // CertDetails encapsulates various details about a certificate obtained
 // from a scraped domain.
 type CertDetails struct {
-	Domain     string              `json:"domain"`
-	Serial     string              `json:"serial"`
-	NotBefore  string              `json:"not_before"`
-	NotAfter   string              `json:"not_after"`
-	Issuer     string              `json:"issuer"`
-	CRL        []string            `json:"crl"`
-	OCSPServer []string            `json:"ocsp_server"`
-	CertChain  []*x509.Certificate `json:"cert_chain"`
+	Domain             string              `json:"domain"`
+	Port               int                 `json:"port"`
+	ServerName         string              `json:"server_name,omitempty"`
+	Serial             string              `json:"serial"`
+	NotBefore          string              `json:"not_before"`
+	NotAfter           string              `json:"not_after"`
+	Issuer             string              `json:"issuer"`
+	Subject            string              `json:"subject"`
+	CommonName         string              `json:"common_name"`
+	SANs               []string            `json:"sans"`
+	CRL                []string            `json:"crl"`
+	OCSPServer         []string            `json:"ocsp_server"`
+	CAIssuerURLs       []string            `json:"ca_issuer_urls"`
+	AKI                string              `json:"aki"`
+	SKI                string              `json:"ski"`
+	SPKISHA256         string              `json:"spki_sha256"`
+	Fingerprint        string              `json:"fingerprint"`
+	FingerprintSHA1    string              `json:"fingerprint_sha1"`
+	PublicKeyAlgorithm string              `json:"public_key_algorithm"`
+	PublicKeyBits      int                 `json:"public_key_bits"`
+	SignatureAlgorithm string              `json:"signature_algorithm"`
+	WeakSignature      bool                `json:"weak_signature"`
+	RawSubject         []DNAttribute       `json:"raw_subject"`
+	RawIssuer          []DNAttribute       `json:"raw_issuer"`
+	LeafCerts          []CertSummary       `json:"leaf_certs,omitempty"`
+	Timings            *Timings            `json:"timings,omitempty"`
+	Valid              bool                `json:"valid"`
+	ValidationErrors   []ValidationError   `json:"validation_errors,omitempty"`
+	OCSPStatus         string              `json:"ocsp_status,omitempty"`
+	OCSPError          string              `json:"ocsp_error,omitempty"`
+	CRLStatus          string              `json:"crl_status,omitempty"`
+	CRLError           string              `json:"crl_error,omitempty"`
+	HasSCT             bool                `json:"has_sct"`
+	SCTCount           int                 `json:"sct_count"`
+	CertChain          []*x509.Certificate `json:"cert_chain"`
+
+	// ChainLength is the number of certificates the server sent, including
+	// any dropped by the maximum chain depth. ChainComplete is whether they
+	// are enough to verify the leaf against the trusted roots, without
+	// fetching missing intermediates from the AIA URLs as browsers do. It is
+	// false, too, when the chain doesn't verify for other reasons, such as
+	// an expired certificate, which ValidationErrors explains.
+	ChainLength   int  `json:"chain_length"`
+	ChainComplete bool `json:"chain_complete"`
+
+	// UsedAIA is set when the chain could only be verified with issuers
+	// downloaded from AIA URLs, see WithAIA.
+	UsedAIA bool `json:"used_aia"`
+
+	// LifetimeRemainingPct is the share of the leaf's validity period that
+	// is left, from 100 before it becomes valid down to 0 once it expires.
+	LifetimeRemainingPct float64 `json:"lifetime_remaining_pct"`
+
+	// DaysUntilExpiry is the whole days left until the leaf expires, negative
+	// once it has, and Expired whether it has, so that results can be
+	// filtered without parsing NotAfter.
+	DaysUntilExpiry int  `json:"days_until_expiry"`
+	Expired         bool `json:"expired"`
+
+	// EffectiveExpiry is the earliest expiry of any certificate in the chain
+	// the server sent, which is the real deadline for renewing it, and
+	// DaysUntilChainExpiry the whole days left until then.
+	EffectiveExpiry      time.Time `json:"effective_expiry"`
+	DaysUntilChainExpiry int       `json:"days_until_chain_expiry"`
+
+	// Metadata holds caller supplied annotations for the domain, such as its
+	// owner or environment. The scraper itself never sets it.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// tlsVersion is the TLS version negotiated when the certificate was
+	// scraped, when known.
+	tlsVersion uint16
+
+	// connState is the state of the connection the certificate was scraped
+	// over.
+	connState *tls.ConnectionState
+
+	// aiaIssuers are the issuers downloaded to complete the chain, which are
+	// used in validation alongside CertChain.
+	aiaIssuers []*x509.Certificate
+
+	// sentChainLength is the number of certificates the server sent, which
+	// is more than len(CertChain) when the chain was truncated.
+	sentChainLength int
 }
 
 // Dialer is an interface for types that can dial and establish network
@@ -45,17 +125,23 @@ func (cd *CertDetails) GetCertChain() []*x509.Certificate {
 	return cd.CertChain
 }
 
-// fetchFromDomain retrieves the certificate details from the provided domain.
-func (cd *CertDetails) fetchFromDomain(domain string) error {
-	return cd.fetchFromDomainWithDialer(domain, &tls.Dialer{})
+// ConnectionState returns the state of the TLS connection the certificate was
+// scraped over, for debugging handshakes. It reports false for details that
+// were not scraped, such as those decoded from a file.
+func (cd *CertDetails) ConnectionState() (tls.ConnectionState, bool) {
+	if cd.connState == nil {
+		return tls.ConnectionState{}, false
+	}
+	return *cd.connState, true
 }
 
 // fetchFromDomainWithDialer retrieves the certificate details from
-// the provided domain using a custom dialer.
-func (cd *CertDetails) fetchFromDomainWithDialer(domain string, dialer Dialer) error {
-	conn, err := dialer.Dial("tcp", domain+":443")
+// the provided domain and port using a custom dialer. Only the first
+// maxChainDepth certificates the server sent are kept, unless it is zero.
+func (cd *CertDetails) fetchFromDomainWithDialer(domain string, port int, dialer Dialer, maxChainDepth int) error {
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(domain, strconv.Itoa(port)))
 	if err != nil {
-		return err
+		return newScrapeError(domain, err)
 	}
 	defer conn.Close()
 
@@ -69,8 +155,13 @@ func (cd *CertDetails) fetchFromDomainWithDialer(domain string, dialer Dialer) e
 		return fmt.Errorf("expected a ConnectionStateGetter, got %T", conn)
 	}
 
-	certs := tlsGetter.ConnectionState().PeerCertificates
+	state := tlsGetter.ConnectionState()
+	certs, sent := capChain(state.PeerCertificates, maxChainDepth)
 	cd.CertChain = certs
+	cd.sentChainLength = sent
+	cd.ChainLength = sent
+	cd.tlsVersion = state.Version
+	cd.connState = &state
 	if len(certs) == 0 {
 		return fmt.Errorf("no certificates found for domain %s", domain)
 	}
@@ -78,78 +169,103 @@ func (cd *CertDetails) fetchFromDomainWithDialer(domain string, dialer Dialer) e
 	cert := certs[0]
 
 	cd.Domain = domain
+	cd.Port = port
+	// Go never sends an IP address as SNI, even when asked to.
+	if net.ParseIP(state.ServerName) == nil {
+		cd.ServerName = state.ServerName
+	}
 	cd.Serial = cert.SerialNumber.String()
 	cd.NotBefore = cert.NotBefore.String()
 	cd.NotAfter = cert.NotAfter.String()
 	cd.Issuer = cert.Issuer.String()
+	cd.Subject = cert.Subject.String()
+	cd.CommonName = cert.Subject.CommonName
+	cd.SANs = subjectAltNames(cert)
 	cd.CRL = cert.CRLDistributionPoints
 	cd.OCSPServer = cert.OCSPServer
-
-	return nil
-}
-
-// ScrapeTLS scrapes the given websites for TLS certificate details
-// concurrently and returns the collected information.
-func ScrapeTLS(websites []string, concurrency int) ([]*CertDetails, error) {
-	results := make(chan *CertDetails, len(websites))
-	errorChan := make(chan map[string]error, len(websites))
-
-	sem := make(chan struct{}, concurrency)
-
-	var wg sync.WaitGroup
-
-	// For each website, fetch certificate details in a goroutine.
-	for _, website := range websites {
-		wg.Add(1)
-		go func(site string) {
-			defer wg.Done()
-
-			sem <- struct{}{} // Acquire a concurrency token
-
-			timer := prometheus.NewTimer(scrapeDuration.WithLabelValues(site))
-			defer timer.ObserveDuration()
-
-			certInfo := &CertDetails{}
-			err := certInfo.fetchFromDomain(site)
-
-			<-sem // Release a concurrency token
-
-			if err != nil {
-				errorChan <- map[string]error{site: err}
-				totalScrapes.WithLabelValues("failed").Inc()
-				return
-			}
-			totalScrapes.WithLabelValues("success").Inc()
-			results <- certInfo
-		}(website)
+	cd.CAIssuerURLs = cert.IssuingCertificateURL
+	cd.AKI = formatKeyID(cert.AuthorityKeyId)
+	cd.SKI = formatKeyID(cert.SubjectKeyId)
+	cd.SPKISHA256 = spkiSHA256(cert)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	cd.Fingerprint = formatKeyID(sha256Sum[:])
+	sha1Sum := sha1.Sum(cert.Raw)
+	cd.FingerprintSHA1 = formatKeyID(sha1Sum[:])
+	cd.PublicKeyAlgorithm, cd.PublicKeyBits = publicKeyInfo(cert)
+	if cert.SignatureAlgorithm != x509.UnknownSignatureAlgorithm {
+		cd.SignatureAlgorithm = cert.SignatureAlgorithm.String()
 	}
+	cd.WeakSignature = isWeakSignature(cert.SignatureAlgorithm)
+	cd.RawSubject = describeDN(cert.RawSubject, cert.Subject)
+	cd.RawIssuer = describeDN(cert.RawIssuer, cert.Issuer)
+	cd.HasSCT, cd.SCTCount = embeddedSCTs(cert)
 
-	// Close result channels when all scraping goroutines are done.
-	go func() {
-		wg.Wait()
-		close(results)
-		close(errorChan)
-	}()
+	// Only the first certificate is described above. When the server sent
+	// more than one end-entity certificate, list them all so none is missed.
+	if leaves := findLeafCerts(certs); len(leaves) > 1 {
+		cd.LeafCerts = make([]CertSummary, len(leaves))
+		for i, leaf := range leaves {
+			cd.LeafCerts[i] = summarizeCert(leaf)
+		}
+	}
 
-	var details []*CertDetails
+	if timed, ok := conn.(interface{ Timings() Timings }); ok {
+		timings := timed.Timings()
+		cd.Timings = &timings
+	}
 
-	multiError := &MultiError{Errors: make(map[string]error)}
+	return nil
+}
 
-	for res := range results {
-		details = append(details, res)
+// subjectAltNames returns the DNS names and IP addresses in cert's subject
+// alternative names, DNS names first.
+func subjectAltNames(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
 	}
+	return sans
+}
 
-	for err := range errorChan {
-		for domain, e := range err {
-			multiError.Errors[domain] = e
-		}
+// formatKeyID renders a key identifier or fingerprint as colon-separated
+// upper-case hex, matching the format used by openssl.
+func formatKeyID(id []byte) string {
+	parts := make([]string, len(id))
+	for i, b := range id {
+		parts[i] = fmt.Sprintf("%02X", b)
 	}
+	return strings.Join(parts, ":")
+}
 
-	if len(multiError.Errors) > 0 {
-		return details, multiError
-	}
+// Scrape scrapes the given websites for TLS certificate details with a
+// Scanner made from opts, e.g. WithConcurrency, WithPort, WithTimeout and
+// WithRoots, and returns the collected information. See Scanner.ScrapeTLS.
+func Scrape(websites []string, opts ...Option) ([]*CertDetails, error) {
+	return NewScanner(opts...).ScrapeTLS(websites)
+}
+
+// ScrapeContext is like Scrape, but stops scraping when ctx is done. See
+// Scanner.ScrapeTLSContext.
+func ScrapeContext(ctx context.Context, websites []string, opts ...Option) ([]*CertDetails, error) {
+	return NewScanner(opts...).ScrapeTLSContext(ctx, websites)
+}
+
+// ScrapeTLS scrapes the given websites for TLS certificate details
+// concurrently and returns the collected information.
+//
+// Deprecated: Use Scrape with WithConcurrency. ScrapeTLS will be removed in
+// the next release.
+func ScrapeTLS(websites []string, concurrency int) ([]*CertDetails, error) {
+	return Scrape(websites, WithConcurrency(concurrency))
+}
 
-	return details, nil
+// ScrapeTLSContext is like ScrapeTLS, but stops scraping when ctx is done.
+//
+// Deprecated: Use ScrapeContext with WithConcurrency. ScrapeTLSContext will
+// be removed in the next release.
+func ScrapeTLSContext(ctx context.Context, websites []string, concurrency int) ([]*CertDetails, error) {
+	return ScrapeContext(ctx, websites, WithConcurrency(concurrency))
 }
 
 // String provides a string representation of the certificate details.
@@ -160,14 +276,28 @@ func (c *CertDetails) String() string {
 			"NotBefore:%s "+
 			"NotAfter:%s "+
 			"Issuer:%s "+
+			"Subject:%s "+
+			"CommonName:%s "+
+			"SANs:%s "+
 			"CRL:%s "+
-			"OCSPServer:%s",
+			"OCSPServer:%s "+
+			"Fingerprint:%s "+
+			"FingerprintSHA1:%s "+
+			"PublicKeyAlgorithm:%s "+
+			"PublicKeyBits:%d",
 		c.Domain,
 		c.Serial,
 		c.NotBefore,
 		c.NotAfter,
 		c.Issuer,
+		c.Subject,
+		c.CommonName,
+		c.SANs,
 		c.CRL,
 		c.OCSPServer,
+		c.Fingerprint,
+		c.FingerprintSHA1,
+		c.PublicKeyAlgorithm,
+		c.PublicKeyBits,
 	)
 }
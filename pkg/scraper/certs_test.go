@@ -9,21 +9,29 @@ import (
 	"math/big"
 	"net"
 	"runtime/debug"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestCertDetailsString(t *testing.T) {
 	cd := &CertDetails{
-		Domain:     "www.jetbrains.com",
-		Serial:     "12070828292658740519284007523384970881",
-		NotBefore:  "2023-02-28 00:00:00 +0000 UTC",
-		NotAfter:   "2024-02-09 23:59:59 +0000 UTC",
-		Issuer:     "CN=Amazon RSA 2048 M02,O=Amazon,C=US",
-		CRL:        []string{"http://crl.r2m02.amazontrust.com/r2m02.crl"},
-		OCSPServer: []string{"http://ocsp.r2m02.amazontrust.com"},
+		Domain:             "www.jetbrains.com",
+		Serial:             "12070828292658740519284007523384970881",
+		NotBefore:          "2023-02-28 00:00:00 +0000 UTC",
+		NotAfter:           "2024-02-09 23:59:59 +0000 UTC",
+		Issuer:             "CN=Amazon RSA 2048 M02,O=Amazon,C=US",
+		Subject:            "CN=www.jetbrains.com",
+		CommonName:         "www.jetbrains.com",
+		SANs:               []string{"www.jetbrains.com", "jetbrains.com"},
+		CRL:                []string{"http://crl.r2m02.amazontrust.com/r2m02.crl"},
+		OCSPServer:         []string{"http://ocsp.r2m02.amazontrust.com"},
+		Fingerprint:        "06:29:84:32",
+		FingerprintSHA1:    "CD:1B:50:69",
+		PublicKeyAlgorithm: "RSA",
+		PublicKeyBits:      2048,
 	}
-	expected := "Domain:www.jetbrains.com Serial:12070828292658740519284007523384970881 NotBefore:2023-02-28 00:00:00 +0000 UTC NotAfter:2024-02-09 23:59:59 +0000 UTC Issuer:CN=Amazon RSA 2048 M02,O=Amazon,C=US CRL:[http://crl.r2m02.amazontrust.com/r2m02.crl] OCSPServer:[http://ocsp.r2m02.amazontrust.com]"
+	expected := "Domain:www.jetbrains.com Serial:12070828292658740519284007523384970881 NotBefore:2023-02-28 00:00:00 +0000 UTC NotAfter:2024-02-09 23:59:59 +0000 UTC Issuer:CN=Amazon RSA 2048 M02,O=Amazon,C=US Subject:CN=www.jetbrains.com CommonName:www.jetbrains.com SANs:[www.jetbrains.com jetbrains.com] CRL:[http://crl.r2m02.amazontrust.com/r2m02.crl] OCSPServer:[http://ocsp.r2m02.amazontrust.com] Fingerprint:06:29:84:32 FingerprintSHA1:CD:1B:50:69 PublicKeyAlgorithm:RSA PublicKeyBits:2048"
 	if cd.String() != expected {
 		t.Errorf("expected %s \n got %s", expected, cd.String())
 	}
@@ -83,9 +91,11 @@ func generateMockConnectionState() tls.ConnectionState {
 	return tls.ConnectionState{
 		PeerCertificates: []*x509.Certificate{
 			{
-				SerialNumber: big.NewInt(1234567890),
-				NotBefore:    notBefore,
-				NotAfter:     notAfter,
+				Raw:                     []byte("cert"),
+				SerialNumber:            big.NewInt(1234567890),
+				RawSubjectPublicKeyInfo: []byte("spki"),
+				NotBefore:               notBefore,
+				NotAfter:                notAfter,
 				Issuer: pkix.Name{
 					CommonName:   "Amazon RSA 2048 M02",
 					Organization: []string{"Amazon"},
@@ -93,6 +103,9 @@ func generateMockConnectionState() tls.ConnectionState {
 				},
 				CRLDistributionPoints: []string{"http://crl.r2m02.amazontrust.com/r2m02.crl"},
 				OCSPServer:            []string{"http://ocsp.r2m02.amazontrust.com"},
+				IssuingCertificateURL: []string{"http://crt.r2m02.amazontrust.com/r2m02.cer"},
+				AuthorityKeyId:        []byte{0xc0, 0x31, 0x52, 0xcd},
+				SubjectKeyId:          []byte{0x0a, 0xbc, 0x01},
 			},
 		},
 	}
@@ -104,12 +117,19 @@ func TestFetchFromDomainWithDialer(t *testing.T) {
 		dialer             Dialer
 		expectedErr        string
 		expectedDomain     string
+		expectedPort       int
 		expectedSerial     string
 		expectedNotBefore  string
 		expectedNotAfter   string
 		expectedIssuer     string
 		expectedCRL        string
 		expectedOCSPServer string
+		expectedCAIssuers  string
+		expectedAKI        string
+		expectedSKI        string
+		expectedSPKI       string
+		expectedSHA256     string
+		expectedSHA1       string
 	}{
 		{
 			name: "failed to dial",
@@ -124,12 +144,19 @@ func TestFetchFromDomainWithDialer(t *testing.T) {
 				conn: &mockTLSConn{},
 			},
 			expectedDomain:     "example.com",
+			expectedPort:       DefaultPort,
 			expectedSerial:     "1234567890",
 			expectedNotBefore:  "2023-02-28 00:00:00 +0000 UTC",
 			expectedNotAfter:   "2024-02-09 23:59:59 +0000 UTC",
 			expectedIssuer:     "CN=Amazon RSA 2048 M02,O=Amazon,C=US",
 			expectedCRL:        "http://crl.r2m02.amazontrust.com/r2m02.crl",
 			expectedOCSPServer: "http://ocsp.r2m02.amazontrust.com",
+			expectedCAIssuers:  "http://crt.r2m02.amazontrust.com/r2m02.cer",
+			expectedAKI:        "C0:31:52:CD",
+			expectedSKI:        "0A:BC:01",
+			expectedSPKI:       "b+7MjBbFVR2f6z61934tp3O/aL2e+cUpJ86yyG5WiSs=",
+			expectedSHA256:     "06:29:84:32:E8:06:6B:29:E2:22:3B:CC:23:AA:95:04:B5:6A:E5:08:FA:BF:34:35:50:88:69:B9:C3:19:0E:22",
+			expectedSHA1:       "CD:1B:50:69:96:3D:98:78:F9:2F:1C:FB:E7:7F:89:12:CD:D5:B2:ED",
 		},
 	}
 
@@ -143,7 +170,7 @@ func TestFetchFromDomainWithDialer(t *testing.T) {
 			}()
 
 			cd := &CertDetails{}
-			err := cd.fetchFromDomainWithDialer("example.com", tt.dialer)
+			err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, tt.dialer, 0)
 			if tt.expectedErr == "" && err != nil {
 				t.Errorf("expected no error, got: %v", err)
 			} else if tt.expectedErr != "" && (err == nil || err.Error() != tt.expectedErr) {
@@ -154,6 +181,9 @@ func TestFetchFromDomainWithDialer(t *testing.T) {
 			if cd.Domain != tt.expectedDomain {
 				t.Errorf("expected domain %s, got %s", tt.expectedDomain, cd.Domain)
 			}
+			if cd.Port != tt.expectedPort {
+				t.Errorf("expected port %d, got %d", tt.expectedPort, cd.Port)
+			}
 			if cd.Serial != tt.expectedSerial {
 				t.Errorf("expected serial %s, got %s", tt.expectedSerial, cd.Serial)
 			}
@@ -172,6 +202,47 @@ func TestFetchFromDomainWithDialer(t *testing.T) {
 			if len(cd.OCSPServer) > 0 && cd.OCSPServer[0] != tt.expectedOCSPServer {
 				t.Errorf("expected OCSPServer %s, got %s", tt.expectedOCSPServer, cd.OCSPServer[0])
 			}
+			if got := strings.Join(cd.CAIssuerURLs, ","); got != tt.expectedCAIssuers {
+				t.Errorf("expected CA issuer URLs %s, got %s", tt.expectedCAIssuers, got)
+			}
+			if cd.AKI != tt.expectedAKI {
+				t.Errorf("expected AKI %s, got %s", tt.expectedAKI, cd.AKI)
+			}
+			if cd.SKI != tt.expectedSKI {
+				t.Errorf("expected SKI %s, got %s", tt.expectedSKI, cd.SKI)
+			}
+			if cd.SPKISHA256 != tt.expectedSPKI {
+				t.Errorf("expected SPKI SHA-256 %s, got %s", tt.expectedSPKI, cd.SPKISHA256)
+			}
+			if cd.Fingerprint != tt.expectedSHA256 {
+				t.Errorf("expected fingerprint %s, got %s", tt.expectedSHA256, cd.Fingerprint)
+			}
+			if cd.FingerprintSHA1 != tt.expectedSHA1 {
+				t.Errorf("expected SHA-1 fingerprint %s, got %s", tt.expectedSHA1, cd.FingerprintSHA1)
+			}
 		})
 	}
 }
+
+func TestFetchSubjectAndSANs(t *testing.T) {
+	state := generateMockConnectionState()
+	leaf := state.PeerCertificates[0]
+	leaf.Subject = pkix.Name{CommonName: "example.com", Organization: []string{"Example"}}
+	leaf.DNSNames = []string{"example.com", "www.example.com", "*.api.example.com"}
+	leaf.IPAddresses = []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}
+
+	cd := &CertDetails{}
+	if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, &stateDialer{state: state}, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if cd.Subject != "CN=example.com,O=Example" {
+		t.Errorf("expected subject CN=example.com,O=Example, got %s", cd.Subject)
+	}
+	if cd.CommonName != "example.com" {
+		t.Errorf("expected common name example.com, got %s", cd.CommonName)
+	}
+	expected := "example.com,www.example.com,*.api.example.com,192.0.2.1,2001:db8::1"
+	if got := strings.Join(cd.SANs, ","); got != expected {
+		t.Errorf("expected SANs %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// CodeChainTooLong is reported when a server sends more certificates than the
+// maximum chain depth, and the chain has been truncated.
+const CodeChainTooLong = "CHAIN_TOO_LONG"
+
+// DefaultMaxChainDepth is the most certificates kept from a chain unless
+// WithMaxChainDepth says otherwise. Real chains rarely exceed four.
+const DefaultMaxChainDepth = 10
+
+// capChain returns the first maxDepth certificates of chain and the number of
+// certificates in the chain as sent, or the whole chain when it is within the
+// limit or maxDepth is not positive.
+func capChain(chain []*x509.Certificate, maxDepth int) ([]*x509.Certificate, int) {
+	if maxDepth <= 0 || len(chain) <= maxDepth {
+		return chain, len(chain)
+	}
+	return chain[:maxDepth:maxDepth], len(chain)
+}
+
+// chainTooLongError returns the warning for a chain of sent certificates
+// truncated to kept.
+func chainTooLongError(sent, kept int) ValidationError {
+	return ValidationError{
+		Code:    CodeChainTooLong,
+		Message: fmt.Sprintf("server sent %d certificates, only the first %d were kept", sent, kept),
+		Warning: true,
+	}
+}
+
+// chainComplete reports whether leaf verifies against roots, the system pool
+// when nil, using only the intermediates in the chain the server sent.
+// Nothing is fetched from the leaf's AIA URLs, so a server that relies on
+// clients fetching its intermediates is reported as incomplete. Like
+// ValidateCertificate, the name the chain was issued for isn't checked.
+func chainComplete(leaf *x509.Certificate, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) bool {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain {
+		if cert != leaf {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now})
+	return err == nil
+}
@@ -0,0 +1,142 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// loopingChain returns a chain of length certificates: a leaf followed by its
+// issuer repeated, as a buggy or malicious server might send.
+func loopingChain(t *testing.T, length int) []*x509.Certificate {
+	t.Helper()
+	ca, caKey := newTestCA(t, "Test CA")
+	chain := []*x509.Certificate{newTestLeaf(t, ca, caKey, "example.com")}
+	for len(chain) < length {
+		chain = append(chain, ca)
+	}
+	return chain
+}
+
+func TestFetchCapsChainDepth(t *testing.T) {
+	chain := loopingChain(t, 25)
+
+	tests := []struct {
+		name         string
+		maxDepth     int
+		expectedKept int
+	}{
+		{"capped", 10, 10},
+		{"within limit", 30, 25},
+		{"unlimited", 0, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &CertDetails{}
+			dialer := &stateDialer{state: tls.ConnectionState{PeerCertificates: chain}}
+			if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, dialer, tt.maxDepth); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if len(cd.CertChain) != tt.expectedKept {
+				t.Errorf("expected %d certificates kept, got %d", tt.expectedKept, len(cd.CertChain))
+			}
+			if cd.sentChainLength != len(chain) {
+				t.Errorf("expected the sent length to be %d, got %d", len(chain), cd.sentChainLength)
+			}
+			if cd.CertChain[0] != chain[0] {
+				t.Error("expected the leaf to be kept first")
+			}
+		})
+	}
+}
+
+func TestMaxChainDepthWarning(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	leaf, leafKey := issueTestCert(t, &x509.Certificate{DNSNames: []string{"example.com"}}, ca, caKey)
+	raw := [][]byte{leaf.Raw}
+	for len(raw) < 13 {
+		raw = append(raw, ca.Raw)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: raw, PrivateKey: leafKey}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse port: %v", err)
+	}
+
+	details, err := NewScanner(WithPort(port), WithMaxChainDepth(5)).ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(details.CertChain) != 5 {
+		t.Errorf("expected 5 certificates kept, got %d", len(details.CertChain))
+	}
+	found := false
+	for _, e := range details.ValidationErrors {
+		if e.Code == CodeChainTooLong {
+			found = e.Warning
+		}
+	}
+	if !found {
+		t.Errorf("expected a %s warning, got %v", CodeChainTooLong, validationCodes(details.ValidationErrors))
+	}
+}
+
+func TestChainComplete(t *testing.T) {
+	root, rootKey := newTestCA(t, "Test Root")
+	intermediate, intermediateKey := issueTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, root, rootKey)
+	leaf := newTestLeaf(t, intermediate, intermediateKey, "example.com")
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	tests := []struct {
+		name             string
+		chain            []*x509.Certificate
+		maxDepth         int
+		expectedLength   int
+		expectedComplete bool
+	}{
+		{"complete", []*x509.Certificate{leaf, intermediate}, 0, 2, true},
+		{"with root", []*x509.Certificate{leaf, intermediate, root}, 0, 3, true},
+		{"missing intermediate", []*x509.Certificate{leaf}, 0, 1, false},
+		{"intermediate truncated", []*x509.Certificate{leaf, intermediate}, 1, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &CertDetails{}
+			dialer := &stateDialer{state: tls.ConnectionState{PeerCertificates: tt.chain}}
+			if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, dialer, tt.maxDepth); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			cd.validate("example.com", ValidationOptions{Roots: roots})
+
+			if cd.ChainLength != tt.expectedLength {
+				t.Errorf("expected a chain length of %d, got %d", tt.expectedLength, cd.ChainLength)
+			}
+			if cd.ChainComplete != tt.expectedComplete {
+				t.Errorf("expected the chain to be complete: %v, got %v", tt.expectedComplete, cd.ChainComplete)
+			}
+			if cd.Valid != tt.expectedComplete {
+				t.Errorf("expected the certificate to be valid: %v, got %v", tt.expectedComplete, cd.Valid)
+			}
+		})
+	}
+}
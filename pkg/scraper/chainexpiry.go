@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math"
+	"time"
+)
+
+// CodeIntermediateExpiresFirst is reported when a certificate in the chain
+// expires before the leaf, which breaks the chain before the leaf's own
+// expiry date.
+const CodeIntermediateExpiresFirst = "INTERMEDIATE_EXPIRES_FIRST"
+
+// chainExpiry returns the earliest NotAfter of leaf and the certificates in
+// chain, which is when the chain as served stops being valid.
+func chainExpiry(leaf *x509.Certificate, chain []*x509.Certificate) time.Time {
+	expiry := leaf.NotAfter
+	for _, cert := range chain {
+		if cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	return expiry
+}
+
+// daysUntil returns the number of whole days from now until t, rounded down,
+// so that it is negative once t has passed.
+func daysUntil(t, now time.Time) int {
+	return int(math.Floor(t.Sub(now).Hours() / 24))
+}
+
+// checkChainExpiry reports an INTERMEDIATE_EXPIRES_FIRST warning for the
+// first certificate in chain, other than leaf, that expires before leaf.
+func checkChainExpiry(leaf *x509.Certificate, chain []*x509.Certificate) *ValidationError {
+	for _, cert := range chain {
+		if cert == leaf || !cert.NotAfter.Before(leaf.NotAfter) {
+			continue
+		}
+		return &ValidationError{
+			Code:    CodeIntermediateExpiresFirst,
+			Message: fmt.Sprintf("%s expires at %s, before the leaf at %s", cert.Subject, cert.NotAfter, leaf.NotAfter),
+			Warning: true,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateIntermediateExpiresFirst(t *testing.T) {
+	root, rootKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	newIntermediate := func(notAfter time.Time) (*x509.Certificate, *x509.Certificate) {
+		intermediate, intermediateKey := issueTestCert(t, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			NotAfter:              notAfter,
+		}, root, rootKey)
+		return intermediate, newTestLeaf(t, intermediate, intermediateKey, "example.com")
+	}
+
+	now := time.Now()
+	shortIntermediate, shortLeaf := newIntermediate(now.Add(10 * 24 * time.Hour))
+	longIntermediate, longLeaf := newIntermediate(now.Add(365 * 24 * time.Hour))
+
+	tests := []struct {
+		name           string
+		chain          []*x509.Certificate
+		expectedCode   bool
+		expectedExpiry time.Time
+	}{
+		{"intermediate expires first", []*x509.Certificate{shortLeaf, shortIntermediate}, true, shortIntermediate.NotAfter},
+		{"leaf expires first", []*x509.Certificate{longLeaf, longIntermediate}, false, longLeaf.NotAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &CertDetails{CertChain: tt.chain}
+			cd.validate("example.com", ValidationOptions{Roots: roots, CurrentTime: now})
+
+			codes := strings.Join(validationCodes(cd.ValidationErrors), ",")
+			if strings.Contains(codes, CodeIntermediateExpiresFirst) != tt.expectedCode {
+				t.Errorf("expected %s %t, got codes %s", CodeIntermediateExpiresFirst, tt.expectedCode, codes)
+			}
+			if !cd.Valid {
+				t.Errorf("expected the chain to still be valid, got %v", cd.ValidationErrors)
+			}
+			if !cd.EffectiveExpiry.Equal(tt.expectedExpiry) {
+				t.Errorf("expected effective expiry %s, got %s", tt.expectedExpiry, cd.EffectiveExpiry)
+			}
+			if expected := daysUntil(tt.expectedExpiry, now); cd.DaysUntilChainExpiry != expected {
+				t.Errorf("expected %d days until chain expiry, got %d", expected, cd.DaysUntilChainExpiry)
+			}
+		})
+	}
+}
+
+func TestDaysUntil(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at       time.Time
+		expected int
+	}{
+		{now.Add(10*24*time.Hour + time.Hour), 10},
+		{now.Add(time.Hour), 0},
+		{now.Add(-time.Hour), -1},
+	}
+	for _, tt := range tests {
+		if got := daysUntil(tt.at, now); got != tt.expected {
+			t.Errorf("expected %d days until %s, got %d", tt.expected, tt.at, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped, for targets that were skipped because
+// too many targets in the same network had already timed out.
+var ErrCircuitOpen = errors.New("SKIPPED_CIRCUIT_OPEN")
+
+// Default network sizes the circuit breaker groups addresses by.
+const (
+	DefaultCircuitPrefixV4 = 24
+	DefaultCircuitPrefixV6 = 64
+)
+
+// DefaultCircuitCooldown is how long an open circuit skips a network before
+// letting a single target through to test it again.
+const DefaultCircuitCooldown = time.Minute
+
+// circuitBreaker stops dialing into networks that appear to be down. Once
+// threshold targets in a network time out in a row, further targets in it
+// are skipped. After cooldown one target is let through as a trial: if it
+// doesn't time out the circuit closes, and if it does the network is skipped
+// for another cooldown. Any outcome other than a timeout, even a refused
+// connection, shows the network is up and resets the count.
+type circuitBreaker struct {
+	threshold          int
+	prefixV4, prefixV6 int
+	cooldown           time.Duration
+	now                func() time.Time
+
+	mu       sync.Mutex
+	timeouts map[netip.Prefix]int
+	opened   map[netip.Prefix]time.Time
+	trials   map[netip.Prefix]bool
+}
+
+func newCircuitBreaker(threshold, prefixV4, prefixV6 int) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		prefixV4:  prefixV4,
+		prefixV6:  prefixV6,
+		cooldown:  DefaultCircuitCooldown,
+		now:       time.Now,
+		timeouts:  make(map[netip.Prefix]int),
+		opened:    make(map[netip.Prefix]time.Time),
+		trials:    make(map[netip.Prefix]bool),
+	}
+}
+
+// network returns the network ip is grouped into.
+func (b *circuitBreaker) network(ip net.IP) (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := b.prefixV6
+	if addr.Is4() {
+		bits = b.prefixV4
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return prefix, true
+}
+
+// allow returns an error wrapping ErrCircuitOpen when network has timed out
+// too often to be dialled again. Once the cooldown has passed, the next call
+// is let through as the trial and the cooldown starts again for the others.
+func (b *circuitBreaker) allow(network netip.Prefix) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timeouts[network] < b.threshold {
+		return nil
+	}
+	if !b.trials[network] && b.now().Sub(b.opened[network]) >= b.cooldown {
+		b.trials[network] = true
+		b.opened[network] = b.now()
+		return nil
+	}
+	return fmt.Errorf("%w: %d consecutive timeouts in %s", ErrCircuitOpen, b.timeouts[network], network)
+}
+
+// record counts the outcome of dialing a target in network.
+func (b *circuitBreaker) record(network netip.Prefix, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timeouts[network] >= b.threshold {
+		// Dials that were already in flight when the circuit opened don't
+		// close it again; only the trial does.
+		if !b.trials[network] {
+			return
+		}
+		delete(b.trials, network)
+		if IsTimeout(err) {
+			b.opened[network] = b.now()
+			return
+		}
+		delete(b.opened, network)
+		delete(b.timeouts, network)
+		return
+	}
+	if !IsTimeout(err) {
+		delete(b.timeouts, network)
+		return
+	}
+	b.timeouts[network]++
+	if b.timeouts[network] >= b.threshold {
+		b.opened[network] = b.now()
+	}
+}
+
+// targetIP returns the address that will be dialled for domain, when it is
+// known before dialing: a resolve override, a pre-resolved address, or the
+// domain itself when it is an IP address.
+func (s *Scanner) targetIP(domain string, ips []net.IP) net.IP {
+	if ip, ok := s.overrides[domain]; ok {
+		return ip
+	}
+	if len(ips) > 0 {
+		return ips[0]
+	}
+	return net.ParseIP(domain)
+}
@@ -0,0 +1,138 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, DefaultCircuitPrefixV4, DefaultCircuitPrefixV6)
+	network, ok := b.network(net.ParseIP("192.0.2.10"))
+	if !ok || network != netip.MustParsePrefix("192.0.2.0/24") {
+		t.Fatalf("expected 192.0.2.0/24, got %v", network)
+	}
+	other, _ := b.network(net.ParseIP("198.51.100.1"))
+
+	b.record(network, context.DeadlineExceeded)
+	// A refused connection shows the network is up, so the count restarts.
+	b.record(network, syscall.ECONNREFUSED)
+	b.record(network, context.DeadlineExceeded)
+	if err := b.allow(network); err != nil {
+		t.Fatalf("expected the circuit to stay closed after non-consecutive timeouts, got: %v", err)
+	}
+
+	b.record(network, context.DeadlineExceeded)
+	if err := b.allow(network); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got: %v", err)
+	}
+	b.record(network, nil)
+	if err := b.allow(network); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a late success not to close the circuit, got: %v", err)
+	}
+	if err := b.allow(other); err != nil {
+		t.Errorf("expected other networks to be unaffected, got: %v", err)
+	}
+
+	// Once the cooldown has passed a single trial is let through, and a trial
+	// that times out keeps the circuit open for another cooldown.
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	now = now.Add(DefaultCircuitCooldown)
+	if err := b.allow(network); err != nil {
+		t.Fatalf("expected a trial after the cooldown, got: %v", err)
+	}
+	if err := b.allow(network); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected only one trial at a time, got: %v", err)
+	}
+	b.record(network, context.DeadlineExceeded)
+	if err := b.allow(network); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected a timed out trial to keep the circuit open, got: %v", err)
+	}
+	now = now.Add(DefaultCircuitCooldown)
+	if err := b.allow(network); err != nil {
+		t.Fatalf("expected another trial after the next cooldown, got: %v", err)
+	}
+	b.record(network, nil)
+	if err := b.allow(network); err != nil {
+		t.Errorf("expected a successful trial to close the circuit, got: %v", err)
+	}
+
+	if network, _ := b.network(net.ParseIP("2001:db8:1:2:3::4")); network != netip.MustParsePrefix("2001:db8:1:2::/64") {
+		t.Errorf("expected 2001:db8:1:2::/64, got %v", network)
+	}
+	if _, ok := b.network(nil); ok {
+		t.Error("expected no network for an unknown address")
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	port := newSilentTarget(t)
+
+	targets := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.1.1"}
+	timeouts := make(map[string]time.Duration)
+	for _, target := range targets {
+		timeouts[target] = 100 * time.Millisecond
+	}
+	scanner := NewScanner(
+		WithPort(port),
+		WithConcurrency(1),
+		WithDomainTimeouts(timeouts),
+		WithCircuitBreaker(2, DefaultCircuitPrefixV4, DefaultCircuitPrefixV6),
+	)
+
+	_, err := scanner.ScrapeTLS(targets)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != len(targets) {
+		t.Fatalf("expected every target to fail, got: %v", err)
+	}
+	skipped := 0
+	for target, err := range multiErr.Errors {
+		if errors.Is(err, ErrCircuitOpen) {
+			skipped++
+			if target == "127.0.1.1" {
+				t.Errorf("expected %s, in another network, not to be skipped", target)
+			}
+		} else if !IsTimeout(err) {
+			t.Errorf("expected %s to time out, got: %v", target, err)
+		}
+	}
+	if skipped != 2 {
+		t.Errorf("expected 2 targets to be skipped, got %d: %v", skipped, multiErr.Errors)
+	}
+}
+
+func TestWithCircuitCooldownReusedScanner(t *testing.T) {
+	silentPort := newSilentTarget(t)
+	_, livePort := newTestTarget(t)
+
+	const cooldown = 200 * time.Millisecond
+	scanner := NewScanner(
+		WithConcurrency(1),
+		WithDomainPorts(map[string]int{"127.0.0.2": silentPort, "127.0.0.3": silentPort, "127.0.0.1": livePort}),
+		WithDomainTimeouts(map[string]time.Duration{"127.0.0.2": 100 * time.Millisecond, "127.0.0.3": 100 * time.Millisecond}),
+		WithCircuitBreaker(2, DefaultCircuitPrefixV4, DefaultCircuitPrefixV6),
+		WithCircuitCooldown(cooldown),
+	)
+
+	// The first run opens the circuit for 127.0.0.0/24, and a later run on
+	// the same scanner, as in watch mode, skips the network until the
+	// cooldown has passed.
+	if _, err := scanner.ScrapeTLS([]string{"127.0.0.2", "127.0.0.3"}); err == nil {
+		t.Fatal("expected the silent targets to time out")
+	}
+	if _, err := scanner.ScrapeDomain("127.0.0.1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen before the cooldown, got: %v", err)
+	}
+	time.Sleep(cooldown)
+	if _, err := scanner.ScrapeTLS([]string{"127.0.0.1"}); err != nil {
+		t.Fatalf("expected the network to be tried again after the cooldown, got: %v", err)
+	}
+	if _, err := scanner.ScrapeDomain("127.0.0.1"); err != nil {
+		t.Errorf("expected the circuit to stay closed after a successful trial, got: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// CodeCNNotInSAN is reported when the subject common name is not also listed
+// as a SAN. Browsers ignore the CN, but RFC 5280 compliant certificates
+// repeat it in the SANs and some older clients only check the CN.
+const CodeCNNotInSAN = "CN_NOT_IN_SAN"
+
+// checkCNInSAN reports a CN_NOT_IN_SAN warning when cert has a common name
+// that is not one of its DNS or IP address SANs.
+func checkCNInSAN(cert *x509.Certificate) *ValidationError {
+	cn := cert.Subject.CommonName
+	if cn == "" {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, cn) {
+			return nil
+		}
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		for _, san := range cert.IPAddresses {
+			if san.Equal(ip) {
+				return nil
+			}
+		}
+	}
+	return &ValidationError{
+		Code:    CodeCNNotInSAN,
+		Message: fmt.Sprintf("common name %s is not listed as a SAN", cn),
+		Warning: true,
+	}
+}
@@ -0,0 +1,52 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestValidateCertificateCNNotInSAN(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	issue := func(cn string, dnsNames []string, ips []net.IP) *x509.Certificate {
+		cert, _ := issueTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: cn},
+			DNSNames:    dnsNames,
+			IPAddresses: ips,
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			OCSPServer:  []string{"http://ocsp.example.com"},
+		}, ca, caKey)
+		return cert
+	}
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		expected bool
+	}{
+		{"CN in SAN", issue("example.com", []string{"example.com", "www.example.com"}, nil), false},
+		{"CN in SAN with different case", issue("Example.COM", []string{"example.com"}, nil), false},
+		{"CN not in SAN", issue("legacy.example.com", []string{"example.com"}, nil), true},
+		{"no CN", issue("", []string{"example.com"}, nil), false},
+		{"IP CN in IP SAN", issue("192.0.2.1", []string{"example.com"}, []net.IP{net.ParseIP("192.0.2.1")}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, errs := ValidateCertificate(tt.cert, []*x509.Certificate{tt.cert}, "example.com", ValidationOptions{Roots: roots})
+			codes := strings.Join(validationCodes(errs), ",")
+			if strings.Contains(codes, CodeCNNotInSAN) != tt.expected {
+				t.Errorf("expected %s %t, got codes %s", CodeCNNotInSAN, tt.expected, codes)
+			}
+			if !valid {
+				t.Errorf("expected %s to be a warning only, got %v", CodeCNNotInSAN, errs)
+			}
+		})
+	}
+}
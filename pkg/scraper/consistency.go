@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// CodeInconsistentCerts is reported in consistency check mode when repeated
+// handshakes with the same target return different leaf certificates, as
+// happens when a rollout has only reached part of a load balanced pool.
+const CodeInconsistentCerts = "INCONSISTENT_CERTS"
+
+// checkConsistency makes attempts-1 further handshakes with domain on port,
+// using the same dialer settings as the first, and reports an INCONSISTENT_CERTS error
+// listing the serials seen when any returns a different leaf certificate to
+// first. Handshakes that fail are not counted.
+func (s *Scanner) checkConsistency(ctx context.Context, domain string, port int, serverName string, ips []net.IP, minVersion uint16, first *leafIdentity, attempts int) *ValidationError {
+	address := net.JoinHostPort(domain, strconv.Itoa(port))
+	seen := map[[sha256.Size]byte]bool{first.fingerprint: true}
+	serials := []string{first.serial}
+	for i := 1; i < attempts; i++ {
+		leaf, err := s.handshakeLeaf(ctx, domain, serverName, ips, minVersion, address)
+		if err != nil || seen[leaf.fingerprint] {
+			continue
+		}
+		seen[leaf.fingerprint] = true
+		serials = append(serials, leaf.serial)
+	}
+	if len(serials) == 1 {
+		return nil
+	}
+	return &ValidationError{
+		Code:    CodeInconsistentCerts,
+		Message: fmt.Sprintf("%d handshakes returned %d different certificates, with serials %s", attempts, len(serials), strings.Join(serials, ", ")),
+	}
+}
+
+// leafIdentity identifies the leaf certificate returned by a handshake.
+type leafIdentity struct {
+	fingerprint [sha256.Size]byte
+	serial      string
+}
+
+// identify returns the identity of the leaf certificate cert.
+func identify(cert *x509.Certificate) *leafIdentity {
+	return &leafIdentity{fingerprint: sha256.Sum256(cert.Raw), serial: cert.SerialNumber.String()}
+}
+
+// handshakeLeaf makes a single handshake with address and returns the leaf
+// certificate the server sent.
+func (s *Scanner) handshakeLeaf(ctx context.Context, domain, serverName string, ips []net.IP, minVersion uint16, address string) (*leafIdentity, error) {
+	conn, err := s.dialer(ctx, domain, serverName, ips, minVersion).Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tlsConn, ok := conn.(interface{ ConnectionState() tls.ConnectionState })
+	if !ok {
+		return nil, fmt.Errorf("expected a ConnectionStateGetter, got %T", conn)
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found for domain %s", domain)
+	}
+	return identify(certs[0]), nil
+}
@@ -0,0 +1,85 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newRotatingTestTarget starts a TLS server that serves the given leaf
+// certificates in turn, one per connection, and returns its host and port.
+func newRotatingTestTarget(t *testing.T, leaves int) (string, int, []*x509.Certificate) {
+	t.Helper()
+	ca, caKey := newTestCA(t, "Test CA")
+	var certs []*x509.Certificate
+	var tlsCerts []tls.Certificate
+	for i := 0; i < leaves; i++ {
+		cert, key := issueTestCert(t, &x509.Certificate{DNSNames: []string{"example.com"}}, ca, caKey)
+		certs = append(certs, cert)
+		tlsCerts = append(tlsCerts, tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key})
+	}
+
+	var handshakes int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The certificate is chosen per connection rather than with
+	// GetCertificate, which is skipped when no SNI is sent for an IP address.
+	server.TLS = &tls.Config{GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		n := atomic.AddInt64(&handshakes, 1) - 1
+		return &tls.Config{Certificates: []tls.Certificate{tlsCerts[int(n)%len(tlsCerts)]}}, nil
+	}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, certs
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		leaves       int
+		attempts     int
+		inconsistent bool
+	}{
+		{"consistent", 1, 3, false},
+		{"rotating", 2, 3, true},
+		{"single attempt", 2, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, certs := newRotatingTestTarget(t, tt.leaves)
+
+			details, err := NewScanner(WithPort(port), WithConsistencyCheck(tt.attempts)).ScrapeDomain(host)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			var found *ValidationError
+			for i := range details.ValidationErrors {
+				if details.ValidationErrors[i].Code == CodeInconsistentCerts {
+					found = &details.ValidationErrors[i]
+				}
+			}
+			if (found != nil) != tt.inconsistent {
+				t.Fatalf("expected inconsistent %v, got codes %v", tt.inconsistent, validationCodes(details.ValidationErrors))
+			}
+			if found == nil {
+				return
+			}
+			if details.Valid {
+				t.Error("expected inconsistent certificates to make the result invalid")
+			}
+			for _, cert := range certs {
+				if !strings.Contains(found.Message, cert.SerialNumber.String()) {
+					t.Errorf("expected serial %s in %q", cert.SerialNumber, found.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package scraper
+
+// CRL statuses recorded in CertDetails.CRLStatus.
+const (
+	CRLStatusGood    = "good"
+	CRLStatusRevoked = "revoked"
+	CRLStatusUnknown = "unknown"
+)
+
+// checkCRL looks the leaf up in the CRL it names and records its revocation
+// status on the details. Failures, such as an unreachable CRL server, are
+// recorded as an unknown status with the reason in CRLError rather than
+// failing the scrape.
+func (s *Scanner) checkCRL(cd *CertDetails) {
+	if len(cd.CertChain) < 2 {
+		cd.CRLStatus = CRLStatusUnknown
+		cd.CRLError = "the server did not send the issuer certificate"
+		return
+	}
+
+	revoked, err := s.crl.IsRevoked(cd.GetLeafCert(), cd.GetIssuerCert())
+	switch {
+	case err != nil:
+		cd.CRLStatus = CRLStatusUnknown
+		cd.CRLError = err.Error()
+	case revoked:
+		cd.CRLStatus = CRLStatusRevoked
+	default:
+		cd.CRLStatus = CRLStatusGood
+	}
+}
@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckCRL(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{SerialNumber: big.NewInt(1000), RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ca.crl" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(der)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		serial         int64
+		crlPath        string
+		issuerInChain  bool
+		expectedStatus string
+		expectError    bool
+	}{
+		{"good", 1001, "/ca.crl", true, CRLStatusGood, false},
+		{"revoked", 1000, "/ca.crl", true, CRLStatusRevoked, false},
+		{"server error", 1000, "/missing.crl", true, CRLStatusUnknown, true},
+		{"no issuer", 1000, "/ca.crl", false, CRLStatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf, _ := issueTestCert(t, &x509.Certificate{
+				SerialNumber:          big.NewInt(tt.serial),
+				Subject:               pkix.Name{CommonName: "example.com"},
+				DNSNames:              []string{"example.com"},
+				CRLDistributionPoints: []string{server.URL + tt.crlPath},
+			}, ca, caKey)
+
+			cd := &CertDetails{CertChain: []*x509.Certificate{leaf}}
+			if tt.issuerInChain {
+				cd.CertChain = append(cd.CertChain, ca)
+			}
+			NewScanner(WithCRL(true)).checkCRL(cd)
+			if cd.CRLStatus != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, cd.CRLStatus)
+			}
+			if tt.expectError != (cd.CRLError != "") {
+				t.Errorf("expected an error: %v, got %q", tt.expectError, cd.CRLError)
+			}
+		})
+	}
+}
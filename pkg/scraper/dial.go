@@ -0,0 +1,169 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// stepDialer is a Dialer that resolves, connects and performs the TLS
+// handshake as separate steps, so that each step can be timed and data can be
+// written to the connection before the handshake starts.
+type stepDialer struct {
+	// timeout bounds the whole dial, including the handshake. A zero value
+	// means no timeout.
+	timeout time.Duration
+
+	// config is used as a template for the TLS client configuration. The
+	// ServerName is taken from the dialled address when not set.
+	config *tls.Config
+
+	// timings, when set, makes Dial return a *timedConn carrying the
+	// duration of each step.
+	timings bool
+
+	// preamble, when set, is called on the TCP connection before the TLS
+	// handshake.
+	preamble func(conn net.Conn) error
+
+	// dial, when set, opens the TCP connection instead of resolving the host
+	// and connecting to it directly, e.g. through a proxy. No DNS time is
+	// recorded then.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// timedConn is a TLS connection annotated with the timings of its setup.
+type timedConn struct {
+	*tls.Conn
+	timings Timings
+}
+
+// Timings returns the timings recorded while establishing the connection.
+func (c *timedConn) Timings() Timings {
+	return c.timings
+}
+
+// Dial connects to the address and performs a TLS handshake, recording how
+// long each step took.
+func (d *stepDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext is like Dial, but gives up when ctx is done.
+func (d *stepDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var timings Timings
+	var netConn net.Conn
+	if d.dial != nil {
+		start := time.Now()
+		netConn, err = d.dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		timings.Connect = time.Since(start)
+	} else {
+		netConn, err = resolveAndConnect(ctx, network, host, port, &timings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.preamble != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = netConn.SetDeadline(deadline)
+		}
+		if err := d.preamble(netConn); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		_ = netConn.SetDeadline(time.Time{})
+	}
+
+	config := &tls.Config{}
+	if d.config != nil {
+		config = d.config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	start := time.Now()
+	tlsConn := tls.Client(netConn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	timings.Handshake = time.Since(start)
+
+	if !d.timings {
+		return tlsConn, nil
+	}
+	return &timedConn{Conn: tlsConn, timings: timings}, nil
+}
+
+// resolveAndConnect resolves host and connects to the first of its addresses
+// that accepts, recording how long each step took in timings.
+func resolveAndConnect(ctx context.Context, network, host, port string, timings *Timings) (net.Conn, error) {
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	timings.DNS = time.Since(start)
+
+	start = time.Now()
+	var netConn net.Conn
+	netDialer := &net.Dialer{}
+	for _, addr := range addrs {
+		netConn, err = netDialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	timings.Connect = time.Since(start)
+	return netConn, nil
+}
+
+// dialContextOf returns the DialContext method of dialer if it has one, and
+// otherwise its Dial method, which ignores the context.
+func dialContextOf(dialer Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if d, ok := dialer.(interface {
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	}); ok {
+		return d.DialContext
+	}
+	return func(_ context.Context, network, address string) (net.Conn, error) {
+		return dialer.Dial(network, address)
+	}
+}
+
+// contextDialer is a Dialer that binds a context to a dial function, so that
+// dials made through the Dialer interface are abandoned when the context is
+// done.
+type contextDialer struct {
+	ctx  context.Context
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Dial dials the address with the bound context.
+func (d *contextDialer) Dial(network, address string) (net.Conn, error) {
+	return d.dial(d.ctx, network, address)
+}
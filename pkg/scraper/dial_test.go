@@ -0,0 +1,38 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStepDialerTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	address := strings.TrimPrefix(server.URL, "https://")
+	dialer := &stepDialer{config: &tls.Config{RootCAs: roots}, timings: true}
+
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer conn.Close()
+
+	timed, ok := conn.(*timedConn)
+	if !ok {
+		t.Fatalf("expected a *timedConn, got %T", conn)
+	}
+	if timed.Timings().Handshake <= 0 {
+		t.Errorf("expected a positive handshake duration, got %s", timed.Timings().Handshake)
+	}
+	if len(timed.ConnectionState().PeerCertificates) == 0 {
+		t.Errorf("expected peer certificates to be available")
+	}
+}
@@ -0,0 +1,48 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+)
+
+// CodeDistrustedRoot is reported when every verified chain of a certificate
+// ends in a root on the distrust list, such as a CA that browsers have
+// announced they will stop trusting.
+const CodeDistrustedRoot = "DISTRUSTED_ROOT"
+
+// checkDistrustedRoots reports a DISTRUSTED_ROOT validation error when chains
+// is non-empty and every chain terminates in a root whose fingerprint is in
+// distrusted. A chain to another root means the certificate is still trusted
+// through that path. Fingerprints are in the "sha256:HEX" pin format.
+func checkDistrustedRoots(chains [][]*x509.Certificate, distrusted []string) *ValidationError {
+	if len(chains) == 0 || len(distrusted) == 0 {
+		return nil
+	}
+	listed := make(map[string]bool, len(distrusted))
+	for _, fingerprint := range distrusted {
+		if normalized, err := NormalizePin(fingerprint); err == nil {
+			listed[normalized] = true
+		}
+	}
+
+	var root *x509.Certificate
+	for _, chain := range chains {
+		if len(chain) == 0 {
+			continue
+		}
+		root = chain[len(chain)-1]
+		sum := sha256.Sum256(root.Raw)
+		if !listed[hex.EncodeToString(sum[:])] {
+			return nil
+		}
+	}
+	if root == nil {
+		return nil
+	}
+	return &ValidationError{
+		Code:    CodeDistrustedRoot,
+		Message: fmt.Sprintf("certificate chains to distrusted root %s", root.Subject),
+	}
+}
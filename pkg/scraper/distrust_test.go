@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func fingerprintPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return pinPrefix + hex.EncodeToString(sum[:])
+}
+
+func TestValidateCertificateDistrustedRoot(t *testing.T) {
+	ca, caKey := newTestCA(t, "Distrusted Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leaf := newTestLeaf(t, ca, caKey, "example.com")
+	other, _ := newTestCA(t, "Other Root CA")
+
+	tests := []struct {
+		name       string
+		distrusted []string
+		expected   bool
+	}{
+		{"root distrusted", []string{fingerprintPin(ca)}, true},
+		{"root distrusted in upper case", []string{strings.ToUpper(fingerprintPin(ca))}, true},
+		{"other root distrusted", []string{fingerprintPin(other)}, false},
+		{"no distrust list", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, errs := ValidateCertificate(leaf, []*x509.Certificate{leaf}, "example.com", ValidationOptions{Roots: roots, DistrustedRoots: tt.distrusted})
+			codes := strings.Join(validationCodes(errs), ",")
+			if strings.Contains(codes, CodeDistrustedRoot) != tt.expected {
+				t.Errorf("expected %s %t, got codes %s", CodeDistrustedRoot, tt.expected, codes)
+			}
+			if valid == tt.expected {
+				t.Errorf("expected valid %t, got %t", !tt.expected, valid)
+			}
+		})
+	}
+}
+
+func TestCheckDistrustedRootsAlternateChain(t *testing.T) {
+	distrustedRoot, _ := newTestCA(t, "Distrusted Root CA")
+	trustedRoot, _ := newTestCA(t, "Trusted Root CA")
+	leaf := &x509.Certificate{Raw: []byte("leaf")}
+	distrusted := []string{fingerprintPin(distrustedRoot)}
+
+	chains := [][]*x509.Certificate{{leaf, distrustedRoot}, {leaf, trustedRoot}}
+	if err := checkDistrustedRoots(chains, distrusted); err != nil {
+		t.Errorf("expected no error when another chain avoids the distrusted root, got %v", err)
+	}
+	if err := checkDistrustedRoots(chains[:1], distrusted); err == nil || err.Code != CodeDistrustedRoot {
+		t.Errorf("expected %s when the only chain ends in a distrusted root, got %v", CodeDistrustedRoot, err)
+	}
+}
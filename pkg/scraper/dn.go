@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+// DNAttribute is a single attribute of a distinguished name.
+type DNAttribute struct {
+	// RDN is the index of the relative distinguished name the attribute
+	// belongs to. Attributes of a multi-valued RDN share the same index.
+	RDN int `json:"rdn"`
+
+	// OID is the dotted attribute type, e.g. "2.5.4.3".
+	OID string `json:"oid"`
+
+	// Name is the short name of the attribute type, when known.
+	Name string `json:"name,omitempty"`
+
+	// Value is the attribute value.
+	Value string `json:"value"`
+}
+
+// dnAttributeNames maps attribute type OIDs to their short names, including
+// the uncommon attributes used in EV certificates.
+var dnAttributeNames = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.4":                    "SN",
+	"2.5.4.5":                    "serialNumber",
+	"2.5.4.6":                    "C",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.9":                    "STREET",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.12":                   "title",
+	"2.5.4.15":                   "businessCategory",
+	"2.5.4.17":                   "postalCode",
+	"2.5.4.42":                   "GN",
+	"2.5.4.97":                   "organizationIdentifier",
+	"0.9.2342.19200300.100.1.1":  "UID",
+	"0.9.2342.19200300.100.1.25": "DC",
+	"1.2.840.113549.1.9.1":       "emailAddress",
+	"1.3.6.1.4.1.311.60.2.1.1":   "jurisdictionL",
+	"1.3.6.1.4.1.311.60.2.1.2":   "jurisdictionST",
+	"1.3.6.1.4.1.311.60.2.1.3":   "jurisdictionC",
+}
+
+// describeDN returns every attribute of a distinguished name in certificate
+// order. The DER encoding is preferred since it preserves multi-valued RDNs
+// and unknown attributes; name is used when raw is empty or malformed.
+func describeDN(raw []byte, name pkix.Name) []DNAttribute {
+	var seq pkix.RDNSequence
+	if rest, err := asn1.Unmarshal(raw, &seq); err != nil || len(rest) > 0 {
+		seq = name.ToRDNSequence()
+	}
+
+	var attributes []DNAttribute
+	for i, rdn := range seq {
+		for _, atv := range rdn {
+			oid := atv.Type.String()
+			attributes = append(attributes, DNAttribute{
+				RDN:   i,
+				OID:   oid,
+				Name:  dnAttributeNames[oid],
+				Value: fmt.Sprint(atv.Value),
+			})
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"reflect"
+	"testing"
+)
+
+func TestDescribeDNWithEVSubject(t *testing.T) {
+	ca, caKey := newTestCA(t, "EV Test CA")
+	leaf, _ := issueTestCert(t, &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   "example.com",
+			Organization: []string{"Example Inc"},
+			Country:      []string{"US"},
+			SerialNumber: "5157550",
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: asn1.ObjectIdentifier{2, 5, 4, 15}, Value: "Private Organization"},
+				{Type: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 3}, Value: "US"},
+				{Type: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 2}, Value: "Delaware"},
+			},
+		},
+		DNSNames: []string{"example.com"},
+	}, ca, caKey)
+
+	attributes := describeDN(leaf.RawSubject, leaf.Subject)
+
+	expected := map[string]string{
+		"CN":               "example.com",
+		"O":                "Example Inc",
+		"C":                "US",
+		"serialNumber":     "5157550",
+		"businessCategory": "Private Organization",
+		"jurisdictionC":    "US",
+		"jurisdictionST":   "Delaware",
+	}
+	got := make(map[string]string)
+	for _, attribute := range attributes {
+		got[attribute.Name] = attribute.Value
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v \n got %v", expected, got)
+	}
+}
+
+func TestDescribeDNMultiValuedRDN(t *testing.T) {
+	seq := pkix.RDNSequence{
+		{{Type: asn1.ObjectIdentifier{2, 5, 4, 6}, Value: "GB"}},
+		{
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 10}, Value: "Example"},
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 11}, Value: "Ops"},
+		},
+	}
+	raw, err := asn1.Marshal(seq)
+	if err != nil {
+		t.Fatalf("failed to marshal RDN sequence: %v", err)
+	}
+
+	// DER sorts the members of a SET, so the multi-valued RDN comes back in
+	// encoded order rather than the order it was built in.
+	attributes := describeDN(raw, pkix.Name{})
+	expected := []DNAttribute{
+		{RDN: 0, OID: "2.5.4.6", Name: "C", Value: "GB"},
+		{RDN: 1, OID: "2.5.4.11", Name: "OU", Value: "Ops"},
+		{RDN: 1, OID: "2.5.4.10", Name: "O", Value: "Example"},
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("expected %v \n got %v", expected, attributes)
+	}
+}
@@ -0,0 +1,75 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/pion/dtls/v2"
+	"net"
+	"time"
+)
+
+// dtlsDialer is a Dialer that performs a DTLS handshake over UDP. It is kept
+// separate from the TCP dialers, and the options that only apply to TCP, such
+// as timings and PROXY protocol headers, are ignored.
+type dtlsDialer struct {
+	// timeout bounds the resolution and handshake. A zero value means no
+	// timeout.
+	timeout time.Duration
+
+	config *dtls.Config
+}
+
+// dtlsConn adapts a DTLS connection so that the certificate extraction used
+// for TLS connections can read its peer certificates.
+type dtlsConn struct {
+	*dtls.Conn
+}
+
+// ConnectionState returns the peer certificates of the DTLS connection in a
+// tls.ConnectionState. Certificates that fail to parse are left out.
+func (c *dtlsConn) ConnectionState() tls.ConnectionState {
+	var state tls.ConnectionState
+	for _, der := range c.Conn.ConnectionState().PeerCertificates {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		state.PeerCertificates = append(state.PeerCertificates, cert)
+	}
+	return state
+}
+
+// Dial resolves the address and performs a DTLS handshake with it. The
+// network is ignored in favour of UDP.
+func (d *dtlsDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext is like Dial, but gives up when ctx is done.
+func (d *dtlsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	portNumber, err := net.LookupPort("udp", port)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dtls.DialWithContext(ctx, "udp", &net.UDPAddr{IP: ips[0], Port: portNumber}, d.config)
+	if err != nil {
+		return nil, err
+	}
+	return &dtlsConn{Conn: conn}, nil
+}
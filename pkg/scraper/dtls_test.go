@@ -0,0 +1,46 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/pion/dtls/v2"
+	"net"
+	"testing"
+)
+
+func TestScrapeDTLS(t *testing.T) {
+	leaf, key := issueTestCert(t, &x509.Certificate{DNSNames: []string{"dtls.example.com"}}, nil, nil)
+
+	ln, err := dtls.Listen("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}, &dtls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	scanner := NewScanner(
+		WithDTLS(true),
+		WithPort(ln.Addr().(*net.UDPAddr).Port),
+		WithResolveOverrides(map[string]net.IP{"dtls.example.com": net.ParseIP("127.0.0.1")}),
+	)
+	details, err := scanner.ScrapeDomain("dtls.example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if details.Serial != leaf.SerialNumber.String() {
+		t.Errorf("expected serial %s, got %s", leaf.SerialNumber, details.Serial)
+	}
+	if len(details.CertChain) != 1 {
+		t.Errorf("expected 1 certificate, got %d", len(details.CertChain))
+	}
+}
@@ -1,6 +1,12 @@
 package scraper
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
 
 // MultiError is a custom error type that encapsulates multiple errors
 // with their associated domain.
@@ -17,3 +23,57 @@ func (me *MultiError) Error() string {
 	}
 	return errMsg
 }
+
+// The phases of a scrape a ScrapeError can come from.
+const (
+	// PhaseDial is resolving the host and opening the connection.
+	PhaseDial = "dial"
+	// PhaseHandshake is the TLS handshake, or anything else after the
+	// connection was opened.
+	PhaseHandshake = "handshake"
+)
+
+// ScrapeError is returned when connecting to a host fails, recording which
+// phase of the scrape failed. Its message is that of the underlying error,
+// except that a timeout also names the host and phase, since the bare
+// "context deadline exceeded" doesn't say what timed out.
+type ScrapeError struct {
+	Host  string
+	Phase string
+	Err   error
+}
+
+// newScrapeError wraps an error returned by a Dialer, telling a failure to
+// reach the host apart from a failed handshake.
+func newScrapeError(host string, err error) *ScrapeError {
+	phase := PhaseHandshake
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if (errors.As(err, &opErr) && opErr.Op == "dial") || errors.As(err, &dnsErr) {
+		phase = PhaseDial
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%s with %s timed out: %w", phase, host, err)
+	}
+	return &ScrapeError{Host: host, Phase: phase, Err: err}
+}
+
+func (e *ScrapeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+// IsConnectionError reports whether err comes from the network rather than
+// from TLS: the host couldn't be reached, the connection was broken, or it
+// timed out, including a handshake that ran past its deadline.
+func IsConnectionError(err error) bool {
+	var scrapeErr *ScrapeError
+	if errors.As(err, &scrapeErr) && scrapeErr.Phase == PhaseDial {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded)
+}
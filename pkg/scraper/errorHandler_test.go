@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"dial", refused, true},
+		{"wrapped dial", fmt.Errorf("scrape failed: %w", refused), true},
+		{"read", reset, true},
+		{"deadline", fmt.Errorf("handshake: %w", os.ErrDeadlineExceeded), true},
+		{"handshake timeout", newScrapeError("example.com", context.DeadlineExceeded), true},
+		{"dns", newScrapeError("a.invalid", &net.DNSError{Err: "no such host", Name: "a.invalid"}), true},
+		{"tls alert", newScrapeError("example.com", errors.New("remote error: tls: handshake failure")), false},
+		{"message only", errors.New("dial tcp 192.0.2.1:443: connect: connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := IsConnectionError(tt.err); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestScrapeErrorPhase(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	_, err = NewScanner(WithPort(closedPort)).ScrapeDomain("127.0.0.1")
+	var scrapeErr *ScrapeError
+	if !errors.As(err, &scrapeErr) {
+		t.Fatalf("expected a ScrapeError, got: %v", err)
+	}
+	if scrapeErr.Host != "127.0.0.1" || scrapeErr.Phase != PhaseDial {
+		t.Errorf("expected a dial error for 127.0.0.1, got %s for %s", scrapeErr.Phase, scrapeErr.Host)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected the underlying error to be kept, got: %v", err)
+	}
+
+	handshake := newScrapeError("example.com", errors.New("remote error: tls: handshake failure"))
+	if handshake.Phase != PhaseHandshake {
+		t.Errorf("expected a handshake error, got %s", handshake.Phase)
+	}
+}
@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// CodeLegacyTLSOnly is reported in TLS fallback mode when a server only
+// completed a handshake once versions older than TLS 1.2 were offered.
+const CodeLegacyTLSOnly = "LEGACY_TLS_ONLY"
+
+// fallbackMinVersion is the oldest version offered when falling back.
+const fallbackMinVersion = tls.VersionTLS10
+
+// versionErrors are fragments of the handshake errors returned when a server
+// and client share no protocol version. Servers that only speak older
+// versions often send a generic handshake failure alert instead of a protocol
+// version alert, so that is treated as version related too.
+var versionErrors = []string{
+	"protocol version",
+	"unsupported protocol version",
+	"handshake failure",
+}
+
+// isVersionError reports whether err looks like a handshake that failed
+// because the server only supports older TLS versions.
+func isVersionError(err error) bool {
+	msg := err.Error()
+	for _, fragment := range versionErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSVersionName returns the name of a TLS version, such as "TLS 1.0", or its
+// hex value when unknown.
+func TLSVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// legacyTLSError returns the warning for a server whose handshake only
+// succeeded using version.
+func legacyTLSError(version uint16) ValidationError {
+	return ValidationError{
+		Code:    CodeLegacyTLSOnly,
+		Message: fmt.Sprintf("handshake only succeeded after falling back to %s", TLSVersionName(version)),
+		Warning: true,
+	}
+}
@@ -0,0 +1,87 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newLegacyTestTarget returns the host and port of a TLS server that only
+// speaks TLS 1.0.
+func newLegacyTestTarget(t *testing.T) (string, int) {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS10}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse port: %v", err)
+	}
+	return host, port
+}
+
+func TestTLSFallback(t *testing.T) {
+	host, port := newLegacyTestTarget(t)
+
+	if _, err := NewScanner(WithPort(port)).ScrapeDomain(host); err == nil {
+		t.Fatal("expected the handshake to fail without fallback")
+	}
+
+	details, err := NewScanner(WithPort(port), WithTLSFallback(true)).ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("expected the fallback handshake to succeed, got: %v", err)
+	}
+	var legacy *ValidationError
+	for i := range details.ValidationErrors {
+		if details.ValidationErrors[i].Code == CodeLegacyTLSOnly {
+			legacy = &details.ValidationErrors[i]
+		}
+	}
+	if legacy == nil {
+		t.Fatalf("expected a %s warning, got %s", CodeLegacyTLSOnly, validationCodes(details.ValidationErrors))
+	}
+	if !legacy.Warning || !strings.Contains(legacy.Message, "TLS 1.0") {
+		t.Errorf("expected a warning naming TLS 1.0, got %+v", legacy)
+	}
+}
+
+func TestTLSFallbackModernServer(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	details, err := NewScanner(WithPort(port), WithTLSFallback(true)).ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if codes := strings.Join(validationCodes(details.ValidationErrors), ","); strings.Contains(codes, CodeLegacyTLSOnly) {
+		t.Errorf("expected no %s warning, got %s", CodeLegacyTLSOnly, codes)
+	}
+}
+
+func TestIsVersionError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{errors.New("remote error: tls: protocol version not supported"), true},
+		{errors.New("tls: server selected unsupported protocol version 301"), true},
+		{errors.New("remote error: tls: handshake failure"), true},
+		{errors.New("dial tcp 192.0.2.1:443: connect: connection refused"), false},
+		{errors.New("tls: failed to verify certificate"), false},
+	}
+	for _, tt := range tests {
+		if got := isVersionError(tt.err); got != tt.expected {
+			t.Errorf("isVersionError(%q) = %v, expected %v", tt.err, got, tt.expected)
+		}
+	}
+}
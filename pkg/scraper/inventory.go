@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"sort"
+	"strings"
+)
+
+// CollectSANs builds an inventory of every DNS Subject Alternative Name
+// asserted by the leaf certificates in details. The returned map is keyed by
+// the lower-cased SAN, and each value is the sorted, deduplicated list of
+// scraped domains whose certificate asserted it.
+func CollectSANs(details []*CertDetails) map[string][]string {
+	seen := make(map[string]map[string]struct{})
+	for _, detail := range details {
+		if detail == nil || len(detail.CertChain) == 0 {
+			continue
+		}
+		for _, san := range detail.GetLeafCert().DNSNames {
+			san = strings.ToLower(strings.TrimSpace(san))
+			if san == "" {
+				continue
+			}
+			if seen[san] == nil {
+				seen[san] = make(map[string]struct{})
+			}
+			seen[san][detail.Domain] = struct{}{}
+		}
+	}
+
+	inventory := make(map[string][]string, len(seen))
+	for san, domains := range seen {
+		targets := make([]string, 0, len(domains))
+		for domain := range domains {
+			targets = append(targets, domain)
+		}
+		sort.Strings(targets)
+		inventory[san] = targets
+	}
+	return inventory
+}
+
+// SortedSANs returns the keys of a SAN inventory as a sorted list.
+func SortedSANs(inventory map[string][]string) []string {
+	sans := make([]string, 0, len(inventory))
+	for san := range inventory {
+		sans = append(sans, san)
+	}
+	sort.Strings(sans)
+	return sans
+}
@@ -0,0 +1,39 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"reflect"
+	"testing"
+)
+
+func TestCollectSANs(t *testing.T) {
+	details := []*CertDetails{
+		{
+			Domain:    "example.com",
+			CertChain: []*x509.Certificate{{DNSNames: []string{"example.com", "www.example.com"}}},
+		},
+		{
+			Domain:    "www.example.com",
+			CertChain: []*x509.Certificate{{DNSNames: []string{"WWW.example.com", "api.example.com"}}},
+		},
+		{
+			Domain: "nochain.example.com",
+		},
+	}
+
+	inventory := CollectSANs(details)
+	expected := map[string][]string{
+		"api.example.com": {"www.example.com"},
+		"example.com":     {"example.com"},
+		"www.example.com": {"example.com", "www.example.com"},
+	}
+	if !reflect.DeepEqual(inventory, expected) {
+		t.Errorf("expected %v \n got %v", expected, inventory)
+	}
+
+	sans := SortedSANs(inventory)
+	expectedSANs := []string{"api.example.com", "example.com", "www.example.com"}
+	if !reflect.DeepEqual(sans, expectedSANs) {
+		t.Errorf("expected %v \n got %v", expectedSANs, sans)
+	}
+}
@@ -0,0 +1,19 @@
+package scraper
+
+import (
+	"io"
+	"sync"
+)
+
+// lockedWriter serializes writes to an underlying writer, so that concurrent
+// handshakes do not interleave their key log lines.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
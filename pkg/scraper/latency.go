@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyWindow is the number of most recent handshake latencies kept.
+	latencyWindow = 256
+
+	// latencyMinSamples is the number of samples required before the
+	// observed latencies are trusted over the ceiling.
+	latencyMinSamples = 10
+
+	// latencyPercentile is the percentile of observed latencies used as the
+	// basis for the adaptive timeout.
+	latencyPercentile = 0.95
+
+	// latencyMargin is added on top of the percentile to absorb jitter.
+	latencyMargin = 500 * time.Millisecond
+)
+
+// latencyTracker records handshake latencies observed during a scan and
+// derives a dial timeout from them.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	floor   time.Duration
+	ceiling time.Duration
+}
+
+func newLatencyTracker(floor, ceiling time.Duration) *latencyTracker {
+	if ceiling < floor {
+		ceiling = floor
+	}
+	return &latencyTracker{
+		samples: make([]time.Duration, 0, latencyWindow),
+		floor:   floor,
+		ceiling: ceiling,
+	}
+}
+
+// observe records a completed handshake latency, replacing the oldest sample
+// once the window is full.
+func (lt *latencyTracker) observe(d time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if len(lt.samples) < latencyWindow {
+		lt.samples = append(lt.samples, d)
+		return
+	}
+	lt.samples[lt.next] = d
+	lt.next = (lt.next + 1) % latencyWindow
+}
+
+// timeout returns the percentile latency plus margin, clamped to the
+// configured floor and ceiling.
+func (lt *latencyTracker) timeout() time.Duration {
+	lt.mu.Lock()
+	if len(lt.samples) < latencyMinSamples {
+		lt.mu.Unlock()
+		return lt.ceiling
+	}
+	sorted := make([]time.Duration, len(lt.samples))
+	copy(sorted, lt.samples)
+	lt.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(float64(len(sorted)-1) * latencyPercentile)
+	t := sorted[index] + latencyMargin
+
+	if t < lt.floor {
+		return lt.floor
+	}
+	if t > lt.ceiling {
+		return lt.ceiling
+	}
+	return t
+}
@@ -0,0 +1,75 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTrackerTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []time.Duration
+		floor    time.Duration
+		ceiling  time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "too few samples uses ceiling",
+			samples:  []time.Duration{10 * time.Millisecond},
+			floor:    time.Second,
+			ceiling:  30 * time.Second,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "fast network clamps to floor",
+			samples:  repeatDuration(20*time.Millisecond, 20),
+			floor:    time.Second,
+			ceiling:  30 * time.Second,
+			expected: time.Second,
+		},
+		{
+			name:     "slow network clamps to ceiling",
+			samples:  repeatDuration(40*time.Second, 20),
+			floor:    time.Second,
+			ceiling:  30 * time.Second,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "percentile plus margin",
+			samples:  append(repeatDuration(time.Second, 19), 2*time.Second),
+			floor:    100 * time.Millisecond,
+			ceiling:  30 * time.Second,
+			expected: time.Second + latencyMargin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLatencyTracker(tt.floor, tt.ceiling)
+			for _, d := range tt.samples {
+				lt.observe(d)
+			}
+			if got := lt.timeout(); got != tt.expected {
+				t.Errorf("expected timeout %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLatencyTrackerWindow(t *testing.T) {
+	lt := newLatencyTracker(0, time.Hour)
+	for i := 0; i < latencyWindow*2; i++ {
+		lt.observe(time.Millisecond)
+	}
+	if len(lt.samples) != latencyWindow {
+		t.Errorf("expected %d samples, got %d", latencyWindow, len(lt.samples))
+	}
+}
+
+func repeatDuration(d time.Duration, n int) []time.Duration {
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		durations[i] = d
+	}
+	return durations
+}
@@ -0,0 +1,53 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/x509"
+)
+
+// CertSummary is a short description of a certificate in a served chain.
+type CertSummary struct {
+	Subject            string   `json:"subject"`
+	Serial             string   `json:"serial"`
+	NotBefore          string   `json:"not_before"`
+	NotAfter           string   `json:"not_after"`
+	Issuer             string   `json:"issuer"`
+	PublicKeyAlgorithm string   `json:"public_key_algorithm"`
+	DNSNames           []string `json:"dns_names,omitempty"`
+}
+
+// summarizeCert builds a CertSummary for cert.
+func summarizeCert(cert *x509.Certificate) CertSummary {
+	return CertSummary{
+		Subject:            cert.Subject.String(),
+		Serial:             cert.SerialNumber.String(),
+		NotBefore:          cert.NotBefore.String(),
+		NotAfter:           cert.NotAfter.String(),
+		Issuer:             cert.Issuer.String(),
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		DNSNames:           cert.DNSNames,
+	}
+}
+
+// findLeafCerts returns the end-entity certificates in chain: those that are
+// not CAs and did not issue any other certificate in the chain. Servers with
+// dual RSA and ECDSA certificates can send more than one.
+func findLeafCerts(chain []*x509.Certificate) []*x509.Certificate {
+	var leaves []*x509.Certificate
+	for i, cert := range chain {
+		if cert.IsCA {
+			continue
+		}
+		issuer := false
+		for j, other := range chain {
+			if i != j && bytes.Equal(other.RawIssuer, cert.RawSubject) {
+				issuer = true
+				break
+			}
+		}
+		if !issuer {
+			leaves = append(leaves, cert)
+		}
+	}
+	return leaves
+}
@@ -0,0 +1,78 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+)
+
+// stateDialer returns connections reporting a fixed TLS connection state.
+type stateDialer struct {
+	state tls.ConnectionState
+}
+
+func (d *stateDialer) Dial(network, address string) (net.Conn, error) {
+	return &mockTLSConn{Conn: &mockConn{}, state: d.state}, nil
+}
+
+func TestFindLeafCerts(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	first := newTestLeaf(t, ca, caKey, "example.com")
+	second := newTestLeaf(t, ca, caKey, "example.com")
+
+	tests := []struct {
+		name     string
+		chain    []*x509.Certificate
+		expected []*x509.Certificate
+	}{
+		{"single leaf", []*x509.Certificate{first, ca}, []*x509.Certificate{first}},
+		{"dual leaf", []*x509.Certificate{first, second, ca}, []*x509.Certificate{first, second}},
+		{"dual leaf after issuer", []*x509.Certificate{first, ca, second}, []*x509.Certificate{first, second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaves := findLeafCerts(tt.chain)
+			if len(leaves) != len(tt.expected) {
+				t.Fatalf("expected %d leaves, got %d", len(tt.expected), len(leaves))
+			}
+			for i, leaf := range leaves {
+				if leaf != tt.expected[i] {
+					t.Errorf("expected leaf %d to have serial %s, got %s", i, tt.expected[i].SerialNumber, leaf.SerialNumber)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchReportsDualLeafCerts(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	first := newTestLeaf(t, ca, caKey, "example.com")
+	second := newTestLeaf(t, ca, caKey, "example.com")
+
+	cd := &CertDetails{}
+	dialer := &stateDialer{state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{first, second, ca}}}
+	if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, dialer, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(cd.LeafCerts) != 2 {
+		t.Fatalf("expected 2 leaf certs, got %d", len(cd.LeafCerts))
+	}
+	if cd.LeafCerts[0].Serial != first.SerialNumber.String() {
+		t.Errorf("expected serial %s, got %s", first.SerialNumber, cd.LeafCerts[0].Serial)
+	}
+	if cd.LeafCerts[1].Serial != second.SerialNumber.String() {
+		t.Errorf("expected serial %s, got %s", second.SerialNumber, cd.LeafCerts[1].Serial)
+	}
+
+	cd = &CertDetails{}
+	dialer.state.PeerCertificates = []*x509.Certificate{first, ca}
+	if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, dialer, 0); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if cd.LeafCerts != nil {
+		t.Errorf("expected no leaf certs for a single leaf chain, got %d", len(cd.LeafCerts))
+	}
+}
@@ -0,0 +1,44 @@
+package scraper
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+)
+
+// ScrapeN scrapes a single host:port target n times with the given
+// concurrency and returns how long it took. It is intended for measuring
+// throughput against a local server. The error is non-nil if any scrape
+// failed.
+func ScrapeN(target string, n, concurrency int) (time.Duration, error) {
+	host, portString, err := net.SplitHostPort(target)
+	if err != nil {
+		return 0, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return 0, err
+	}
+
+	websites := make([]string, n)
+	for i := range websites {
+		websites[i] = host
+	}
+
+	// Each repeat of the host is scraped, rather than removed as a duplicate
+	// as ScrapeTLS would.
+	scanner := NewScanner(WithConcurrency(concurrency), WithPort(port))
+	multiError := &MultiError{Errors: make(map[string]error)}
+	start := time.Now()
+	scanner.scrapeBatches(context.Background(), websites, n, func(_ []*CertDetails, errs map[string]error) {
+		for domain, err := range errs {
+			multiError.Errors[domain] = err
+		}
+	})
+	elapsed := time.Since(start)
+	if len(multiError.Errors) > 0 {
+		return elapsed, multiError
+	}
+	return elapsed, nil
+}
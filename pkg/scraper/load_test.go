@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScrapeN(t *testing.T) {
+	var handshakes int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&handshakes, 1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+
+	elapsed, err := ScrapeN(net.JoinHostPort(host, portString), 5, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if elapsed <= 0 {
+		t.Errorf("expected a positive duration, got %s", elapsed)
+	}
+	// The server sees new connections asynchronously, so wait for them.
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&handshakes) < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&handshakes); got != 5 {
+		t.Errorf("expected 5 connections, got %d", got)
+	}
+
+	if _, err := ScrapeN(host, 1, 1); err == nil {
+		t.Errorf("expected an error for a target without a port")
+	}
+}
+
+func BenchmarkScrapeTLS(b *testing.B) {
+	host, port := newTestTarget(b)
+	websites, overrides := aliasTargets(host, b.N)
+	scanner := NewScanner(WithConcurrency(10), WithPort(port), WithResolveOverrides(overrides))
+
+	b.ResetTimer()
+	if _, err := scanner.ScrapeTLS(websites); err != nil {
+		b.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+func BenchmarkScrapeN(b *testing.B) {
+	host, port := newTestTarget(b)
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ScrapeN(target, 20, 10); err != nil {
+			b.Fatalf("expected no error, got: %v", err)
+		}
+	}
+}
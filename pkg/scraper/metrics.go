@@ -4,6 +4,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 // totalScrapes is a counter metric to track the number of domains scraped.
@@ -26,12 +29,175 @@ var (
 		},
 		[]string{"domain"}, // The domain for which the scrape duration is being measured
 	)
+
+	// scrapeLatency is a histogram of scrape durations across all domains.
+	// Unlike scrapeDuration it can carry exemplars, which link a bucket to the
+	// target and certificate serial of a scrape that landed in it.
+	scrapeLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tls_scrape_latency_seconds",
+			Help:    "Histogram of TLS scrape durations in seconds.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	// certExpiryDays is the whole days left until the leaf certificate of
+	// each domain expires, for alerting on certificates due for renewal.
+	// Like scrapeDuration it has a series per domain, so scanning large
+	// ranges of IPs creates as many series.
+	certExpiryDays = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tls_cert_expiry_days",
+			Help: "Days until the leaf certificate of a domain expires, negative once it has.",
+		},
+		[]string{"domain"},
+	)
+
+	// certValid is 1 when the certificate of a domain passed validation and
+	// 0 when it didn't, with a series per domain like certExpiryDays.
+	certValid = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tls_cert_valid",
+			Help: "Whether the certificate of a domain passed validation, 1 or 0.",
+		},
+		[]string{"domain"},
+	)
 )
 
+// certExpiry holds the certificate expiry gauges registered with
+// certExpiryRegisterer so far, keyed by their comma separated extra label
+// names. See expiryGauge.
+var (
+	certExpiryMu         sync.Mutex
+	certExpiry           = make(map[string]*prometheus.GaugeVec)
+	certExpiryRegisterer = prometheus.DefaultRegisterer
+)
+
+// MetricsOptions configures the metrics recorded for every scan.
+type MetricsOptions struct {
+	// DisableDomainLabels stops recording the metrics that have a series per
+	// domain: tls_scrape_duration_seconds, tls_cert_expiry_days,
+	// tls_cert_valid and tls_cert_expiry_timestamp_seconds. Scanning large
+	// ranges of IPs otherwise creates a series for every address, which can
+	// exhaust Prometheus' memory. Scrape durations are still recorded, across
+	// all domains, by the tls_scrape_latency_seconds histogram.
+	DisableDomainLabels bool
+}
+
+var (
+	metricsOptionsMu sync.RWMutex
+	metricsOptions   MetricsOptions
+)
+
+// ConfigureMetrics sets the options for the metrics recorded by all
+// scanners. It should be called before scanning starts.
+func ConfigureMetrics(opts MetricsOptions) {
+	metricsOptionsMu.Lock()
+	defer metricsOptionsMu.Unlock()
+	metricsOptions = opts
+}
+
+// domainLabels reports whether metrics with a series per domain are
+// recorded.
+func domainLabels() bool {
+	metricsOptionsMu.RLock()
+	defer metricsOptionsMu.RUnlock()
+	return !metricsOptions.DisableDomainLabels
+}
+
 // init function registers the Prometheus metrics during package initialization.
 func init() {
 	prometheus.MustRegister(totalScrapes)
 	prometheus.MustRegister(scrapeDuration)
+	prometheus.MustRegister(scrapeLatency)
+	prometheus.MustRegister(certExpiryDays)
+	prometheus.MustRegister(certValid)
+}
+
+// observeScrape records the outcome and duration of a scrape of domain, and
+// for successful scrapes the expiry and validity of the certificate. With
+// exemplars enabled, successful scrapes attach the target and certificate
+// serial to the latency histogram.
+func observeScrape(domain string, elapsed time.Duration, certInfo *CertDetails, err error, exemplars bool) {
+	perDomain := domainLabels()
+	if perDomain {
+		scrapeDuration.WithLabelValues(domain).Observe(elapsed.Seconds())
+	}
+	if err != nil {
+		totalScrapes.WithLabelValues("failed").Inc()
+		scrapeLatency.Observe(elapsed.Seconds())
+		return
+	}
+	totalScrapes.WithLabelValues("success").Inc()
+	if perDomain {
+		certExpiryDays.WithLabelValues(domain).Set(float64(certInfo.DaysUntilExpiry))
+		valid := 0.0
+		if certInfo.Valid {
+			valid = 1
+		}
+		certValid.WithLabelValues(domain).Set(valid)
+	}
+
+	if !exemplars {
+		scrapeLatency.Observe(elapsed.Seconds())
+		return
+	}
+	scrapeLatency.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed.Seconds(), scrapeExemplar(domain, certInfo.Serial))
+}
+
+// expiryGauge returns the gauge of certificate expiry times, labelled by
+// domain and then by labels, registering it on first use. Prometheus requires
+// every series of a metric to have the same label names, so this fails when
+// the gauge is already registered with different labels.
+func expiryGauge(labels []string) (*prometheus.GaugeVec, error) {
+	key := strings.Join(labels, ",")
+
+	certExpiryMu.Lock()
+	defer certExpiryMu.Unlock()
+	if gauge, ok := certExpiry[key]; ok {
+		return gauge, nil
+	}
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tls_cert_expiry_timestamp_seconds",
+			Help: "Time at which the leaf certificate of a domain expires, in seconds since the Unix epoch.",
+		},
+		append([]string{"domain"}, labels...),
+	)
+	if err := certExpiryRegisterer.Register(gauge); err != nil {
+		return nil, err
+	}
+	certExpiry[key] = gauge
+	return gauge, nil
+}
+
+// observeExpiry records the expiry time of the leaf certificate scraped from
+// domain, labelled with the domain's metric labels.
+func (s *Scanner) observeExpiry(domain string, certInfo *CertDetails) {
+	if s.expiry == nil || certInfo == nil || len(certInfo.CertChain) == 0 || !domainLabels() {
+		return
+	}
+	values := make([]string, 0, 1+len(s.metricLabels))
+	values = append(values, domain)
+	for _, label := range s.metricLabels {
+		values = append(values, s.targetLabels[domain][label])
+	}
+	s.expiry.WithLabelValues(values...).Set(float64(certInfo.GetLeafCert().NotAfter.Unix()))
+}
+
+// scrapeExemplar returns the exemplar labels for a scrape, truncating the
+// target so that the labels fit within prometheus.ExemplarMaxRunes.
+func scrapeExemplar(target, serial string) prometheus.Labels {
+	budget := prometheus.ExemplarMaxRunes - len("target") - len("serial")
+	serialRunes := []rune(serial)
+	if len(serialRunes) > budget {
+		serialRunes = serialRunes[:budget]
+	}
+	targetRunes := []rune(target)
+	if len(targetRunes) > budget-len(serialRunes) {
+		targetRunes = targetRunes[:budget-len(serialRunes)]
+	}
+	return prometheus.Labels{"target": string(targetRunes), "serial": string(serialRunes)}
 }
 
 // GetMetricsHandler returns a HTTP handler for the Prometheus metrics.
@@ -39,3 +205,13 @@ func init() {
 func GetMetricsHandler() http.Handler {
 	return promhttp.Handler()
 }
+
+// GetOpenMetricsHandler returns a HTTP handler for the Prometheus metrics that
+// also serves the OpenMetrics format to scrapers that negotiate it, which is
+// required for exemplars to be exposed.
+func GetOpenMetricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
+}
@@ -0,0 +1,221 @@
+package scraper
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestScrapeExemplar(t *testing.T) {
+	labels := scrapeExemplar("example.com", "1234567890")
+	if labels["target"] != "example.com" || labels["serial"] != "1234567890" {
+		t.Errorf("expected target and serial to be kept, got %v", labels)
+	}
+
+	long := strings.Repeat("a", 250) + ".example.com"
+	labels = scrapeExemplar(long, "1234567890")
+	runes := 0
+	for name, value := range labels {
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if runes > prometheus.ExemplarMaxRunes {
+		t.Errorf("expected at most %d runes, got %d", prometheus.ExemplarMaxRunes, runes)
+	}
+	if labels["serial"] != "1234567890" {
+		t.Errorf("expected the serial to be kept, got %s", labels["serial"])
+	}
+}
+
+func TestObserveScrapeExemplar(t *testing.T) {
+	observeScrape("exemplar.example.com", 3*time.Millisecond, &CertDetails{Serial: "42"}, nil, true)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "tls_scrape_latency_seconds" {
+			continue
+		}
+		for _, bucket := range family.GetMetric()[0].GetHistogram().GetBucket() {
+			exemplar := bucket.GetExemplar()
+			if exemplar == nil {
+				continue
+			}
+			labels := map[string]string{}
+			for _, pair := range exemplar.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if labels["target"] == "exemplar.example.com" && labels["serial"] == "42" {
+				return
+			}
+		}
+	}
+	t.Errorf("expected an exemplar for exemplar.example.com")
+}
+
+func TestObserveScrapeCertGauges(t *testing.T) {
+	observeScrape("gauges.example.com", time.Millisecond, &CertDetails{DaysUntilExpiry: 12, Valid: true}, nil, false)
+	observeScrape("invalid.example.com", time.Millisecond, &CertDetails{DaysUntilExpiry: -3}, nil, false)
+
+	tests := []struct {
+		gauge    *prometheus.GaugeVec
+		domain   string
+		expected float64
+	}{
+		{certExpiryDays, "gauges.example.com", 12},
+		{certValid, "gauges.example.com", 1},
+		{certExpiryDays, "invalid.example.com", -3},
+		{certValid, "invalid.example.com", 0},
+	}
+	for _, tt := range tests {
+		var metric dto.Metric
+		if err := tt.gauge.WithLabelValues(tt.domain).Write(&metric); err != nil {
+			t.Fatalf("failed to read gauge: %v", err)
+		}
+		if got := metric.GetGauge().GetValue(); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.domain, tt.expected, got)
+		}
+	}
+
+	// A failed scrape leaves the gauges alone.
+	observeScrape("gauges.example.com", time.Millisecond, nil, errors.New("connection refused"), false)
+	var metric dto.Metric
+	if err := certExpiryDays.WithLabelValues("gauges.example.com").Write(&metric); err != nil {
+		t.Fatalf("failed to read gauge: %v", err)
+	}
+	if got := metric.GetGauge().GetValue(); got != 12 {
+		t.Errorf("expected the expiry to be kept after a failed scrape, got %v", got)
+	}
+}
+
+// domainSeries returns the names of the metrics gathered from gatherer that
+// have a series for domain.
+func domainSeries(t *testing.T, gatherer prometheus.Gatherer, domain string) []string {
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	var names []string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "domain" && pair.GetValue() == domain {
+					names = append(names, family.GetName())
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestConfigureMetricsDisableDomainLabels(t *testing.T) {
+	registry := useExpiryRegistry(t)
+	ConfigureMetrics(MetricsOptions{DisableDomainLabels: true})
+	t.Cleanup(func() { ConfigureMetrics(MetricsOptions{}) })
+
+	// Other tests scrape the test target by its IP, so give it a name of its
+	// own that no other series can have.
+	_, port := newTestTarget(t)
+	host := "unlabelled-scan.example.com"
+	scanner := NewScanner(WithPort(port), WithResolveOverrides(map[string]net.IP{host: net.ParseIP("127.0.0.1")}))
+	if _, err := scanner.ScrapeTLS([]string{host}); err != nil {
+		t.Fatalf("ScrapeTLS() error = %v", err)
+	}
+	observeScrape("unlabelled.example.com", time.Millisecond, nil, errors.New("connection refused"), false)
+
+	for _, domain := range []string{host, "unlabelled.example.com"} {
+		for _, gatherer := range []prometheus.Gatherer{prometheus.DefaultGatherer, registry} {
+			if names := domainSeries(t, gatherer, domain); len(names) != 0 {
+				t.Errorf("expected no series for %s, got %v", domain, names)
+			}
+		}
+	}
+
+	// Scrapes are still counted and timed across all domains.
+	var metric dto.Metric
+	if err := scrapeLatency.Write(&metric); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	if metric.GetHistogram().GetSampleCount() == 0 {
+		t.Error("expected the latency histogram to be observed")
+	}
+
+	ConfigureMetrics(MetricsOptions{})
+	observeScrape("unlabelled.example.com", time.Millisecond, nil, errors.New("connection refused"), false)
+	if names := domainSeries(t, prometheus.DefaultGatherer, "unlabelled.example.com"); len(names) != 1 {
+		t.Errorf("expected a duration series once labels are enabled again, got %v", names)
+	}
+}
+
+// useExpiryRegistry registers expiry gauges with a new registry for the rest
+// of the test, so that the test can choose their labels. It returns the
+// registry to gather from.
+func useExpiryRegistry(t *testing.T) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	certExpiryMu.Lock()
+	saved, savedRegisterer := certExpiry, certExpiryRegisterer
+	certExpiry, certExpiryRegisterer = make(map[string]*prometheus.GaugeVec), registry
+	certExpiryMu.Unlock()
+
+	t.Cleanup(func() {
+		certExpiryMu.Lock()
+		certExpiry, certExpiryRegisterer = saved, savedRegisterer
+		certExpiryMu.Unlock()
+	})
+	return registry
+}
+
+func TestWithMetricLabels(t *testing.T) {
+	registry := useExpiryRegistry(t)
+	host, port := newTestTarget(t)
+
+	scanner := NewScanner(WithPort(port), WithMetricLabels(
+		[]string{"owner", "env"},
+		map[string]map[string]string{host: {"owner": "payments", "team": "unused"}},
+	))
+	details, err := scanner.ScrapeTLS([]string{host})
+	if err != nil {
+		t.Fatalf("ScrapeTLS() error = %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	found := false
+	for _, family := range families {
+		if family.GetName() != "tls_cert_expiry_timestamp_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if labels["domain"] != host {
+				continue
+			}
+			found = true
+			if len(labels) != 3 || labels["owner"] != "payments" || labels["env"] != "" {
+				t.Errorf("expected domain, owner and empty env labels, got %v", labels)
+			}
+			if expected := float64(details[0].GetLeafCert().NotAfter.Unix()); metric.GetGauge().GetValue() != expected {
+				t.Errorf("expected expiry %v, got %v", expected, metric.GetGauge().GetValue())
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected an expiry series for %s", host)
+	}
+
+	// A scanner with different labels can't share the metric.
+	if other := NewScanner(WithMetricLabels([]string{"team"}, nil)); other.expiry != nil {
+		t.Errorf("expected no expiry gauge for conflicting labels")
+	}
+}
@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"github.com/scotta01/tls-scrape/pkg/ocsp"
+	xocsp "golang.org/x/crypto/ocsp"
+)
+
+// OCSP statuses recorded in CertDetails.OCSPStatus.
+const (
+	OCSPStatusGood    = "good"
+	OCSPStatusRevoked = "revoked"
+	OCSPStatusUnknown = "unknown"
+)
+
+// checkOCSP asks the leaf's OCSP responder for its revocation status and
+// records it on the details. Failures, such as an unreachable responder, are
+// recorded as an unknown status with the reason in OCSPError rather than
+// failing the scrape.
+func (s *Scanner) checkOCSP(cd *CertDetails) {
+	if len(cd.CertChain) < 2 {
+		cd.OCSPStatus = OCSPStatusUnknown
+		cd.OCSPError = "the server did not send the issuer certificate"
+		return
+	}
+
+	checker := &ocsp.OCSPChecker{Certificate: cd.GetLeafCert(), Issuer: cd.GetIssuerCert(), Nonce: s.ocspNonce}
+	resp, err := checker.GetOCSPResp()
+	if err != nil {
+		cd.OCSPStatus = OCSPStatusUnknown
+		cd.OCSPError = err.Error()
+		return
+	}
+
+	switch resp.Status {
+	case xocsp.Good:
+		cd.OCSPStatus = OCSPStatusGood
+	case xocsp.Revoked:
+		cd.OCSPStatus = OCSPStatusRevoked
+	default:
+		cd.OCSPStatus = OCSPStatusUnknown
+	}
+}
@@ -0,0 +1,123 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckOCSP(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	tests := []struct {
+		name           string
+		status         int
+		httpStatus     int
+		issuerInChain  bool
+		expectedStatus string
+		expectError    bool
+	}{
+		{"good", ocsp.Good, http.StatusOK, true, OCSPStatusGood, false},
+		{"revoked", ocsp.Revoked, http.StatusOK, true, OCSPStatusRevoked, false},
+		{"unknown", ocsp.Unknown, http.StatusOK, true, OCSPStatusUnknown, false},
+		{"responder error", ocsp.Good, http.StatusInternalServerError, true, OCSPStatusUnknown, true},
+		{"no issuer", ocsp.Good, http.StatusOK, false, OCSPStatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var leaf *x509.Certificate
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					t.Errorf("failed to read request: %v", err)
+				}
+				if tt.httpStatus != http.StatusOK {
+					w.WriteHeader(tt.httpStatus)
+					return
+				}
+				resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+					Status:       tt.status,
+					SerialNumber: leaf.SerialNumber,
+					ThisUpdate:   time.Now().Add(-time.Hour),
+					NextUpdate:   time.Now().Add(time.Hour),
+					RevokedAt:    time.Now().Add(-time.Hour),
+				}, caKey)
+				if err != nil {
+					t.Errorf("failed to create response: %v", err)
+				}
+				w.Write(resp)
+			}))
+			defer server.Close()
+			leaf, _ = issueTestCert(t, &x509.Certificate{
+				Subject:    pkix.Name{CommonName: "example.com"},
+				DNSNames:   []string{"example.com"},
+				OCSPServer: []string{server.URL},
+			}, ca, caKey)
+
+			cd := &CertDetails{CertChain: []*x509.Certificate{leaf}}
+			if tt.issuerInChain {
+				cd.CertChain = append(cd.CertChain, ca)
+			}
+			NewScanner(WithOCSP(true)).checkOCSP(cd)
+			if cd.OCSPStatus != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q", tt.expectedStatus, cd.OCSPStatus)
+			}
+			if tt.expectError != (cd.OCSPError != "") {
+				t.Errorf("expected an error: %v, got %q", tt.expectError, cd.OCSPError)
+			}
+		})
+	}
+}
+
+func TestCheckOCSPNonce(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	oid, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2})
+	if err != nil {
+		t.Fatalf("failed to marshal nonce OID: %v", err)
+	}
+
+	for _, nonce := range []bool{false, true} {
+		t.Run(fmt.Sprintf("nonce=%v", nonce), func(t *testing.T) {
+			var leaf *x509.Certificate
+			var hasNonce bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("failed to read request: %v", err)
+				}
+				hasNonce = bytes.Contains(body, oid)
+				resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+					Status:       ocsp.Good,
+					SerialNumber: leaf.SerialNumber,
+					ThisUpdate:   time.Now().Add(-time.Hour),
+					NextUpdate:   time.Now().Add(time.Hour),
+				}, caKey)
+				if err != nil {
+					t.Errorf("failed to create response: %v", err)
+				}
+				w.Write(resp)
+			}))
+			defer server.Close()
+			leaf, _ = issueTestCert(t, &x509.Certificate{
+				Subject:    pkix.Name{CommonName: "example.com"},
+				DNSNames:   []string{"example.com"},
+				OCSPServer: []string{server.URL},
+			}, ca, caKey)
+
+			cd := &CertDetails{CertChain: []*x509.Certificate{leaf, ca}}
+			NewScanner(WithOCSP(true), WithOCSPNonce(nonce)).checkOCSP(cd)
+			if cd.OCSPStatus != OCSPStatusGood {
+				t.Errorf("expected status %q, got %q (%s)", OCSPStatusGood, cd.OCSPStatus, cd.OCSPError)
+			}
+			if hasNonce != nonce {
+				t.Errorf("expected a nonce extension in the request: %v, got %v", nonce, hasNonce)
+			}
+		})
+	}
+}
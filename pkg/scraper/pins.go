@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// CodePinMismatch is reported when a certificate matches none of the pins
+// expected for its target.
+const CodePinMismatch = "PIN_MISMATCH"
+
+// pinPrefix is the algorithm prefix of a certificate pin.
+const pinPrefix = "sha256:"
+
+// NormalizePin parses a pin of the form "sha256:HEX", where HEX is the
+// SHA-256 fingerprint of a certificate, optionally colon-separated. It
+// returns the fingerprint as lower-case hex without separators.
+func NormalizePin(pin string) (string, error) {
+	pin = strings.TrimSpace(pin)
+	if !strings.HasPrefix(strings.ToLower(pin), pinPrefix) {
+		return "", fmt.Errorf("pin %q must start with %q", pin, pinPrefix)
+	}
+	fingerprint := strings.ToLower(strings.ReplaceAll(pin[len(pinPrefix):], ":", ""))
+	decoded, err := hex.DecodeString(fingerprint)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("pin %q is not a hex encoded SHA-256 fingerprint", pin)
+	}
+	return fingerprint, nil
+}
+
+// checkPins reports a PIN_MISMATCH validation error when pins is non-empty
+// and the certificate's SHA-256 fingerprint matches none of them.
+func checkPins(cert *x509.Certificate, pins []string) *ValidationError {
+	if len(pins) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	for _, pin := range pins {
+		normalized, err := NormalizePin(pin)
+		if err == nil && normalized == fingerprint {
+			return nil
+		}
+	}
+	return &ValidationError{
+		Code:    CodePinMismatch,
+		Message: fmt.Sprintf("certificate fingerprint %s%s matches none of the expected pins", pinPrefix, fingerprint),
+	}
+}
+
+// spkiSHA256 returns the base64 encoded SHA-256 hash of the certificate's
+// Subject Public Key Info, as used by HTTP Public Key Pinning and many mobile
+// pinning libraries. Unlike the certificate fingerprint it survives renewal
+// with the same key.
+func spkiSHA256(cert *x509.Certificate) string {
+	if len(cert.RawSubjectPublicKeyInfo) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
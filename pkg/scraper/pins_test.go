@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePin(t *testing.T) {
+	fingerprint := strings.Repeat("ab", sha256.Size)
+	colonSeparated := strings.ToUpper(strings.TrimSuffix(strings.Repeat("ab:", sha256.Size), ":"))
+
+	tests := []struct {
+		name      string
+		pin       string
+		expected  string
+		expectErr bool
+	}{
+		{name: "lower case hex", pin: "sha256:" + fingerprint, expected: fingerprint},
+		{name: "colon separated upper case", pin: "SHA256:" + colonSeparated, expected: fingerprint},
+		{name: "missing prefix", pin: fingerprint, expectErr: true},
+		{name: "wrong length", pin: "sha256:abcd", expectErr: true},
+		{name: "not hex", pin: "sha256:" + strings.Repeat("zz", sha256.Size), expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePin(tt.pin)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected an error for pin %q", tt.pin)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateCertificatePins(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leaf := newTestLeaf(t, ca, caKey, "example.com")
+
+	sum := sha256.Sum256(leaf.Raw)
+	matching := "sha256:" + hex.EncodeToString(sum[:])
+	other := "sha256:" + strings.Repeat("00", sha256.Size)
+
+	tests := []struct {
+		name          string
+		pins          []string
+		expectedValid bool
+	}{
+		{name: "no pins", expectedValid: true},
+		{name: "matching pin", pins: []string{matching}, expectedValid: true},
+		{name: "one of several pins matches", pins: []string{other, strings.ToUpper(matching)}, expectedValid: true},
+		{name: "mismatching pin", pins: []string{other}, expectedValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, errs := ValidateCertificate(leaf, []*x509.Certificate{leaf}, "example.com", ValidationOptions{Roots: roots, Pins: tt.pins})
+			if valid != tt.expectedValid {
+				t.Errorf("expected valid %t, got %t (%v)", tt.expectedValid, valid, errs)
+			}
+			if !tt.expectedValid && (len(errs) != 1 || errs[0].Code != CodePinMismatch) {
+				t.Errorf("expected a single %s error, got %v", CodePinMismatch, errs)
+			}
+		})
+	}
+}
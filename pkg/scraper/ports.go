@@ -0,0 +1,37 @@
+package scraper
+
+import "fmt"
+
+// DefaultPort is the port scanned when none is configured.
+const DefaultPort = 443
+
+// PortPolicy restricts which ports a Scanner is allowed to connect to. It is a
+// guardrail for shared tooling, e.g. to stop users from pointing the scanner
+// at database ports.
+type PortPolicy struct {
+	// Allowed, when non-empty, is the exhaustive list of ports that may be
+	// scanned.
+	Allowed []int
+
+	// Denied lists ports that may never be scanned. Denied takes precedence
+	// over Allowed.
+	Denied []int
+}
+
+// Check returns an error if the policy does not permit scanning the port.
+func (p PortPolicy) Check(port int) error {
+	for _, denied := range p.Denied {
+		if port == denied {
+			return fmt.Errorf("port %d is denied by the port policy", port)
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return nil
+	}
+	for _, allowed := range p.Allowed {
+		if port == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("port %d is not in the allowed ports %v", port, p.Allowed)
+}
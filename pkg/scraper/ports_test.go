@@ -0,0 +1,44 @@
+package scraper
+
+import "testing"
+
+func TestPortPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    PortPolicy
+		port      int
+		expectErr bool
+	}{
+		{name: "empty policy permits everything", policy: PortPolicy{}, port: 5432},
+		{name: "allowed port", policy: PortPolicy{Allowed: []int{443, 8443}}, port: 8443},
+		{name: "port outside allow list", policy: PortPolicy{Allowed: []int{443}}, port: 3306, expectErr: true},
+		{name: "denied port", policy: PortPolicy{Denied: []int{5432}}, port: 5432, expectErr: true},
+		{name: "deny wins over allow", policy: PortPolicy{Allowed: []int{443}, Denied: []int{443}}, port: 443, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.port)
+			if tt.expectErr && err == nil {
+				t.Errorf("expected an error for port %d", tt.port)
+			} else if !tt.expectErr && err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestScannerEnforcesPortPolicy(t *testing.T) {
+	s := NewScanner(WithPort(5432), WithPortPolicy(PortPolicy{Denied: []int{5432}}))
+	details, err := s.ScrapeTLS([]string{"db.example.com"})
+	if len(details) != 0 {
+		t.Errorf("expected no details, got %d", len(details))
+	}
+	multiErr, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("expected a *MultiError, got %T", err)
+	}
+	if _, ok := multiErr.Errors["db.example.com"]; !ok {
+		t.Errorf("expected an error for db.example.com, got %v", multiErr.Errors)
+	}
+}
@@ -0,0 +1,29 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+)
+
+// CodeIsPrecertificate is reported when the leaf carries the Certificate
+// Transparency poison extension. Precertificates are only for CT logs and
+// should never be served.
+const CodeIsPrecertificate = "IS_PRECERTIFICATE"
+
+// oidCTPoison is the critical extension marking a CT precertificate, from
+// RFC 6962 section 3.1.
+var oidCTPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+// checkPrecertificate reports an IS_PRECERTIFICATE validation error when cert
+// carries the CT poison extension.
+func checkPrecertificate(cert *x509.Certificate) *ValidationError {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidCTPoison) {
+			return &ValidationError{
+				Code:    CodeIsPrecertificate,
+				Message: "certificate is a CT precertificate and should not be served",
+			}
+		}
+	}
+	return nil
+}
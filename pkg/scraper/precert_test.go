@@ -0,0 +1,46 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+)
+
+func TestValidateCertificatePrecertificate(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	precert, _ := issueTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "example.com"},
+		DNSNames:    []string{"example.com"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		// The poison extension holds an ASN.1 NULL.
+		ExtraExtensions: []pkix.Extension{{Id: oidCTPoison, Critical: true, Value: []byte{0x05, 0x00}}},
+	}, ca, caKey)
+	leaf := newTestLeaf(t, ca, caKey, "example.com")
+
+	tests := []struct {
+		name            string
+		cert            *x509.Certificate
+		expectedPrecert bool
+	}{
+		{"precertificate", precert, true},
+		{"certificate", leaf, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, errs := ValidateCertificate(tt.cert, []*x509.Certificate{tt.cert}, "example.com", ValidationOptions{Roots: roots})
+			codes := strings.Join(validationCodes(errs), ",")
+			if strings.Contains(codes, CodeIsPrecertificate) != tt.expectedPrecert {
+				t.Errorf("expected precertificate %t, got codes %s", tt.expectedPrecert, codes)
+			}
+			if valid == tt.expectedPrecert {
+				t.Errorf("expected valid %t, got %t", !tt.expectedPrecert, valid)
+			}
+		})
+	}
+}
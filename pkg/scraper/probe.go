@@ -0,0 +1,111 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// ProbeResult reports whether a TLS service answered on a target, without
+// any details of its certificate.
+type ProbeResult struct {
+	Target     string `json:"target"`
+	Port       int    `json:"port"`
+	Reachable  bool   `json:"reachable"`
+	TLSVersion string `json:"tls_version,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// probe completes a TLS handshake with website and reports the version
+// negotiated, giving up when ctx is done. The certificate is neither parsed
+// nor validated.
+func (s *Scanner) probe(ctx context.Context, website string) *ProbeResult {
+	domain, port := s.hostPort(website)
+	result := &ProbeResult{Target: website, Port: port}
+	if err := s.portPolicy.Check(port); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	address := net.JoinHostPort(domain, strconv.Itoa(port))
+	conn, err := s.dialer(ctx, domain, s.sniFor(domain), nil, 0).Dial("tcp", address)
+	if err != nil && s.tlsFallback && !s.dtlsEnabled && isVersionError(err) {
+		conn, err = s.dialer(ctx, domain, s.sniFor(domain), nil, fallbackMinVersion).Dial("tcp", address)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+
+	result.Reachable = true
+	if tlsConn, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		result.TLSVersion = TLSVersionName(tlsConn.ConnectionState().Version)
+	} else {
+		result.Error = fmt.Sprintf("expected a ConnectionStateGetter, got %T", conn)
+	}
+	return result
+}
+
+// ProbeTLS checks concurrently whether each of the websites completes a TLS
+// handshake, skipping certificate extraction and validation entirely. This is
+// much lighter than ScrapeTLS, for a first pass over a large range before a
+// full scan. A result is returned for every website, in the same order.
+func (s *Scanner) ProbeTLS(websites []string) []*ProbeResult {
+	return s.ProbeTLSContext(context.Background(), websites)
+}
+
+// ProbeTLSContext is like ProbeTLS, but stops when ctx is done: handshakes in
+// flight are abandoned and websites not yet started are not dialled. Their
+// results carry the context's error.
+func (s *Scanner) ProbeTLSContext(ctx context.Context, websites []string) []*ProbeResult {
+	results := make([]*ProbeResult, len(websites))
+
+	sem := make(chan struct{}, s.concurrency)
+	stopRamp := rampUp(sem, s.rampUp)
+	defer stopRamp()
+
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range websites {
+			queue <- i
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < s.concurrency && w < len(websites); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i] = s.probeQueued(ctx, sem, websites[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// probeQueued probes site once a concurrency token is free in sem, unless
+// ctx is done first.
+func (s *Scanner) probeQueued(ctx context.Context, sem chan struct{}, site string) *ProbeResult {
+	err := ctx.Err()
+	if err == nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		_, port := s.hostPort(site)
+		return &ProbeResult{Target: site, Port: port, Error: fmt.Sprintf("probe cancelled: %v", err)}
+	}
+	defer func() { <-sem }()
+	return s.probe(ctx, site)
+}
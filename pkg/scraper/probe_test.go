@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProbeTLS(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	// A listener that is closed straight away leaves a port nothing answers on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	scanner := NewScanner(WithPort(port), WithDomainPorts(map[string]int{"localhost": closedPort}))
+	results := scanner.ProbeTLS([]string{host, "localhost"})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	open := results[0]
+	if open.Target != host || open.Port != port || !open.Reachable || open.Error != "" {
+		t.Errorf("expected %s:%d to be reachable, got %+v", host, port, open)
+	}
+	if open.TLSVersion != "TLS 1.3" {
+		t.Errorf("expected TLS 1.3, got %q", open.TLSVersion)
+	}
+
+	closed := results[1]
+	if closed.Target != "localhost" || closed.Port != closedPort || closed.Reachable || closed.Error == "" {
+		t.Errorf("expected localhost:%d to be unreachable with an error, got %+v", closedPort, closed)
+	}
+}
+
+func TestProbeTLSPortPolicy(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	scanner := NewScanner(WithPort(port), WithPortPolicy(PortPolicy{Denied: []int{port}}))
+	results := scanner.ProbeTLS([]string{host})
+	if results[0].Reachable || results[0].Error == "" {
+		t.Errorf("expected the denied port not to be probed, got %+v", results[0])
+	}
+}
+
+func TestProbeTLSContextCancelled(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := NewScanner(WithPort(port)).ProbeTLSContext(ctx, []string{host, "localhost"})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Reachable || !strings.Contains(result.Error, context.Canceled.Error()) {
+			t.Errorf("expected %s not to be probed once cancelled, got %+v", result.Target, result)
+		}
+	}
+}
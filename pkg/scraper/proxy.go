@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ProxyDialer is a Dialer that opens TCP connections through a SOCKS5 or
+// HTTP proxy, which connects to the target on its behalf. The TLS handshake
+// then runs over the tunnel, end to end with the target. See WithProxy.
+type ProxyDialer struct {
+	proxyURL *url.URL
+	address  string
+	socks    proxy.ContextDialer
+}
+
+// NewProxyDialer returns a ProxyDialer for proxyURL, whose scheme is
+// "socks5", "socks5h" or "http". Credentials in the URL are sent to the
+// proxy. The port defaults to 1080 for SOCKS5 and 80 for HTTP. Host names
+// are always resolved by the proxy.
+func NewProxyDialer(proxyURL *url.URL) (*ProxyDialer, error) {
+	d := &ProxyDialer{proxyURL: proxyURL}
+	port := proxyURL.Port()
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		if port == "" {
+			port = "1080"
+		}
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected socks5, socks5h or http", proxyURL.Scheme)
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", proxyURL.Redacted())
+	}
+	d.address = net.JoinHostPort(proxyURL.Hostname(), port)
+
+	if proxyURL.Scheme != "http" {
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", d.address, auth, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		d.socks = socks.(proxy.ContextDialer)
+	}
+	return d, nil
+}
+
+// ProxyFromEnvironment returns the proxy URL from the HTTPS_PROXY or, failing
+// that, the ALL_PROXY environment variable, or their lower-case forms, and
+// nil when neither is set. NO_PROXY isn't consulted.
+func ProxyFromEnvironment() (*url.URL, error) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			proxyURL, err := url.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", name, err)
+			}
+			return proxyURL, nil
+		}
+	}
+	return nil, nil
+}
+
+// Dial opens a connection to address through the proxy.
+func (d *ProxyDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext is like Dial, but gives up when ctx is done.
+func (d *ProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.socks != nil {
+		return d.socks.DialContext(ctx, network, address)
+	}
+	return d.connect(ctx, network, address)
+}
+
+// connect opens a tunnel to address with an HTTP CONNECT request.
+func (d *ProxyDialer) connect(ctx context.Context, network, address string) (net.Conn, error) {
+	var netDialer net.Dialer
+	conn, err := netDialer.DialContext(ctx, network, d.address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+		credentials := d.proxyURL.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The server speaks only after the TLS client hello, so nothing should
+	// follow the response that the reader could swallow.
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", address, resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("proxy sent unexpected data after CONNECT to %s", address)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
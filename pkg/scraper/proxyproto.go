@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// ProxyHeader configures a HAProxy PROXY protocol header sent before the TLS
+// handshake, for backends behind an L4 proxy that require one.
+type ProxyHeader struct {
+	// Version is the protocol version, 1 (text) or 2 (binary).
+	Version int
+
+	// Source is the claimed client address. The local address of the
+	// connection is used when nil.
+	Source *net.TCPAddr
+
+	// Destination is the claimed server address. The remote address of the
+	// connection is used when nil.
+	Destination *net.TCPAddr
+}
+
+// write sends the header on conn, filling in missing addresses from the
+// connection itself.
+func (h ProxyHeader) write(conn net.Conn) error {
+	src, dst := h.Source, h.Destination
+	if src == nil {
+		local, ok := conn.LocalAddr().(*net.TCPAddr)
+		if !ok {
+			return fmt.Errorf("PROXY protocol requires a TCP connection, got %T", conn.LocalAddr())
+		}
+		src = local
+	}
+	if dst == nil {
+		remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+		if !ok {
+			return fmt.Errorf("PROXY protocol requires a TCP connection, got %T", conn.RemoteAddr())
+		}
+		dst = remote
+	}
+
+	header, err := EncodeProxyHeader(h.Version, src, dst)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(header)
+	return err
+}
+
+// EncodeProxyHeader returns a PROXY protocol header of the given version for
+// a TCP connection from src to dst.
+func EncodeProxyHeader(version int, src, dst *net.TCPAddr) ([]byte, error) {
+	src4, dst4 := src.IP.To4(), dst.IP.To4()
+	if (src4 == nil) != (dst4 == nil) {
+		return nil, fmt.Errorf("PROXY protocol source %s and destination %s must be the same address family", src, dst)
+	}
+
+	switch version {
+	case 1:
+		family := "TCP6"
+		if src4 != nil {
+			family = "TCP4"
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, src.IP, dst.IP, src.Port, dst.Port)), nil
+	case 2:
+		header := append([]byte{}, proxyV2Signature...)
+		// Version 2, PROXY command.
+		header = append(header, 0x21)
+		var addresses []byte
+		if src4 != nil {
+			// AF_INET over STREAM.
+			header = append(header, 0x11)
+			addresses = append(append(addresses, src4...), dst4...)
+		} else {
+			// AF_INET6 over STREAM.
+			header = append(header, 0x21)
+			addresses = append(append(addresses, src.IP.To16()...), dst.IP.To16()...)
+		}
+		addresses = binary.BigEndian.AppendUint16(addresses, uint16(src.Port))
+		addresses = binary.BigEndian.AppendUint16(addresses, uint16(dst.Port))
+		header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+		return append(header, addresses...), nil
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+}
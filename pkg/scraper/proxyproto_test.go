@@ -0,0 +1,158 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestEncodeProxyHeader(t *testing.T) {
+	src4 := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+	dst4 := &net.TCPAddr{IP: net.ParseIP("198.51.100.2"), Port: 443}
+	src6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+
+	v1, err := EncodeProxyHeader(1, src4, dst4)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if expected := "PROXY TCP4 192.0.2.1 198.51.100.2 56324 443\r\n"; string(v1) != expected {
+		t.Errorf("expected %q, got %q", expected, v1)
+	}
+
+	v1, err = EncodeProxyHeader(1, src6, dst6)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if expected := "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"; string(v1) != expected {
+		t.Errorf("expected %q, got %q", expected, v1)
+	}
+
+	v2, err := EncodeProxyHeader(2, src4, dst4)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	expected := append(append([]byte{}, proxyV2Signature...), 0x21, 0x11, 0x00, 0x0C,
+		192, 0, 2, 1, 198, 51, 100, 2, 0xDC, 0x04, 0x01, 0xBB)
+	if !bytes.Equal(v2, expected) {
+		t.Errorf("expected %x, got %x", expected, v2)
+	}
+
+	if _, err := EncodeProxyHeader(2, src4, dst6); err == nil {
+		t.Errorf("expected an error for mixed address families")
+	}
+	if _, err := EncodeProxyHeader(3, src4, dst4); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
+
+// readProxyHeader parses a v1 or v2 PROXY header from r and returns the
+// claimed source and destination as "ip:port" strings.
+func readProxyHeader(r *bufio.Reader) (string, string, error) {
+	prefix, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return "", "", err
+	}
+
+	if !bytes.Equal(prefix, proxyV2Signature) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		var family, srcIP, dstIP string
+		var srcPort, dstPort int
+		if _, err := fmt.Sscanf(line, "PROXY %s %s %s %d %d\r\n", &family, &srcIP, &dstIP, &srcPort, &dstPort); err != nil {
+			return "", "", err
+		}
+		return net.JoinHostPort(srcIP, fmt.Sprint(srcPort)), net.JoinHostPort(dstIP, fmt.Sprint(dstPort)), nil
+	}
+
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return "", "", err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", "", err
+	}
+	size := net.IPv4len
+	if fixed[13] == 0x21 {
+		size = net.IPv6len
+	}
+	src := &net.TCPAddr{IP: net.IP(body[:size]), Port: int(binary.BigEndian.Uint16(body[2*size:]))}
+	dst := &net.TCPAddr{IP: net.IP(body[size : 2*size]), Port: int(binary.BigEndian.Uint16(body[2*size+2:]))}
+	return src.String(), dst.String(), nil
+}
+
+// prefixedConn is a net.Conn whose reads come from a buffered reader that may
+// already hold data read off the connection.
+type prefixedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *prefixedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func TestStepDialerSendsProxyHeader(t *testing.T) {
+	leaf, leafKey := issueTestCert(t, &x509.Certificate{DNSNames: []string{"backend.example.com"}}, nil, nil)
+	serverCert := tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey}
+
+	for _, version := range []int{1, 2} {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			defer ln.Close()
+
+			type header struct {
+				src, dst string
+				err      error
+			}
+			received := make(chan header, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					received <- header{err: err}
+					return
+				}
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				src, dst, err := readProxyHeader(r)
+				received <- header{src: src, dst: dst, err: err}
+				_ = tls.Server(&prefixedConn{Conn: conn, r: r}, &tls.Config{Certificates: []tls.Certificate{serverCert}}).Handshake()
+			}()
+
+			proxy := ProxyHeader{
+				Version: version,
+				Source:  &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40000},
+			}
+			dialer := &stepDialer{
+				config:   &tls.Config{InsecureSkipVerify: true, ServerName: "backend.example.com"},
+				preamble: proxy.write,
+			}
+			_, err = dialer.Dial("tcp", ln.Addr().String())
+			got := <-received
+			if got.err != nil {
+				t.Fatalf("failed to parse PROXY header: %v", got.err)
+			}
+			if err != nil {
+				t.Fatalf("expected the handshake to succeed after the header, got: %v", err)
+			}
+			if got.src != "203.0.113.7:40000" {
+				t.Errorf("expected source 203.0.113.7:40000, got %s", got.src)
+			}
+			if got.dst != ln.Addr().String() {
+				t.Errorf("expected destination %s, got %s", ln.Addr(), got.dst)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package scraper
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+)
+
+// publicKeyInfo returns the algorithm of cert's public key, such as "RSA",
+// "ECDSA" or "Ed25519", and its size in bits: the modulus for RSA and the
+// curve for ECDSA. The size is 0 for keys of other types, and both are empty
+// when the algorithm is unknown.
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return x509.RSA.String(), key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return x509.ECDSA.String(), key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return x509.Ed25519.String(), len(key) * 8
+	}
+	if cert.PublicKeyAlgorithm == x509.UnknownPublicKeyAlgorithm {
+		return "", 0
+	}
+	return cert.PublicKeyAlgorithm.String(), 0
+}
+
+// isWeakSignature reports whether algorithm is based on MD2, MD5 or SHA-1,
+// whose collisions are practical to find.
+func isWeakSignature(algorithm x509.SignatureAlgorithm) bool {
+	switch algorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	}
+	return false
+}
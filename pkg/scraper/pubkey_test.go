@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+)
+
+func TestPublicKeyInfo(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		key               crypto.PublicKey
+		algorithm         x509.PublicKeyAlgorithm
+		expectedAlgorithm string
+		expectedBits      int
+	}{
+		{"rsa", &rsaKey.PublicKey, x509.RSA, "RSA", 1024},
+		{"ecdsa p-256", &p256Key.PublicKey, x509.ECDSA, "ECDSA", 256},
+		{"ecdsa p-384", &p384Key.PublicKey, x509.ECDSA, "ECDSA", 384},
+		{"ed25519", edKey, x509.Ed25519, "Ed25519", 256},
+		{"unparsed dsa", nil, x509.DSA, "DSA", 0},
+		{"unknown", nil, x509.UnknownPublicKeyAlgorithm, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := generateMockConnectionState()
+			state.PeerCertificates[0].PublicKey = tt.key
+			state.PeerCertificates[0].PublicKeyAlgorithm = tt.algorithm
+
+			cd := &CertDetails{}
+			if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, &stateDialer{state: state}, 0); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if cd.PublicKeyAlgorithm != tt.expectedAlgorithm || cd.PublicKeyBits != tt.expectedBits {
+				t.Errorf("expected %s %d, got %s %d", tt.expectedAlgorithm, tt.expectedBits, cd.PublicKeyAlgorithm, cd.PublicKeyBits)
+			}
+		})
+	}
+}
+
+func TestSignatureAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm    x509.SignatureAlgorithm
+		expectedName string
+		expectedWeak bool
+	}{
+		{x509.SHA1WithRSA, "SHA1-RSA", true},
+		{x509.ECDSAWithSHA1, "ECDSA-SHA1", true},
+		{x509.MD5WithRSA, "MD5-RSA", true},
+		{x509.SHA256WithRSA, "SHA256-RSA", false},
+		{x509.ECDSAWithSHA384, "ECDSA-SHA384", false},
+		{x509.PureEd25519, "Ed25519", false},
+	}
+	for _, tt := range tests {
+		state := generateMockConnectionState()
+		state.PeerCertificates[0].SignatureAlgorithm = tt.algorithm
+
+		cd := &CertDetails{}
+		if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, &stateDialer{state: state}, 0); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if cd.SignatureAlgorithm != tt.expectedName || cd.WeakSignature != tt.expectedWeak {
+			t.Errorf("%v: expected %s weak: %v, got %s weak: %v", tt.algorithm, tt.expectedName, tt.expectedWeak, cd.SignatureAlgorithm, cd.WeakSignature)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package scraper
+
+import "time"
+
+// rampUp limits sem to a single free slot and then frees the remaining slots
+// one at a time, evenly spread over the given period, so that a scan reaches
+// its full concurrency gradually. The returned function stops the ramp early
+// and must be called once the scan is done.
+//
+// Slots are held by filling sem with placeholder tokens. Taking a token back
+// out regardless of who sent it is safe, because the ramp never removes more
+// tokens than it added.
+func rampUp(sem chan struct{}, period time.Duration) func() {
+	steps := cap(sem) - 1
+	if steps < 1 || period <= 0 {
+		return func() {}
+	}
+	for i := 0; i < steps; i++ {
+		sem <- struct{}{}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(period / time.Duration(steps))
+		defer ticker.Stop()
+		for i := 0; i < steps; i++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				<-sem
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRampUp(t *testing.T) {
+	sem := make(chan struct{}, 4)
+	stop := rampUp(sem, 60*time.Millisecond)
+	defer stop()
+
+	if free := cap(sem) - len(sem); free != 1 {
+		t.Errorf("expected 1 free slot at the start of the ramp, got %d", free)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(sem) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if free := cap(sem) - len(sem); free != 4 {
+		t.Errorf("expected the final concurrency to be 4, got %d", free)
+	}
+}
+
+func TestRampUpStop(t *testing.T) {
+	sem := make(chan struct{}, 4)
+	stop := rampUp(sem, time.Hour)
+	stop()
+
+	time.Sleep(10 * time.Millisecond)
+	if len(sem) != 3 {
+		t.Errorf("expected the stopped ramp to release nothing, got %d held slots", len(sem))
+	}
+}
+
+func TestRampUpDisabled(t *testing.T) {
+	sem := make(chan struct{}, 4)
+	rampUp(sem, 0)()
+	if len(sem) != 0 {
+		t.Errorf("expected no slots to be held without a ramp period, got %d", len(sem))
+	}
+}
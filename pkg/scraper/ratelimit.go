@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out events so that at most one starts every interval,
+// however many goroutines are waiting. Each waiter reserves the next free
+// slot, so waiters are let through in the order they arrived.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing perSecond events a second, or
+// nil, which never waits, when perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the caller's slot comes up, or returns ctx's error if ctx
+// is done first. A slot given up that way is not handed to anyone else.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedDialer is a Dialer that waits for a slot from limiter before
+// each dial, giving up if ctx is done first.
+type rateLimitedDialer struct {
+	Dialer
+	ctx     context.Context
+	limiter *rateLimiter
+}
+
+// Dial waits for the rate limit and dials address with the wrapped Dialer.
+func (d *rateLimitedDialer) Dial(network, address string) (net.Conn, error) {
+	if err := d.limiter.wait(d.ctx); err != nil {
+		return nil, err
+	}
+	return d.Dialer.Dial(network, address)
+}
@@ -0,0 +1,76 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	host, port := newTestTarget(t)
+	websites, overrides := aliasTargets(host, 6)
+
+	// The first dial starts at once and each of the other five waits a
+	// further 50ms, however many run concurrently.
+	scanner := NewScanner(WithPort(port), WithConcurrency(len(websites)), WithRateLimit(20), WithResolveOverrides(overrides))
+	start := time.Now()
+	if _, err := scanner.ScrapeTLS(websites); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected 6 scrapes at 20 a second to take at least 250ms, took %v", elapsed)
+	}
+}
+
+func TestWithRateLimitExtraDials(t *testing.T) {
+	host, port := newTestTarget(t)
+	websites, overrides := aliasTargets(host, 3)
+
+	// Strict SNI makes a second handshake with each target, so six dials are
+	// made and the last waits 250ms.
+	scanner := NewScanner(WithPort(port), WithConcurrency(len(websites)), WithRateLimit(20), WithStrictSNI(true), WithResolveOverrides(overrides))
+	start := time.Now()
+	if _, err := scanner.ScrapeTLS(websites); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected 6 dials at 20 a second to take at least 250ms, took %v", elapsed)
+	}
+}
+
+func TestProbeTLSRateLimit(t *testing.T) {
+	host, port := newTestTarget(t)
+	websites, overrides := aliasTargets(host, 6)
+
+	scanner := NewScanner(WithPort(port), WithConcurrency(len(websites)), WithRateLimit(20), WithResolveOverrides(overrides))
+	start := time.Now()
+	for _, result := range scanner.ProbeTLS(websites) {
+		if !result.Reachable {
+			t.Errorf("expected %s to be reachable, got %+v", result.Target, result)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected 6 probes at 20 a second to take at least 250ms, took %v", elapsed)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	var unlimited *rateLimiter
+	if newRateLimiter(0) != nil {
+		t.Error("expected no limiter for a rate of 0")
+	}
+	if err := unlimited.wait(context.Background()); err != nil {
+		t.Errorf("expected an unlimited wait to return at once, got: %v", err)
+	}
+
+	limiter := newRateLimiter(1)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("expected the first wait to return at once, got: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the second wait to end with the context, got: %v", err)
+	}
+}
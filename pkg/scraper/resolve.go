@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// addressDialer is a Dialer that connects to fixed hosts instead of the one
+// in the dialled address, keeping the port. The TLS ServerName must be set
+// explicitly on the wrapped dialer so that SNI still uses the original name.
+type addressDialer struct {
+	Dialer
+	hosts []string
+}
+
+// Dial replaces the host of address with each of the hosts in turn and dials
+// it with the wrapped Dialer, returning the first connection made. If every
+// host fails, the error from the first is returned, as net.Dialer does.
+func (d *addressDialer) Dial(network, address string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, host := range d.hosts {
+		conn, err := d.Dialer.Dial(network, net.JoinHostPort(host, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// preResolve resolves every host up front, bounded by the scanner's
+// concurrency. It returns the addresses of the hosts that resolved, and an
+// error for each host that does not exist. Hosts that failed to resolve for
+// any other reason are left out of both so that dialling retries them. Hosts
+// with a resolve override are not looked up.
+func (s *Scanner) preResolve(ctx context.Context, hosts []string) (map[string][]net.IP, map[string]error) {
+	resolved := make(map[string][]net.IP, len(hosts))
+	failed := make(map[string]error)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.concurrency)
+
+	for _, host := range hosts {
+		name, _ := s.hostPort(host)
+		if _, ok := s.overrides[name]; ok {
+			continue
+		}
+		wg.Add(1)
+		go func(host, name string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			ips, err := s.lookupIP(ctx, name)
+			<-sem
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			var dnsErr *net.DNSError
+			switch {
+			case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+				failed[host] = fmt.Errorf("resolving %s: %w", name, err)
+			case err == nil && len(ips) > 0:
+				resolved[host] = ips
+			}
+		}(host, name)
+	}
+	wg.Wait()
+
+	return resolved, failed
+}
+
+// lookupIP resolves a host using the scanner's resolver.
+func (s *Scanner) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if s.lookup != nil {
+		return s.lookup(ctx, host)
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func fakeLookup(ctx context.Context, host string) ([]net.IP, error) {
+	switch host {
+	case "exists.example.com":
+		return []net.IP{net.ParseIP("192.0.2.10")}, nil
+	case "flaky.example.com":
+		return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	default:
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+}
+
+func TestPreResolve(t *testing.T) {
+	s := NewScanner(WithConcurrency(2))
+	s.lookup = fakeLookup
+
+	resolved, failed := s.preResolve(context.Background(), []string{"exists.example.com", "missing.example.com", "flaky.example.com"})
+
+	if ips := resolved["exists.example.com"]; len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.10")) {
+		t.Errorf("expected exists.example.com to resolve to 192.0.2.10, got %v", ips)
+	}
+	var dnsErr *net.DNSError
+	if err := failed["missing.example.com"]; err == nil || !errors.As(err, &dnsErr) {
+		t.Errorf("expected a DNS error for missing.example.com, got %v", err)
+	}
+	if _, ok := failed["flaky.example.com"]; ok {
+		t.Errorf("expected a transient failure not to be recorded as an error")
+	}
+	if _, ok := resolved["flaky.example.com"]; ok {
+		t.Errorf("expected a transient failure not to be resolved")
+	}
+}
+
+func TestScrapeTLSPreResolveFailsFast(t *testing.T) {
+	s := NewScanner(WithPreResolve(true))
+	s.lookup = fakeLookup
+
+	details, err := s.ScrapeTLS([]string{"missing.example.com"})
+	if len(details) != 0 {
+		t.Errorf("expected no details, got %d", len(details))
+	}
+	multiErr, ok := err.(*MultiError)
+	if !ok {
+		t.Fatalf("expected a *MultiError, got %T", err)
+	}
+	if e := multiErr.Errors["missing.example.com"]; e == nil || !strings.HasPrefix(e.Error(), "resolving missing.example.com") {
+		t.Errorf("expected a resolution error, got %v", e)
+	}
+}
+
+type recordingDialer struct {
+	addresses []string
+}
+
+func (r *recordingDialer) Dial(network, address string) (net.Conn, error) {
+	r.addresses = append(r.addresses, address)
+	return nil, fmt.Errorf("not connected to %s", address)
+}
+
+func TestAddressDialer(t *testing.T) {
+	recorder := &recordingDialer{}
+	dialer := &addressDialer{Dialer: recorder, hosts: []string{"2001:db8::1", "192.0.2.1"}}
+
+	_, err := dialer.Dial("tcp", "example.com:8443")
+	if got := strings.Join(recorder.addresses, ","); got != "[2001:db8::1]:8443,192.0.2.1:8443" {
+		t.Errorf("expected each address to be dialled in turn, got %s", got)
+	}
+	if err == nil || err.Error() != "not connected to [2001:db8::1]:8443" {
+		t.Errorf("expected the first address's error, got %v", err)
+	}
+}
+
+func TestPreResolveTriesEachAddress(t *testing.T) {
+	host, port := newTestTarget(t)
+	s := NewScanner(WithPort(port), WithPreResolve(true))
+	// Nothing listens on 127.0.0.2, so the dial falls through to the test
+	// server.
+	s.lookup = func(ctx context.Context, name string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP(host)}, nil
+	}
+
+	details, err := s.ScrapeTLS([]string{"multi.example.com"})
+	if err != nil {
+		t.Fatalf("expected the second address to be dialled, got: %v", err)
+	}
+	if len(details) != 1 {
+		t.Fatalf("expected one result, got %d", len(details))
+	}
+}
+
+func TestResolveOverrides(t *testing.T) {
+	host, port := newTestTarget(t)
+	s := NewScanner(
+		WithPort(port),
+		WithPreResolve(true),
+		WithResolveOverrides(map[string]net.IP{"moved.example.net": net.ParseIP(host)}),
+	)
+	s.lookup = fakeLookup
+
+	details, err := s.ScrapeTLS([]string{"moved.example.net"})
+	if err != nil {
+		t.Fatalf("expected the override to be dialled, got: %v", err)
+	}
+	if len(details) != 1 || details[0].Domain != "moved.example.net" {
+		t.Fatalf("expected details for moved.example.net, got %v", details)
+	}
+	// The test server's certificate does not cover the overridden name.
+	if codes := strings.Join(validationCodes(details[0].ValidationErrors), ","); !strings.Contains(codes, CodeHostnameMismatch) {
+		t.Errorf("expected the certificate to be validated against moved.example.net")
+	}
+}
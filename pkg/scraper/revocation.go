@@ -0,0 +1,44 @@
+package scraper
+
+import "crypto/x509"
+
+// Revocation codes reported as warnings in ValidationError.Code.
+const (
+	// CodeNoRevocationInfo is reported when a certificate has neither an OCSP
+	// responder nor a CRL distribution point, so it can't be checked for
+	// revocation at all.
+	CodeNoRevocationInfo = "NO_REVOCATION_INFO"
+
+	// CodeOCSPOnly and CodeCRLOnly are reported, when requested, for
+	// certificates offering only one of the two revocation mechanisms.
+	CodeOCSPOnly = "OCSP_ONLY"
+	CodeCRLOnly  = "CRL_ONLY"
+)
+
+// checkRevocationInfo reports certificates without revocation endpoints, and
+// when partial is set, certificates with only an OCSP responder or only a CRL
+// distribution point.
+func checkRevocationInfo(cert *x509.Certificate, partial bool) *ValidationError {
+	hasOCSP, hasCRL := len(cert.OCSPServer) > 0, len(cert.CRLDistributionPoints) > 0
+	switch {
+	case !hasOCSP && !hasCRL:
+		return &ValidationError{
+			Code:    CodeNoRevocationInfo,
+			Message: "certificate has no OCSP responder or CRL distribution point",
+			Warning: true,
+		}
+	case partial && !hasCRL:
+		return &ValidationError{
+			Code:    CodeOCSPOnly,
+			Message: "certificate has an OCSP responder but no CRL distribution point",
+			Warning: true,
+		}
+	case partial && !hasOCSP:
+		return &ValidationError{
+			Code:    CodeCRLOnly,
+			Message: "certificate has a CRL distribution point but no OCSP responder",
+			Warning: true,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestCheckRevocationInfo(t *testing.T) {
+	ocsp := []string{"http://ocsp.example.com"}
+	crl := []string{"http://crl.example.com/ca.crl"}
+
+	tests := []struct {
+		name         string
+		ocsp, crl    []string
+		partial      bool
+		expectedCode string
+	}{
+		{name: "both", ocsp: ocsp, crl: crl, partial: true},
+		{name: "neither", expectedCode: CodeNoRevocationInfo},
+		{name: "neither with partial notes", partial: true, expectedCode: CodeNoRevocationInfo},
+		{name: "OCSP only", ocsp: ocsp},
+		{name: "OCSP only with partial notes", ocsp: ocsp, partial: true, expectedCode: CodeOCSPOnly},
+		{name: "CRL only", crl: crl},
+		{name: "CRL only with partial notes", crl: crl, partial: true, expectedCode: CodeCRLOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &x509.Certificate{OCSPServer: tt.ocsp, CRLDistributionPoints: tt.crl}
+			got := checkRevocationInfo(cert, tt.partial)
+			switch {
+			case tt.expectedCode == "" && got != nil:
+				t.Errorf("expected no note, got %s", got.Code)
+			case tt.expectedCode != "" && got == nil:
+				t.Errorf("expected %s, got no note", tt.expectedCode)
+			case got != nil && got.Code != tt.expectedCode:
+				t.Errorf("expected %s, got %s", tt.expectedCode, got.Code)
+			case got != nil && !got.Warning:
+				t.Errorf("expected %s to be a warning", got.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,829 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/pion/dtls/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scotta01/tls-scrape/pkg/crl"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// Scanner scrapes TLS certificate details from a set of domains using a
+// shared configuration. State that is learnt during a scan, such as observed
+// handshake latencies, is kept on the Scanner so that it carries over between
+// calls to ScrapeTLS.
+type Scanner struct {
+	concurrency int
+	timeout     time.Duration
+	latency     *latencyTracker
+	timings     bool
+	port        int
+	domainPorts map[string]int
+	domainSNI   map[string]string
+	domainDial  map[string]time.Duration
+	startTLS    StartTLS
+	domainStart map[string]StartTLS
+	portPolicy  PortPolicy
+	pins        []string
+	domainPins  map[string][]string
+	distrusted  []string
+	roots       *x509.CertPool
+
+	proxyHeader *ProxyHeader
+	proxy       Dialer
+	cache       *resultCache
+	tlsConfig   func(*tls.Config)
+	keyLog      io.Writer
+	exemplars   bool
+	expiry      *prometheus.GaugeVec
+	strictSNI   bool
+	dtlsEnabled bool
+	rampUp      time.Duration
+	rateLimit   *rateLimiter
+	tlsFallback bool
+	maxChain    int
+	consistency int
+	breaker     *circuitBreaker
+	cooldown    time.Duration
+	ocspEnabled bool
+	ocspNonce   bool
+	crl         *crl.CRLChecker
+	aia         *aiaFetcher
+	aiaMaxSize  int64
+	onResult    *resultHandler
+
+	partialRevocationNotes bool
+
+	metricLabels []string
+	targetLabels map[string]map[string]string
+
+	overrides         map[string]net.IP
+	preResolveEnabled bool
+	lookup            func(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// Option configures a Scanner.
+type Option func(*Scanner)
+
+// WithConcurrency sets the maximum number of concurrent TLS connections.
+func WithConcurrency(concurrency int) Option {
+	return func(s *Scanner) {
+		s.concurrency = concurrency
+	}
+}
+
+// WithPort sets the port to connect to. The default is DefaultPort.
+func WithPort(port int) Option {
+	return func(s *Scanner) {
+		s.port = port
+	}
+}
+
+// WithDomainPorts sets the port to connect to for individual domains,
+// overriding the port set with WithPort. To scan a domain on more than one
+// port, list it as host:port for each instead, such as example.com:8443;
+// the port given in a website takes precedence over both.
+func WithDomainPorts(ports map[string]int) Option {
+	return func(s *Scanner) {
+		s.domainPorts = ports
+	}
+}
+
+// WithDomainSNI sets the server name sent as SNI, and that the certificate is
+// validated against, for individual domains, instead of the domain itself.
+func WithDomainSNI(names map[string]string) Option {
+	return func(s *Scanner) {
+		s.domainSNI = names
+	}
+}
+
+// WithTimeout sets the timeout for each dial, including the TLS handshake, so
+// that a server which accepts the connection but never completes the
+// handshake can't hold on to a concurrency slot. The default is
+// DefaultTimeout, and zero means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Scanner) {
+		s.timeout = timeout
+	}
+}
+
+// WithDomainTimeouts sets the dial timeout for individual domains, overriding
+// WithTimeout and the adaptive timeout.
+func WithDomainTimeouts(timeouts map[string]time.Duration) Option {
+	return func(s *Scanner) {
+		s.domainDial = timeouts
+	}
+}
+
+// WithStartTLS speaks the given protocol on each connection and upgrades it
+// with STARTTLS before the TLS handshake, for services such as SMTP, IMAP and
+// POP3 that don't start TLS straight away. It doesn't apply to DTLS.
+func WithStartTLS(protocol StartTLS) Option {
+	return func(s *Scanner) {
+		s.startTLS = protocol
+	}
+}
+
+// WithDomainStartTLS sets the STARTTLS protocol for individual domains,
+// overriding the protocol set with WithStartTLS.
+func WithDomainStartTLS(protocols map[string]StartTLS) Option {
+	return func(s *Scanner) {
+		s.domainStart = protocols
+	}
+}
+
+// WithPortPolicy restricts the ports the scanner may connect to. Targets on a
+// port rejected by the policy fail without being dialled.
+func WithPortPolicy(policy PortPolicy) Option {
+	return func(s *Scanner) {
+		s.portPolicy = policy
+	}
+}
+
+// WithAdaptiveTimeout enables the adaptive dial timeout. Each dial deadline is
+// derived from the 95th percentile of the handshake latencies observed so far,
+// plus a safety margin, and clamped to the [floor, ceiling] range. Until enough
+// samples have been collected the ceiling is used.
+func WithAdaptiveTimeout(floor, ceiling time.Duration) Option {
+	return func(s *Scanner) {
+		s.latency = newLatencyTracker(floor, ceiling)
+	}
+}
+
+// WithTimings records a DNS, TCP connect and TLS handshake timing breakdown
+// for each target in CertDetails.Timings.
+func WithTimings(enabled bool) Option {
+	return func(s *Scanner) {
+		s.timings = enabled
+	}
+}
+
+// WithPins sets the "sha256:HEX" fingerprints that every leaf certificate is
+// expected to match.
+func WithPins(pins ...string) Option {
+	return func(s *Scanner) {
+		s.pins = append(s.pins, pins...)
+	}
+}
+
+// WithDomainPins sets the "sha256:HEX" fingerprints expected for individual
+// domains. They are checked in addition to any pins set with WithPins.
+func WithDomainPins(pins map[string][]string) Option {
+	return func(s *Scanner) {
+		s.domainPins = pins
+	}
+}
+
+// WithRoots sets the root certificates that scraped certificates are
+// validated against, e.g. to trust a private CA. The system pool is used when
+// nil. To trust a private CA as well as the usual public ones, add it to a
+// copy of the system pool.
+func WithRoots(roots *x509.CertPool) Option {
+	return func(s *Scanner) {
+		s.roots = roots
+	}
+}
+
+// WithDistrustedRoots sets the "sha256:HEX" fingerprints of roots that are
+// no longer trusted. Certificates that only chain to them are reported with a
+// DISTRUSTED_ROOT validation error.
+func WithDistrustedRoots(fingerprints ...string) Option {
+	return func(s *Scanner) {
+		s.distrusted = append(s.distrusted, fingerprints...)
+	}
+}
+
+// WithPreResolve resolves every domain before any TLS handshake is attempted.
+// Domains that do not exist fail immediately, separating resolution failures
+// from TLS failures, and the resolved addresses are reused when dialling.
+func WithPreResolve(enabled bool) Option {
+	return func(s *Scanner) {
+		s.preResolveEnabled = enabled
+	}
+}
+
+// WithResolveOverrides dials the given address for a domain instead of
+// resolving it, like curl's --resolve. SNI and validation still use the
+// domain, which makes it possible to check a new backend before DNS is
+// changed to point at it.
+func WithResolveOverrides(overrides map[string]net.IP) Option {
+	return func(s *Scanner) {
+		s.overrides = overrides
+	}
+}
+
+// WithProxyProtocol sends a PROXY protocol header on each connection before
+// the TLS handshake.
+func WithProxyProtocol(header ProxyHeader) Option {
+	return func(s *Scanner) {
+		s.proxyHeader = &header
+	}
+}
+
+// WithProxy opens each TCP connection through proxy, usually a ProxyDialer,
+// before the TLS handshake, for networks where outbound connections must go
+// through a proxy. The timeout still bounds the whole dial when proxy
+// implements DialContext, as ProxyDialer does. DTLS connections don't use the
+// proxy.
+func WithProxy(proxy Dialer) Option {
+	return func(s *Scanner) {
+		s.proxy = proxy
+	}
+}
+
+// WithCache keeps successful results for ttl, so that scanning the same
+// domain and port again within that time returns the cached CertDetails
+// without a new handshake. Cached results aren't counted again in the scrape
+// metrics. At most maxEntries results are kept, or DefaultCacheSize when
+// maxEntries is not positive. A ttl that is not positive disables the cache.
+func WithCache(ttl time.Duration, maxEntries int) Option {
+	return func(s *Scanner) {
+		if ttl <= 0 {
+			s.cache = nil
+			return
+		}
+		s.cache = newResultCache(ttl, maxEntries)
+	}
+}
+
+// WithTLSConfig lets callers adjust the tls.Config used for each connection,
+// for example to set KeyLogWriter, VerifyConnection or GetClientCertificate.
+// The callback gets a new config for every dial. InsecureSkipVerify and
+// ServerName are set by the scanner after the callback runs, so changes to
+// them have no effect.
+func WithTLSConfig(configure func(*tls.Config)) Option {
+	return func(s *Scanner) {
+		s.tlsConfig = configure
+	}
+}
+
+// WithKeyLogWriter writes the TLS secrets of every handshake to w in NSS key
+// log format, as used by SSLKEYLOGFILE, so that captured scans can be
+// decrypted with tools such as Wireshark. Writes from concurrent handshakes
+// are serialized.
+func WithKeyLogWriter(w io.Writer) Option {
+	return func(s *Scanner) {
+		if w == nil {
+			s.keyLog = nil
+			return
+		}
+		s.keyLog = &lockedWriter{w: w}
+	}
+}
+
+// WithExemplars attaches the target and certificate serial as an exemplar to
+// the scrape latency histogram. Exemplars are only exposed by a metrics
+// handler that serves OpenMetrics, see GetOpenMetricsHandler.
+func WithExemplars(enabled bool) Option {
+	return func(s *Scanner) {
+		s.exemplars = enabled
+	}
+}
+
+// WithMetricLabels adds the given labels to the certificate expiry gauge,
+// taking each domain's values from targetLabels, for example the owner or
+// environment read alongside the targets. A domain without a value gets an
+// empty label. Every distinct combination of values is a new time series, so
+// only use labels with few values. Prometheus requires every series of a
+// metric to have the same labels, so the gauge is only recorded by scanners
+// using the same label names as the first Scanner created.
+func WithMetricLabels(labels []string, targetLabels map[string]map[string]string) Option {
+	return func(s *Scanner) {
+		s.metricLabels = labels
+		s.targetLabels = targetLabels
+	}
+}
+
+// WithStrictSNI makes a second handshake to each domain with an SNI the
+// server should not have a certificate for. If the certificate returned still
+// matches the domain, the server is ignoring SNI and serving a default
+// certificate, which is reported as an SNI_IGNORED validation error.
+func WithStrictSNI(enabled bool) Option {
+	return func(s *Scanner) {
+		s.strictSNI = enabled
+	}
+}
+
+// WithDTLS scans over UDP with a DTLS handshake instead of TLS over TCP, for
+// services such as VPNs and CoAP. This is experimental. Timings, PROXY
+// protocol headers and WithTLSConfig do not apply to DTLS connections.
+func WithDTLS(enabled bool) Option {
+	return func(s *Scanner) {
+		s.dtlsEnabled = enabled
+	}
+}
+
+// WithRampUp starts each scan with a concurrency of one and raises it evenly
+// to the configured concurrency over the given period, to avoid overwhelming
+// a cold DNS cache or connection tracking table at the start of a scan.
+func WithRampUp(period time.Duration) Option {
+	return func(s *Scanner) {
+		s.rampUp = period
+	}
+}
+
+// WithRateLimit limits the scan to starting perSecond dials a second, however
+// high the concurrency, so that scanning a subnet doesn't trip IDS or WAF
+// rules or overwhelm a shared load balancer. Every connection counts,
+// including probes and the extra handshakes made for WithStrictSNI,
+// WithConsistencyCheck and WithTLSFallback. The limit is shared by every scan
+// the Scanner runs. Zero, the default, means unlimited.
+func WithRateLimit(perSecond float64) Option {
+	return func(s *Scanner) {
+		s.rateLimit = newRateLimiter(perSecond)
+	}
+}
+
+// WithPartialRevocationNotes reports certificates that offer only one of OCSP
+// and CRLs for revocation checking. Certificates offering neither are always
+// reported.
+func WithPartialRevocationNotes(enabled bool) Option {
+	return func(s *Scanner) {
+		s.partialRevocationNotes = enabled
+	}
+}
+
+// WithTLSFallback retries a handshake that fails in a way that looks version
+// related once more, offering versions down to TLS 1.0. Certificates scraped
+// this way get a LEGACY_TLS_ONLY warning naming the version that succeeded.
+// This does not apply to DTLS.
+func WithTLSFallback(enabled bool) Option {
+	return func(s *Scanner) {
+		s.tlsFallback = enabled
+	}
+}
+
+// WithMaxChainDepth keeps at most depth certificates of the chain a server
+// sends, protecting memory and downstream tooling from very long or looping
+// chains. Truncated chains get a CHAIN_TOO_LONG warning. A depth of zero or
+// less keeps every certificate. DefaultMaxChainDepth is used otherwise.
+func WithMaxChainDepth(depth int) Option {
+	return func(s *Scanner) {
+		s.maxChain = depth
+	}
+}
+
+// WithConsistencyCheck makes attempts handshakes with each target instead of
+// one, and reports an INCONSISTENT_CERTS validation error, listing the serials
+// seen, when they don't all return the same leaf certificate. Values below two
+// turn the check off.
+func WithConsistencyCheck(attempts int) Option {
+	return func(s *Scanner) {
+		s.consistency = attempts
+	}
+}
+
+// WithCircuitBreaker skips the remaining targets in a network once threshold
+// targets in it have timed out in a row, returning an error wrapping
+// ErrCircuitOpen for them instead of waiting for each to time out. Networks
+// are the IPv4 and IPv6 prefixes of the given lengths. Only targets whose
+// address is known before dialing, because they are IP addresses, resolve
+// overrides or were pre-resolved, are grouped. After DefaultCircuitCooldown,
+// or the period set by WithCircuitCooldown, one target in a skipped network
+// is tried again, and the network is dialled as usual if it doesn't time out.
+// A threshold below one turns the breaker off.
+func WithCircuitBreaker(threshold, prefixV4, prefixV6 int) Option {
+	return func(s *Scanner) {
+		if threshold < 1 {
+			s.breaker = nil
+			return
+		}
+		s.breaker = newCircuitBreaker(threshold, prefixV4, prefixV6)
+	}
+}
+
+// WithCircuitCooldown sets how long WithCircuitBreaker skips a network before
+// trying one of its targets again. DefaultCircuitCooldown is used when
+// cooldown is zero or less.
+func WithCircuitCooldown(cooldown time.Duration) Option {
+	return func(s *Scanner) {
+		s.cooldown = cooldown
+	}
+}
+
+// WithOCSP checks the revocation status of each leaf certificate with its
+// OCSP responder, recording the result in CertDetails.OCSPStatus. The issuer
+// must be in the chain the server sent. A check that fails, e.g. because the
+// responder is unreachable, doesn't fail the scrape; the reason is recorded
+// in CertDetails.OCSPError instead.
+func WithOCSP(enabled bool) Option {
+	return func(s *Scanner) {
+		s.ocspEnabled = enabled
+	}
+}
+
+// WithOCSPNonce adds a random nonce to each OCSP request made for WithOCSP
+// and checks that the responder echoes it back, so that a replayed response
+// can't hide a revocation. Responders that ignore the nonce are still
+// accepted, and the request is retried without one if it is rejected.
+func WithOCSPNonce(enabled bool) Option {
+	return func(s *Scanner) {
+		s.ocspNonce = enabled
+	}
+}
+
+// WithCRL checks the revocation status of each leaf certificate against the
+// CRL it names, recording the result in CertDetails.CRLStatus, for issuers
+// whose OCSP responders are unreliable. The issuer must be in the chain the
+// server sent, to check the CRL's signature. Each download is bounded by
+// crl.DefaultTimeout, and up to crl.DefaultMaxCachedCRLs CRLs are reused
+// across targets until their next update. A check that fails doesn't fail the scrape; the reason is recorded
+// in CertDetails.CRLError instead.
+func WithCRL(enabled bool) Option {
+	return func(s *Scanner) {
+		s.crl = nil
+		if enabled {
+			s.crl = &crl.CRLChecker{}
+		}
+	}
+}
+
+// WithAIA downloads the intermediates a server failed to send from the AIA
+// caIssuers URLs of its certificates, as browsers do, when its chain can't
+// otherwise be verified. Certificates validated this way have UsedAIA set.
+// Each download is bounded by DefaultAIATimeout, at most a few issuers are
+// fetched per chain, and downloaded issuers are reused across targets.
+func WithAIA(enabled bool) Option {
+	return func(s *Scanner) {
+		s.aia = nil
+		if enabled {
+			s.aia = newAIAFetcher()
+		}
+	}
+}
+
+// WithAIAMaxSize sets the largest issuer certificate, in bytes, that WithAIA
+// downloads. DefaultMaxAIASize is used when n is zero or less.
+func WithAIAMaxSize(n int64) Option {
+	return func(s *Scanner) {
+		s.aiaMaxSize = n
+	}
+}
+
+// WithResultHandler calls handle with the result of each target as soon as
+// it has been scraped by ScrapeTLS, rather than only when the whole scan has
+// finished, e.g. to stream results into a pipeline. details is nil if the
+// scrape failed. Calls are serialized, so handle needn't be safe for
+// concurrent use.
+func WithResultHandler(handle func(domain string, details *CertDetails, err error)) Option {
+	return func(s *Scanner) {
+		if handle == nil {
+			s.onResult = nil
+			return
+		}
+		s.onResult = &resultHandler{handle: handle}
+	}
+}
+
+// NewScanner returns a Scanner configured with the given options.
+func NewScanner(opts ...Option) *Scanner {
+	s := &Scanner{concurrency: 10, port: DefaultPort, timeout: DefaultTimeout, maxChain: DefaultMaxChainDepth}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.concurrency < 1 {
+		s.concurrency = 1
+	}
+	if s.aia != nil && s.aiaMaxSize > 0 {
+		s.aia.maxSize = s.aiaMaxSize
+	}
+	if s.breaker != nil && s.cooldown > 0 {
+		s.breaker.cooldown = s.cooldown
+	}
+	// Without a gauge only the expiry metric is lost, which is not worth
+	// failing the scanner for.
+	s.expiry, _ = expiryGauge(s.metricLabels)
+	return s
+}
+
+// dialTimeout returns the timeout to apply to the next dial to domain. A zero
+// value means no timeout.
+func (s *Scanner) dialTimeout(domain string) time.Duration {
+	if timeout, ok := s.domainDial[domain]; ok {
+		return timeout
+	}
+	if s.latency != nil {
+		return s.latency.timeout()
+	}
+	return s.timeout
+}
+
+// portFor returns the port to connect to for domain.
+func (s *Scanner) portFor(domain string) int {
+	if port, ok := s.domainPorts[domain]; ok {
+		return port
+	}
+	return s.port
+}
+
+// hostPort returns the host and port to connect to for website: those given
+// in it as host:port, or else the website and the port from portFor.
+func (s *Scanner) hostPort(website string) (string, int) {
+	if host, port, ok := splitTarget(website); ok {
+		return host, port
+	}
+	return website, s.portFor(website)
+}
+
+// sniFor returns the server name to request from domain.
+func (s *Scanner) sniFor(domain string) string {
+	if name, ok := s.domainSNI[domain]; ok {
+		return name
+	}
+	return domain
+}
+
+// startTLSFor returns the STARTTLS protocol to speak to domain.
+func (s *Scanner) startTLSFor(domain string) StartTLS {
+	if protocol, ok := s.domainStart[domain]; ok {
+		return protocol
+	}
+	return s.startTLS
+}
+
+// validationOptions returns the options used to validate the certificate
+// scraped from the domain.
+func (s *Scanner) validationOptions(domain string) ValidationOptions {
+	opts := ValidationOptions{
+		Roots:                 s.roots,
+		DistrustedRoots:       s.distrusted,
+		NotePartialRevocation: s.partialRevocationNotes,
+	}
+	if len(s.pins) > 0 || len(s.domainPins[domain]) > 0 {
+		opts.Pins = append(append([]string{}, s.pins...), s.domainPins[domain]...)
+	}
+	return opts
+}
+
+// dialer returns a Dialer for domain that sends serverName as SNI, waits for
+// the rate limit, and gives up when ctx is done. When ips is non-empty, or
+// the domain has a resolve override, the addresses are dialled in turn until
+// one connects, instead of resolving the domain. A non-zero minVersion
+// overrides the oldest TLS version offered.
+func (s *Scanner) dialer(ctx context.Context, domain, serverName string, ips []net.IP, minVersion uint16) Dialer {
+	config := &tls.Config{KeyLogWriter: s.keyLog}
+	if s.tlsConfig != nil {
+		s.tlsConfig(config)
+	}
+	if minVersion != 0 {
+		config.MinVersion = minVersion
+	}
+	// Verification is skipped during the handshake so that invalid
+	// certificates can still be reported; they are validated afterwards.
+	config.InsecureSkipVerify = true
+	config.ServerName = serverName
+
+	var dialer Dialer
+	if s.dtlsEnabled {
+		dtlsDialer := &dtlsDialer{
+			timeout: s.dialTimeout(domain),
+			config:  &dtls.Config{InsecureSkipVerify: true, ServerName: serverName},
+		}
+		dialer = &contextDialer{ctx: ctx, dial: dtlsDialer.DialContext}
+	} else if startTLS := s.startTLSFor(domain); s.timings || s.proxyHeader != nil || s.proxy != nil || startTLS != StartTLSNone {
+		stepped := &stepDialer{timeout: s.dialTimeout(domain), config: config, timings: s.timings}
+		if s.proxy != nil {
+			stepped.dial = dialContextOf(s.proxy)
+		}
+		if s.proxyHeader != nil || startTLS != StartTLSNone {
+			header := s.proxyHeader
+			stepped.preamble = func(conn net.Conn) error {
+				// The PROXY header must be the first thing on the
+				// connection, before any STARTTLS exchange.
+				if header != nil {
+					if err := header.write(conn); err != nil {
+						return err
+					}
+				}
+				return startTLS.negotiate(conn)
+			}
+		}
+		dialer = &contextDialer{ctx: ctx, dial: stepped.DialContext}
+	} else {
+		tlsDialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: s.dialTimeout(domain)},
+			Config:    config,
+		}
+		dialer = &contextDialer{ctx: ctx, dial: tlsDialer.DialContext}
+	}
+	// Each address tried counts against the rate limit, so the limiter is
+	// wrapped by the addressDialer.
+	if s.rateLimit != nil {
+		dialer = &rateLimitedDialer{Dialer: dialer, ctx: ctx, limiter: s.rateLimit}
+	}
+	if ip, ok := s.overrides[domain]; ok {
+		ips = []net.IP{ip}
+	}
+	if len(ips) > 0 {
+		hosts := make([]string, len(ips))
+		for i, ip := range ips {
+			hosts[i] = ip.String()
+		}
+		dialer = &addressDialer{Dialer: dialer, hosts: hosts}
+	}
+
+	return dialer
+}
+
+// fetch scrapes a single website using the scanner's configuration. See
+// dialer for how ips is used.
+func (s *Scanner) fetch(ctx context.Context, website string, ips []net.IP) (*CertDetails, error) {
+	domain, port := s.hostPort(website)
+	if err := s.portPolicy.Check(port); err != nil {
+		return nil, err
+	}
+
+	var network netip.Prefix
+	hasNetwork := false
+	if s.breaker != nil {
+		if network, hasNetwork = s.breaker.network(s.targetIP(domain, ips)); hasNetwork {
+			if err := s.breaker.allow(network); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	serverName := s.sniFor(domain)
+	dialer := s.dialer(ctx, domain, serverName, ips, 0)
+
+	start := time.Now()
+	certInfo := &CertDetails{}
+	err := certInfo.fetchFromDomainWithDialer(domain, port, dialer, s.maxChain)
+	legacy := false
+	if err != nil && s.tlsFallback && !s.dtlsEnabled && isVersionError(err) {
+		certInfo = &CertDetails{}
+		err = certInfo.fetchFromDomainWithDialer(domain, port, s.dialer(ctx, domain, serverName, ips, fallbackMinVersion), s.maxChain)
+		legacy = err == nil
+	}
+	if hasNetwork {
+		s.breaker.record(network, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A website given as host:port keeps that form, so that results for
+	// the same host on different ports can be told apart.
+	certInfo.Domain = website
+	if s.latency != nil {
+		s.latency.observe(time.Since(start))
+	}
+	opts := s.validationOptions(domain)
+	certInfo.validate(serverName, opts)
+	if s.aia != nil && hasValidationError(certInfo.ValidationErrors, CodeUnknownAuthority) {
+		if issuers := s.aia.completeChain(ctx, certInfo.GetLeafCert(), certInfo.CertChain, opts.Roots, time.Now()); issuers != nil {
+			certInfo.aiaIssuers = issuers
+			certInfo.UsedAIA = true
+			certInfo.validate(serverName, opts)
+		}
+	}
+
+	if certInfo.sentChainLength > len(certInfo.CertChain) {
+		certInfo.ValidationErrors = append(certInfo.ValidationErrors, chainTooLongError(certInfo.sentChainLength, len(certInfo.CertChain)))
+	}
+	if legacy {
+		certInfo.ValidationErrors = append(certInfo.ValidationErrors, legacyTLSError(certInfo.tlsVersion))
+	}
+
+	if s.consistency > 1 {
+		var minVersion uint16
+		if legacy {
+			minVersion = fallbackMinVersion
+		}
+		if e := s.checkConsistency(ctx, domain, port, serverName, ips, minVersion, identify(certInfo.GetLeafCert()), s.consistency); e != nil {
+			certInfo.Valid = false
+			certInfo.ValidationErrors = append(certInfo.ValidationErrors, *e)
+		}
+	}
+
+	if s.ocspEnabled {
+		s.checkOCSP(certInfo)
+	}
+	if s.crl != nil {
+		s.checkCRL(certInfo)
+	}
+
+	if s.strictSNI {
+		if ignored, err := s.probeSNI(ctx, domain, port, ips); err == nil && ignored {
+			certInfo.Valid = false
+			certInfo.ValidationErrors = append(certInfo.ValidationErrors, ValidationError{
+				Code:    CodeSNIIgnored,
+				Message: fmt.Sprintf("server returned a certificate for %s when asked for %s", domain, sniProbeName),
+			})
+		}
+	}
+	return certInfo, nil
+}
+
+// scrape returns the cached result for domain when there is one, and
+// otherwise fetches it and caches the result on success. cached reports
+// whether the result came from the cache, in which case no connection was
+// made and the scrape metrics shouldn't count it.
+func (s *Scanner) scrape(ctx context.Context, domain string, ips []net.IP) (certInfo *CertDetails, cached bool, err error) {
+	if s.cache == nil {
+		certInfo, err = s.fetch(ctx, domain, ips)
+		return certInfo, false, err
+	}
+
+	host, port := s.hostPort(domain)
+	key := cacheKey{host: host, port: port}
+	if certInfo, ok := s.cache.get(key); ok {
+		return certInfo, true, nil
+	}
+	certInfo, err = s.fetch(ctx, domain, ips)
+	if err != nil {
+		return nil, false, err
+	}
+	s.cache.put(key, certInfo)
+	return certInfo, false, nil
+}
+
+// ScrapeTLS scrapes the given websites for TLS certificate details
+// concurrently and returns the collected information. A website may give its
+// port as host:port, in which case CertDetails.Domain keeps that form, so
+// that the results for a host on different ports can be told apart.
+func (s *Scanner) ScrapeTLS(websites []string) ([]*CertDetails, error) {
+	return s.ScrapeTLSContext(context.Background(), websites)
+}
+
+// ScrapeTLSContext is like ScrapeTLS, but stops when ctx is done: dials in
+// flight are abandoned and websites not yet started are not dialled. Their
+// errors wrap the context's error, and the details scraped before then are
+// still returned.
+func (s *Scanner) ScrapeTLSContext(ctx context.Context, websites []string) ([]*CertDetails, error) {
+	var details []*CertDetails
+	multiError := &MultiError{Errors: make(map[string]error)}
+	s.ScrapeTLSBatches(ctx, websites, len(websites), func(batch []*CertDetails, errs map[string]error) {
+		details = append(details, batch...)
+		for domain, err := range errs {
+			multiError.Errors[domain] = err
+		}
+	})
+
+	if len(multiError.Errors) > 0 {
+		return details, multiError
+	}
+
+	return details, nil
+}
+
+// ScrapeDomain scrapes a single domain and returns its certificate details.
+// Unlike ScrapeTLS, a failure is returned as is rather than in a MultiError.
+func (s *Scanner) ScrapeDomain(domain string) (*CertDetails, error) {
+	return s.ScrapeDomainContext(context.Background(), domain)
+}
+
+// ScrapeDomainContext is like ScrapeDomain, but abandons the scrape when ctx
+// is done, returning an error that wraps the context's error.
+func (s *Scanner) ScrapeDomainContext(ctx context.Context, domain string) (*CertDetails, error) {
+	start := time.Now()
+	certInfo, cached, err := s.scrape(ctx, domain, nil)
+	if !cached {
+		observeScrape(domain, time.Since(start), certInfo, err, s.exemplars)
+	}
+	s.observeExpiry(domain, certInfo)
+	if err != nil {
+		return nil, err
+	}
+	return certInfo, nil
+}
+
+// ScrapeOne scrapes host on port with a Scanner made from opts, for callers
+// with a single target. A port of 0 uses the port from opts, or DefaultPort.
+// Like ScrapeDomain, a failure is returned as is rather than in a MultiError.
+func ScrapeOne(host string, port int, opts ...Option) (*CertDetails, error) {
+	if port != 0 {
+		opts = append(opts[:len(opts):len(opts)], WithPort(port))
+	}
+	return NewScanner(opts...).ScrapeDomain(host)
+}
+
+// ScrapeOneIP is ScrapeOne for an IP address. Without an SNI set for it with
+// WithDomainSNI, no server name is sent, so servers that pick a certificate
+// by SNI return their default one.
+func ScrapeOneIP(ip net.IP, port int, opts ...Option) (*CertDetails, error) {
+	if ip.To16() == nil {
+		return nil, fmt.Errorf("invalid IP address %v", ip)
+	}
+	return ScrapeOne(ip.String(), port, opts...)
+}
+
+// Refresh scrapes a single domain like ScrapeDomain, but ignores any cached
+// result and replaces it with the new one.
+func (s *Scanner) Refresh(domain string) (*CertDetails, error) {
+	return s.RefreshContext(context.Background(), domain)
+}
+
+// RefreshContext is like Refresh, but abandons the scrape when ctx is done.
+func (s *Scanner) RefreshContext(ctx context.Context, domain string) (*CertDetails, error) {
+	if s.cache != nil {
+		host, port := s.hostPort(domain)
+		s.cache.remove(cacheKey{host: host, port: port})
+	}
+	return s.ScrapeDomainContext(ctx, domain)
+}
@@ -0,0 +1,393 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTarget starts a TLS server with a self-signed certificate and
+// returns its host and port.
+func newTestTarget(t testing.TB) (string, int) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse port: %v", err)
+	}
+	return host, port
+}
+
+func TestWithTLSConfig(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	var keyLog bytes.Buffer
+	verified := false
+	scanner := NewScanner(WithPort(port), WithTLSConfig(func(config *tls.Config) {
+		config.KeyLogWriter = &keyLog
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			verified = true
+			return nil
+		}
+		// Overridden by the scanner, otherwise the self-signed
+		// certificate would fail the handshake.
+		config.InsecureSkipVerify = false
+		config.ServerName = "ignored.example.com"
+	}))
+
+	details, err := scanner.ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if details.Domain != host {
+		t.Errorf("expected domain %s, got %s", host, details.Domain)
+	}
+	if !verified {
+		t.Errorf("expected VerifyConnection to be called")
+	}
+	if keyLog.Len() == 0 {
+		t.Errorf("expected key log output")
+	}
+}
+
+func TestWithKeyLogWriter(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	var keyLog bytes.Buffer
+	websites, overrides := aliasTargets(host, 4)
+	scanner := NewScanner(WithPort(port), WithConcurrency(4), WithKeyLogWriter(&keyLog), WithResolveOverrides(overrides))
+	if _, err := scanner.ScrapeTLS(websites); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(keyLog.String()), "\n")
+	for _, line := range lines {
+		if len(strings.Fields(line)) != 3 {
+			t.Errorf("expected a well-formed key log line, got %q", line)
+		}
+	}
+	if len(lines) < 4 {
+		t.Errorf("expected key log lines for 4 handshakes, got %d lines", len(lines))
+	}
+}
+
+func TestWithDomainSNI(t *testing.T) {
+	var requested string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		requested = hello.ServerName
+		return nil, nil
+	}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	host := addr.IP.String()
+
+	tests := []struct {
+		sni              string
+		expectedMismatch bool
+	}{
+		// The httptest certificate is valid for example.com and 127.0.0.1.
+		{"example.com", false},
+		{"other.example.org", true},
+		// Without an override no SNI is sent for an IP address.
+		{"", false},
+	}
+	for _, tt := range tests {
+		var names map[string]string
+		if tt.sni != "" {
+			names = map[string]string{host: tt.sni}
+		}
+		scanner := NewScanner(WithPort(addr.Port), WithDomainSNI(names))
+		details, err := scanner.ScrapeDomain(host)
+		if err != nil {
+			t.Fatalf("ScrapeDomain() error = %v", err)
+		}
+		if requested != tt.sni {
+			t.Errorf("expected SNI %q, got %q", tt.sni, requested)
+		}
+		if details.ServerName != tt.sni {
+			t.Errorf("expected the SNI %q to be recorded, got %q", tt.sni, details.ServerName)
+		}
+		if details.Domain != host {
+			t.Errorf("expected the result to be for %s, got %s", host, details.Domain)
+		}
+		codes := strings.Join(validationCodes(details.ValidationErrors), ",")
+		if mismatch := strings.Contains(codes, CodeHostnameMismatch); mismatch != tt.expectedMismatch {
+			t.Errorf("SNI %q: expected hostname mismatch %v, got codes %s", tt.sni, tt.expectedMismatch, codes)
+		}
+	}
+}
+
+func TestWithDomainTimeouts(t *testing.T) {
+	scanner := NewScanner(
+		WithAdaptiveTimeout(time.Second, 30*time.Second),
+		WithDomainTimeouts(map[string]time.Duration{"slow.example.com": time.Minute}),
+	)
+	if got := scanner.dialTimeout("slow.example.com"); got != time.Minute {
+		t.Errorf("expected the per-domain timeout, got %v", got)
+	}
+	if got := scanner.dialTimeout("example.com"); got == time.Minute {
+		t.Errorf("expected the adaptive timeout for other domains, got %v", got)
+	}
+}
+
+func TestWithDomainPorts(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	// The default port has nothing listening on it, so the scrape only
+	// succeeds if the per-domain port is used.
+	scanner := NewScanner(WithPort(1), WithDomainPorts(map[string]int{host: port}))
+	if _, err := scanner.ScrapeDomain(host); err != nil {
+		t.Fatalf("ScrapeDomain() error = %v", err)
+	}
+
+	scanner = NewScanner(
+		WithDomainPorts(map[string]int{host: port}),
+		WithPortPolicy(PortPolicy{Denied: []int{port}}),
+	)
+	if _, err := scanner.ScrapeDomain(host); err == nil {
+		t.Error("expected the port policy to apply to per-domain ports")
+	}
+}
+
+func TestConnectionState(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	details, err := NewScanner(WithPort(port), WithDomainSNI(map[string]string{host: "example.com"})).ScrapeDomain(host)
+	if err != nil {
+		t.Fatalf("ScrapeDomain() error = %v", err)
+	}
+	state, ok := details.ConnectionState()
+	if !ok {
+		t.Fatal("expected the connection state to be captured")
+	}
+	if state.Version != tls.VersionTLS13 || state.ServerName != "example.com" || len(state.PeerCertificates) != len(details.CertChain) {
+		t.Errorf("unexpected connection state: version %x, server name %q, %d peer certificates", state.Version, state.ServerName, len(state.PeerCertificates))
+	}
+
+	if _, ok := (&CertDetails{}).ConnectionState(); ok {
+		t.Error("expected no connection state for details that were not scraped")
+	}
+}
+
+func TestScrapeTLSContext(t *testing.T) {
+	host, port := newTestTarget(t)
+	silentPort := newSilentTarget(t)
+	silent := []string{"127.0.0.2", "127.0.0.3", "127.0.0.4"}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		targets     []string
+		expected    int
+	}{
+		// Every target is dialled at once, so the served one completes
+		// before the scan is cancelled.
+		{"partial results", len(silent) + 1, append([]string{host}, silent...), 1},
+		// Only one target is dialled, and the rest must not start.
+		{"queued targets", 1, silent, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewScanner(
+				WithPort(silentPort),
+				WithDomainPorts(map[string]int{host: port}),
+				WithConcurrency(tt.concurrency),
+				WithTimeout(0),
+			)
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			details, err := scanner.ScrapeTLSContext(ctx, tt.targets)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected the scan to stop promptly, took %v", elapsed)
+			}
+			if len(details) != tt.expected {
+				t.Errorf("expected %d results, got %d", tt.expected, len(details))
+			}
+			var multiErr *MultiError
+			if !errors.As(err, &multiErr) || len(multiErr.Errors) != len(silent) {
+				t.Fatalf("expected an error for each silent target, got: %v", err)
+			}
+			for target, err := range multiErr.Errors {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("expected %s to fail with the context's error, got: %v", target, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithResultHandler(t *testing.T) {
+	host, port := newTestTarget(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	results := make(map[string]error)
+	var served *CertDetails
+	scanner := NewScanner(
+		WithDomainPorts(map[string]int{host: port, "127.0.0.2": closedPort}),
+		WithResultHandler(func(domain string, details *CertDetails, err error) {
+			results[domain] = err
+			if domain == host {
+				served = details
+			}
+		}),
+	)
+	details, _ := scanner.ScrapeTLS([]string{host, "127.0.0.2"})
+
+	if len(results) != 2 {
+		t.Fatalf("expected the handler to be called for each target, got %v", results)
+	}
+	if results[host] != nil || served == nil || len(details) != 1 || served != details[0] {
+		t.Errorf("expected the handler to get the served target's details, got %v, %v", served, results[host])
+	}
+	if results["127.0.0.2"] == nil {
+		t.Error("expected the handler to get the closed target's error")
+	}
+}
+
+func TestWithRoots(t *testing.T) {
+	ca, caKey := newTestCA(t, "Private CA")
+	leaf := newTestLeaf(t, ca, caKey, "internal.example.com")
+	dialer := &stateDialer{state: tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca}}}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	tests := []struct {
+		name     string
+		scanner  *Scanner
+		expected bool
+	}{
+		{"system roots", NewScanner(), false},
+		{"private roots", NewScanner(WithRoots(roots)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &CertDetails{}
+			if err := cd.fetchFromDomainWithDialer("internal.example.com", DefaultPort, dialer, 0); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			cd.validate("internal.example.com", tt.scanner.validationOptions("internal.example.com"))
+			if cd.Valid != tt.expected {
+				t.Errorf("expected valid to be %v, got %v: %v", tt.expected, cd.Valid, cd.ValidationErrors)
+			}
+		})
+	}
+}
+
+func TestScrapeOne(t *testing.T) {
+	host, port := newTestTarget(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	details, err := ScrapeOne(host, port, WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("ScrapeOne() error = %v", err)
+	}
+	if details.Domain != host || details.Port != port {
+		t.Errorf("expected details for %s:%d, got %s:%d", host, port, details.Domain, details.Port)
+	}
+
+	details, err = ScrapeOneIP(net.ParseIP(host), port, WithDomainSNI(map[string]string{host: "example.com"}))
+	if err != nil {
+		t.Fatalf("ScrapeOneIP() error = %v", err)
+	}
+	if details.ServerName != "example.com" {
+		t.Errorf("expected the SNI option to apply, got server name %q", details.ServerName)
+	}
+
+	_, err = ScrapeOne(host, closedPort)
+	var multiErr *MultiError
+	if err == nil || errors.As(err, &multiErr) {
+		t.Errorf("expected a plain error for a closed port, got: %v", err)
+	}
+	if !IsConnectionError(err) {
+		t.Errorf("expected a connection error, got: %v", err)
+	}
+
+	if _, err := ScrapeOneIP(nil, port); err == nil {
+		t.Error("expected an error for a nil IP")
+	}
+}
+
+func TestScrape(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	// Both names are in the test server's certificate.
+	websites := []string{"127.0.0.1", "example.com"}
+	overrides := map[string]net.IP{"example.com": net.ParseIP("127.0.0.1")}
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantValid bool
+	}{
+		{"system roots", []Option{WithPort(port), WithResolveOverrides(overrides)}, false},
+		{"custom roots", []Option{WithPort(port), WithResolveOverrides(overrides), WithRoots(roots)}, true},
+		{"concurrency and timeout", []Option{WithConcurrency(1), WithTimeout(5 * time.Second), WithPort(port), WithResolveOverrides(overrides), WithRoots(roots)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := Scrape(websites, tt.opts...)
+			if err != nil {
+				t.Fatalf("Scrape() error = %v", err)
+			}
+			if len(details) != len(websites) {
+				t.Fatalf("expected %d results, got %d", len(websites), len(details))
+			}
+			for _, d := range details {
+				if d.Port != port {
+					t.Errorf("%s: expected port %d, got %d", d.Domain, port, d.Port)
+				}
+				if d.Valid != tt.wantValid {
+					t.Errorf("%s: expected valid %v, got validation errors %v", d.Domain, tt.wantValid, d.ValidationErrors)
+				}
+			}
+		})
+	}
+
+	silentPort := newSilentTarget(t)
+	start := time.Now()
+	if _, err := Scrape([]string{"127.0.0.1"}, WithPort(silentPort), WithTimeout(100*time.Millisecond)); err == nil {
+		t.Error("expected an error from a silent target")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the timeout to apply, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	details, err := ScrapeContext(ctx, websites, WithPort(port), WithResolveOverrides(overrides))
+	if len(details) != 0 || err == nil {
+		t.Errorf("expected nothing scraped once cancelled, got %d results and error %v", len(details), err)
+	}
+}
@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+)
+
+// oidSCTList is the extension holding the Signed Certificate Timestamps
+// embedded in a certificate, from RFC 6962 section 3.3.
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// embeddedSCTs reports whether cert carries the embedded SCT list extension
+// and how many SCTs the list holds. The SCTs aren't verified against their
+// logs. A malformed list is reported as present with the SCTs that could be
+// read before the error.
+func embeddedSCTs(cert *x509.Certificate) (present bool, count int) {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			return true, countSCTs(ext.Value)
+		}
+	}
+	return false, 0
+}
+
+// countSCTs counts the SCTs in the DER encoded extension value, an OCTET
+// STRING wrapping a TLS encoded SignedCertificateTimestampList: a 16-bit
+// length followed by SCTs that are each prefixed with a 16-bit length.
+func countSCTs(value []byte) int {
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) != 0 {
+		return 0
+	}
+	if len(list) < 2 {
+		return 0
+	}
+	length := int(binary.BigEndian.Uint16(list))
+	list = list[2:]
+	if length > len(list) {
+		return 0
+	}
+	list = list[:length]
+
+	count := 0
+	for len(list) >= 2 {
+		length := int(binary.BigEndian.Uint16(list))
+		if length == 0 || 2+length > len(list) {
+			break
+		}
+		list = list[2+length:]
+		count++
+	}
+	return count
+}
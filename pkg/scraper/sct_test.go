@@ -0,0 +1,63 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+// sctListExtension returns the embedded SCT list extension holding scts,
+// which needn't be real SCTs as they are only counted.
+func sctListExtension(t *testing.T, scts ...[]byte) pkix.Extension {
+	t.Helper()
+	var list []byte
+	for _, sct := range scts {
+		list = append(list, byte(len(sct)>>8), byte(len(sct)))
+		list = append(list, sct...)
+	}
+	list = append([]byte{byte(len(list) >> 8), byte(len(list))}, list...)
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		t.Fatalf("failed to marshal SCT list: %v", err)
+	}
+	return pkix.Extension{Id: oidSCTList, Value: value}
+}
+
+func TestEmbeddedSCTs(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	issue := func(extensions ...pkix.Extension) *x509.Certificate {
+		cert, _ := issueTestCert(t, &x509.Certificate{
+			Subject:         pkix.Name{CommonName: "example.com"},
+			DNSNames:        []string{"example.com"},
+			ExtraExtensions: extensions,
+		}, ca, caKey)
+		return cert
+	}
+
+	tests := []struct {
+		name          string
+		cert          *x509.Certificate
+		expectedSCT   bool
+		expectedCount int
+	}{
+		{"without SCTs", newTestLeaf(t, ca, caKey, "example.com"), false, 0},
+		{"with SCTs", issue(sctListExtension(t, []byte("first sct"), []byte("second sct"))), true, 2},
+		{"empty list", issue(sctListExtension(t)), true, 0},
+		{"malformed list", issue(pkix.Extension{Id: oidSCTList, Value: []byte{0x04, 0x01, 0xff}}), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := generateMockConnectionState()
+			state.PeerCertificates = []*x509.Certificate{tt.cert}
+
+			cd := &CertDetails{}
+			if err := cd.fetchFromDomainWithDialer("example.com", DefaultPort, &stateDialer{state: state}, 0); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if cd.HasSCT != tt.expectedSCT || cd.SCTCount != tt.expectedCount {
+				t.Errorf("expected SCT %v count %d, got %v count %d", tt.expectedSCT, tt.expectedCount, cd.HasSCT, cd.SCTCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// CodeSNIIgnored is reported in strict SNI mode when the server returns a
+// certificate for the domain even though a different name was requested.
+const CodeSNIIgnored = "SNI_IGNORED"
+
+// sniProbeName is sent as SNI by the strict SNI probe. The .invalid TLD is
+// reserved, so no server should have a certificate for it.
+const sniProbeName = "tls-scrape-sni-probe.invalid"
+
+// probeSNI handshakes with domain on port using sniProbeName as SNI and reports
+// whether the certificate returned is still valid for domain. A server that
+// rejects the probe handshake is honouring SNI.
+func (s *Scanner) probeSNI(ctx context.Context, domain string, port int, ips []net.IP) (bool, error) {
+	conn, err := s.dialer(ctx, domain, sniProbeName, ips, 0).Dial("tcp", net.JoinHostPort(domain, strconv.Itoa(port)))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	tlsConn, ok := conn.(interface{ ConnectionState() tls.ConnectionState })
+	if !ok {
+		return false, fmt.Errorf("expected a ConnectionStateGetter, got %T", conn)
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return false, nil
+	}
+	return certs[0].VerifyHostname(s.sniFor(domain)) == nil, nil
+}
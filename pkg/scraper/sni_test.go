@@ -0,0 +1,75 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// newSNITarget starts a TLS listener serving a certificate for name. When
+// strict is set, handshakes with any other SNI are rejected.
+func newSNITarget(t *testing.T, name string, strict bool) int {
+	t.Helper()
+	leaf, key := issueTestCert(t, &x509.Certificate{DNSNames: []string{name}}, nil, nil)
+	cert := &tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if strict && hello.ServerName != name {
+				return nil, errors.New("unknown server name")
+			}
+			return cert, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestStrictSNI(t *testing.T) {
+	const name = "sni.example.com"
+	overrides := map[string]net.IP{name: net.ParseIP("127.0.0.1")}
+
+	tests := []struct {
+		name          string
+		strict        bool
+		expectIgnored bool
+	}{
+		{"honours SNI", true, false},
+		{"ignores SNI", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := newSNITarget(t, name, tt.strict)
+			scanner := NewScanner(WithPort(port), WithResolveOverrides(overrides), WithStrictSNI(true))
+
+			details, err := scanner.ScrapeDomain(name)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			codes := strings.Join(validationCodes(details.ValidationErrors), ",")
+			if ignored := strings.Contains(codes, CodeSNIIgnored); ignored != tt.expectIgnored {
+				t.Errorf("expected SNI ignored to be %t, got validation errors %s", tt.expectIgnored, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package scraper
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// StartTLS is a plaintext protocol that is spoken on a connection before it
+// is upgraded to TLS, for services such as mail servers that don't start TLS
+// straight away.
+type StartTLS int
+
+// The supported STARTTLS protocols.
+const (
+	StartTLSNone StartTLS = iota
+	StartTLSSMTP
+	StartTLSIMAP
+	StartTLSPOP3
+)
+
+// startTLSNames are the names used for the protocols in flags and target
+// files.
+var startTLSNames = map[StartTLS]string{
+	StartTLSNone: "none",
+	StartTLSSMTP: "smtp",
+	StartTLSIMAP: "imap",
+	StartTLSPOP3: "pop3",
+}
+
+// String returns the name of the protocol.
+func (p StartTLS) String() string {
+	if name, ok := startTLSNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("StartTLS(%d)", int(p))
+}
+
+// ParseStartTLS returns the protocol with the given name, ignoring case. An
+// empty name is StartTLSNone.
+func ParseStartTLS(name string) (StartTLS, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return StartTLSNone, nil
+	}
+	for protocol, protocolName := range startTLSNames {
+		if name == protocolName {
+			return protocol, nil
+		}
+	}
+	return StartTLSNone, fmt.Errorf("unknown STARTTLS protocol %q, expected smtp, imap or pop3", name)
+}
+
+// negotiate speaks the protocol on conn up to the point where the server
+// expects the TLS handshake to start.
+func (p StartTLS) negotiate(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	switch p {
+	case StartTLSNone:
+		return nil
+	case StartTLSSMTP:
+		return startSMTP(conn, r)
+	case StartTLSIMAP:
+		return startIMAP(conn, r)
+	case StartTLSPOP3:
+		return startPOP3(conn, r)
+	}
+	return fmt.Errorf("unknown STARTTLS protocol %d", int(p))
+}
+
+// startSMTP upgrades an SMTP connection (RFC 3207).
+func startSMTP(conn net.Conn, r *bufio.Reader) error {
+	if _, err := readSMTPReply(r, "220"); err != nil {
+		return fmt.Errorf("SMTP greeting: %w", err)
+	}
+	if _, err := fmt.Fprint(conn, "EHLO tls-scrape\r\n"); err != nil {
+		return err
+	}
+	extensions, err := readSMTPReply(r, "250")
+	if err != nil {
+		return fmt.Errorf("SMTP EHLO: %w", err)
+	}
+	supported := false
+	for _, extension := range extensions {
+		supported = supported || strings.EqualFold(strings.TrimSpace(extension), "STARTTLS")
+	}
+	if !supported {
+		return fmt.Errorf("SMTP server does not offer STARTTLS")
+	}
+	if _, err := fmt.Fprint(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	if _, err := readSMTPReply(r, "220"); err != nil {
+		return fmt.Errorf("SMTP STARTTLS: %w", err)
+	}
+	return nil
+}
+
+// readSMTPReply reads a possibly multi-line SMTP reply and returns the text
+// of each line, or an error if the reply code is not code.
+func readSMTPReply(r *bufio.Reader, code string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 3 || line[:3] != code {
+			return nil, fmt.Errorf("unexpected reply %q", line)
+		}
+		lines = append(lines, strings.TrimPrefix(line[3:], "-"))
+		// The last line of a reply has a space, or nothing, after the code.
+		if len(line) == 3 || line[3] != '-' {
+			return lines, nil
+		}
+	}
+}
+
+// startIMAP upgrades an IMAP connection (RFC 2595).
+func startIMAP(conn net.Conn, r *bufio.Reader) error {
+	greeting, err := readLine(r)
+	if err != nil {
+		return fmt.Errorf("IMAP greeting: %w", err)
+	}
+	if !strings.HasPrefix(strings.ToUpper(greeting), "* OK") {
+		return fmt.Errorf("IMAP greeting: unexpected reply %q", greeting)
+	}
+	if _, err := fmt.Fprint(conn, "a001 STARTTLS\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return fmt.Errorf("IMAP STARTTLS: %w", err)
+		}
+		// Skip untagged responses until the tagged one.
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToUpper(line), "A001 OK") {
+			return fmt.Errorf("IMAP STARTTLS: unexpected reply %q", line)
+		}
+		return nil
+	}
+}
+
+// startPOP3 upgrades a POP3 connection (RFC 2595).
+func startPOP3(conn net.Conn, r *bufio.Reader) error {
+	greeting, err := readLine(r)
+	if err != nil {
+		return fmt.Errorf("POP3 greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("POP3 greeting: unexpected reply %q", greeting)
+	}
+	if _, err := fmt.Fprint(conn, "STLS\r\n"); err != nil {
+		return err
+	}
+	reply, err := readLine(r)
+	if err != nil {
+		return fmt.Errorf("POP3 STLS: %w", err)
+	}
+	if !strings.HasPrefix(reply, "+OK") {
+		return fmt.Errorf("POP3 STLS: unexpected reply %q", reply)
+	}
+	return nil
+}
+
+// maxLineLength bounds the lines read from a server during STARTTLS
+// negotiation, so a misbehaving server can't make the scraper buffer an
+// endless line.
+const maxLineLength = 4096
+
+// readLine reads a line, without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLength {
+			return "", fmt.Errorf("line longer than %d bytes", maxLineLength)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package scraper
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseStartTLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected StartTLS
+		wantErr  bool
+	}{
+		{"", StartTLSNone, false},
+		{"none", StartTLSNone, false},
+		{"smtp", StartTLSSMTP, false},
+		{" IMAP ", StartTLSIMAP, false},
+		{"Pop3", StartTLSPOP3, false},
+		{"ldap", StartTLSNone, true},
+	}
+	for _, tt := range tests {
+		protocol, err := ParseStartTLS(tt.name)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("%q: expected an error: %v, got: %v", tt.name, tt.wantErr, err)
+		}
+		if protocol != tt.expected {
+			t.Errorf("%q: expected %v, got %v", tt.name, tt.expected, protocol)
+		}
+	}
+}
+
+// serveScript plays the server side of a plaintext exchange on conn. Lines
+// starting with "C: " are expected from the client, and all others are sent.
+// It returns the first line that didn't match, if any.
+func serveScript(conn net.Conn, script []string) <-chan string {
+	mismatch := make(chan string, 1)
+	go func() {
+		defer close(mismatch)
+		r := bufio.NewReader(conn)
+		for _, line := range script {
+			if strings.HasPrefix(line, "C: ") {
+				got, err := readLine(r)
+				if err != nil || got != strings.TrimPrefix(line, "C: ") {
+					mismatch <- got
+					return
+				}
+				continue
+			}
+			if _, err := conn.Write([]byte(line + "\r\n")); err != nil {
+				return
+			}
+		}
+	}()
+	return mismatch
+}
+
+func TestStartTLSNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol StartTLS
+		script   []string
+		wantErr  bool
+	}{
+		{
+			name:     "smtp",
+			protocol: StartTLSSMTP,
+			script: []string{
+				"220-mail.example.com ESMTP", "220 ready",
+				"C: EHLO tls-scrape",
+				"250-mail.example.com", "250-PIPELINING", "250 STARTTLS",
+				"C: STARTTLS",
+				"220 go ahead",
+			},
+		},
+		{
+			name:     "smtp without starttls",
+			protocol: StartTLSSMTP,
+			script: []string{
+				"220 mail.example.com ESMTP",
+				"C: EHLO tls-scrape",
+				"250-mail.example.com", "250 PIPELINING",
+			},
+			wantErr: true,
+		},
+		{
+			name:     "smtp refused",
+			protocol: StartTLSSMTP,
+			script:   []string{"554 no service"},
+			wantErr:  true,
+		},
+		{
+			name:     "imap",
+			protocol: StartTLSIMAP,
+			script: []string{
+				"* OK [CAPABILITY IMAP4rev1 STARTTLS] ready",
+				"C: a001 STARTTLS",
+				"* NOTE untagged",
+				"a001 OK begin TLS negotiation now",
+			},
+		},
+		{
+			name:     "imap refused",
+			protocol: StartTLSIMAP,
+			script: []string{
+				"* OK ready",
+				"C: a001 STARTTLS",
+				"a001 BAD unknown command",
+			},
+			wantErr: true,
+		},
+		{
+			name:     "pop3",
+			protocol: StartTLSPOP3,
+			script:   []string{"+OK POP3 ready", "C: STLS", "+OK begin TLS negotiation"},
+		},
+		{
+			name:     "pop3 refused",
+			protocol: StartTLSPOP3,
+			script:   []string{"+OK POP3 ready", "C: STLS", "-ERR command not permitted"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			mismatch := serveScript(server, tt.script)
+
+			err := tt.protocol.negotiate(client)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected an error: %v, got: %v", tt.wantErr, err)
+			}
+			client.Close()
+			if line, ok := <-mismatch; ok {
+				t.Errorf("unexpected line from the client: %q", line)
+			}
+			server.Close()
+		})
+	}
+}
+
+func TestScrapeStartTLSSMTP(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	leaf, leafKey := issueTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "mail.example.com"},
+		DNSNames:    []string{"mail.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw, ca.Raw}, PrivateKey: leafKey}}}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				mismatch := serveScript(conn, []string{
+					"220 mail.example.com ESMTP",
+					"C: EHLO tls-scrape",
+					"250-mail.example.com", "250 STARTTLS",
+					"C: STARTTLS",
+					"220 go ahead",
+				})
+				if _, ok := <-mismatch; ok {
+					return
+				}
+				_ = tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+
+	scanner := NewScanner(WithPort(ln.Addr().(*net.TCPAddr).Port), WithStartTLS(StartTLSSMTP))
+	details, err := scanner.ScrapeTLS([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(details) != 1 || details[0].Serial != leaf.SerialNumber.String() {
+		t.Fatalf("expected the certificate sent after STARTTLS, got %v", details)
+	}
+}
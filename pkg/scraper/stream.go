@@ -0,0 +1,21 @@
+package scraper
+
+import "sync"
+
+// resultHandler serializes calls to the callback set with WithResultHandler,
+// so that concurrent scrapes don't call it at the same time.
+type resultHandler struct {
+	mu     sync.Mutex
+	handle func(domain string, details *CertDetails, err error)
+}
+
+// call passes a result to the callback. It does nothing on a nil handler, so
+// callers don't need to check whether one was set.
+func (h *resultHandler) call(domain string, details *CertDetails, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handle(domain, details, err)
+}
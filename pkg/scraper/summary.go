@@ -0,0 +1,46 @@
+package scraper
+
+import "time"
+
+// ScanSummary aggregates the outcome of a scan.
+type ScanSummary struct {
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Valid     int           `json:"valid"`
+	Invalid   int           `json:"invalid"`
+	Elapsed   time.Duration `json:"elapsed"`
+
+	// EarliestExpiry is the soonest leaf certificate expiry seen in the scan,
+	// and EarliestExpiryDomain the domain that served it.
+	EarliestExpiry       time.Time `json:"earliest_expiry,omitempty"`
+	EarliestExpiryDomain string    `json:"earliest_expiry_domain,omitempty"`
+}
+
+// Summarize builds a ScanSummary from the results and errors of a scan.
+func Summarize(details []*CertDetails, errs map[string]error, elapsed time.Duration) ScanSummary {
+	summary := ScanSummary{
+		Total:     len(details) + len(errs),
+		Succeeded: len(details),
+		Failed:    len(errs),
+		Elapsed:   elapsed,
+	}
+
+	for _, detail := range details {
+		if detail.Valid {
+			summary.Valid++
+		} else {
+			summary.Invalid++
+		}
+
+		if len(detail.CertChain) == 0 {
+			continue
+		}
+		notAfter := detail.GetLeafCert().NotAfter
+		if summary.EarliestExpiry.IsZero() || notAfter.Before(summary.EarliestExpiry) {
+			summary.EarliestExpiry = notAfter
+			summary.EarliestExpiryDomain = detail.Domain
+		}
+	}
+	return summary
+}
@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// splitTarget splits a website given as host:port, such as example.com:8443
+// or [2001:db8::1]:8443, into its host and port. ok is false for a website
+// without a port, including a bare IPv6 address.
+func splitTarget(website string) (host string, port int, ok bool) {
+	host, portString, err := net.SplitHostPort(website)
+	if err != nil || host == "" {
+		return "", 0, false
+	}
+	port, err = strconv.Atoi(portString)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, false
+	}
+	return host, port, true
+}
+
+// UniqueTargets returns websites with surrounding whitespace trimmed and
+// repeats removed, keeping the first spelling of each. Host names are
+// compared case-insensitively, and IP addresses by value, so that an IPv4
+// address and its IPv4-mapped IPv6 form, such as 192.0.2.1 and
+// ::ffff:192.0.2.1, count as the same target. A website given as host:port
+// is a different target for each port, and is never a repeat of the bare
+// host, whose port depends on the Scanner.
+func UniqueTargets(websites []string) []string {
+	seen := make(map[string]bool, len(websites))
+	unique := make([]string, 0, len(websites))
+	for _, website := range websites {
+		website = strings.TrimSpace(website)
+		key := targetKey(website)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, website)
+	}
+	return unique
+}
+
+// targetKey returns the form of a trimmed website that repeats of it share.
+func targetKey(website string) string {
+	if host, port, ok := splitTarget(website); ok {
+		return net.JoinHostPort(hostKey(host), strconv.Itoa(port))
+	}
+	return hostKey(website)
+}
+
+// hostKey returns the form of a host that repeats of it share.
+func hostKey(host string) string {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return strings.ToLower(host)
+}
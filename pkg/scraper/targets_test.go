@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// aliasTargets returns n distinct names and the resolve overrides that point
+// them all at host, so that one test target can be scraped n times.
+func aliasTargets(host string, n int) ([]string, map[string]net.IP) {
+	names := make([]string, n)
+	overrides := make(map[string]net.IP, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("alias%d.example.com", i)
+		overrides[names[i]] = net.ParseIP(host)
+	}
+	return names, overrides
+}
+
+func TestUniqueTargets(t *testing.T) {
+	websites := []string{
+		"Example.com", " example.com", "EXAMPLE.COM\t", "www.example.com",
+		"192.0.2.1", "::ffff:192.0.2.1", " 192.0.2.1 ",
+		"2001:db8::1", "2001:DB8:0::1",
+		"example.com:443", "EXAMPLE.com:443", "example.com:8443", "[2001:db8::1]:443",
+	}
+	expected := []string{"Example.com", "www.example.com", "192.0.2.1", "2001:db8::1", "example.com:443", "example.com:8443", "[2001:db8::1]:443"}
+	if got := UniqueTargets(websites); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestScrapeTLSDedupsTargets(t *testing.T) {
+	host, port := newTestTarget(t)
+
+	var mu sync.Mutex
+	scraped := make(map[string]int)
+	scanner := NewScanner(
+		WithPort(port),
+		WithResolveOverrides(map[string]net.IP{"Local.example.com": net.ParseIP(host)}),
+		WithResultHandler(func(domain string, details *CertDetails, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			scraped[domain]++
+		}),
+	)
+	details, err := scanner.ScrapeTLS([]string{
+		host, "::ffff:" + host, " " + host,
+		"Local.example.com", "local.example.com ", "LOCAL.EXAMPLE.COM",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(details) != 2 {
+		t.Errorf("expected 2 results, got %d", len(details))
+	}
+	if expected := map[string]int{host: 1, "Local.example.com": 1}; !reflect.DeepEqual(scraped, expected) {
+		t.Errorf("expected each target to be scraped once, got %v", scraped)
+	}
+}
+
+func TestScrapeTLSHostOnSeveralPorts(t *testing.T) {
+	host, port := newTestTarget(t)
+	_, otherPort := newTestTarget(t)
+
+	first := net.JoinHostPort(host, strconv.Itoa(port))
+	second := net.JoinHostPort(host, strconv.Itoa(otherPort))
+	details, err := NewScanner(WithPort(1)).ScrapeTLS([]string{first, second, first})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(details))
+	}
+	got := map[string]int{details[0].Domain: details[0].Port, details[1].Domain: details[1].Port}
+	if expected := map[string]int{first: port, second: otherPort}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected results %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,82 @@
+package scraper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// issueTestCert signs template with parentKey, or self-signs it when parent
+// is nil, and returns the parsed certificate with its private key. Serial
+// number and validity period are filled in when not set on the template.
+func issueTestCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	if template.SerialNumber == nil {
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		if err != nil {
+			t.Fatalf("failed to generate serial: %v", err)
+		}
+		template.SerialNumber = serial
+	}
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+// newTestCA returns a self-signed CA certificate and its key.
+func newTestCA(t *testing.T, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	return issueTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+}
+
+// newTestLeaf returns a server certificate for the given DNS names signed by
+// the issuer, with OCSP and CRL endpoints.
+func newTestLeaf(t *testing.T, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, dnsNames ...string) *x509.Certificate {
+	t.Helper()
+	var commonName string
+	if len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+	cert, _ := issueTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:            []string{"http://ocsp.example.com"},
+		CRLDistributionPoints: []string{"http://crl.example.com/ca.crl"},
+	}, issuer, issuerKey)
+	return cert
+}
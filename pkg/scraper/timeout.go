@@ -0,0 +1,18 @@
+package scraper
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultTimeout is the dial and handshake timeout used when none is
+// configured.
+const DefaultTimeout = 10 * time.Second
+
+// IsTimeout reports whether err is a dial or handshake timeout, as opposed to
+// e.g. a refused connection or a failed handshake.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return err != nil && errors.As(err, &netErr) && netErr.Timeout()
+}
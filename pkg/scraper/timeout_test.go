@@ -0,0 +1,102 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newSilentTarget starts a listener that accepts connections but never
+// answers the TLS handshake, and returns its port.
+func newSilentTarget(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"wrapped deadline exceeded", fmt.Errorf("dial: %w", context.DeadlineExceeded), true},
+		{"connection refused", syscall.ECONNREFUSED, false},
+		{"other", errors.New("handshake failure"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTimeout(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	if timeout := NewScanner().dialTimeout("example.com"); timeout != DefaultTimeout {
+		t.Errorf("expected the default timeout to be %v, got %v", DefaultTimeout, timeout)
+	}
+
+	port := newSilentTarget(t)
+	for _, timings := range []bool{false, true} {
+		t.Run(fmt.Sprintf("timings=%v", timings), func(t *testing.T) {
+			scanner := NewScanner(WithPort(port), WithTimings(timings), WithTimeout(100*time.Millisecond))
+			start := time.Now()
+			_, err := scanner.ScrapeTLS([]string{"127.0.0.1"})
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("expected the scrape to give up promptly, took %v", elapsed)
+			}
+			var multiErr *MultiError
+			if !errors.As(err, &multiErr) || !IsTimeout(multiErr.Errors["127.0.0.1"]) {
+				t.Fatalf("expected a timeout, got: %v", err)
+			}
+			err = multiErr.Errors["127.0.0.1"]
+			var scrapeErr *ScrapeError
+			if !errors.As(err, &scrapeErr) || scrapeErr.Host != "127.0.0.1" || scrapeErr.Phase != PhaseHandshake {
+				t.Errorf("expected a handshake ScrapeError for 127.0.0.1, got: %#v", err)
+			}
+			if msg := err.Error(); !strings.Contains(msg, "127.0.0.1") || !strings.Contains(msg, PhaseHandshake) {
+				t.Errorf("expected the message to name the host and phase, got %q", msg)
+			}
+			if !IsConnectionError(err) {
+				t.Errorf("expected a handshake timeout to be a connection error")
+			}
+		})
+	}
+}
+
+func TestScrapeDomainContext(t *testing.T) {
+	port := newSilentTarget(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewScanner(WithPort(port), WithTimeout(time.Minute)).ScrapeDomainContext(ctx, "127.0.0.1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the scrape to stop with its context, took %v", elapsed)
+	}
+}
@@ -0,0 +1,11 @@
+package scraper
+
+import "time"
+
+// Timings breaks down the time spent establishing a TLS connection to a
+// target into DNS resolution, TCP connect and TLS handshake.
+type Timings struct {
+	DNS       time.Duration `json:"dns"`
+	Connect   time.Duration `json:"connect"`
+	Handshake time.Duration `json:"handshake"`
+}
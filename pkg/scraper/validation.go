@@ -0,0 +1,256 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validation codes reported in ValidationError.Code.
+const (
+	CodeExpired          = "EXPIRED"
+	CodeNotYetValid      = "NOT_YET_VALID"
+	CodeHostnameMismatch = "HOSTNAME_MISMATCH"
+	CodeUnknownAuthority = "UNKNOWN_AUTHORITY"
+	CodeInvalidChain     = "INVALID_CHAIN"
+	CodeRedundantSAN     = "REDUNDANT_SAN"
+)
+
+// ValidationError describes a single problem found while validating a
+// certificate. Warnings are non-fatal notes that do not make the certificate
+// invalid.
+type ValidationError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+// Error returns the code and message of the validation error.
+func (ve ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ve.Code, ve.Message)
+}
+
+// ValidationOptions controls how ValidateCertificate validates a certificate.
+type ValidationOptions struct {
+	// Roots is the set of trusted root certificates. The system pool is used
+	// when nil.
+	Roots *x509.CertPool
+
+	// CurrentTime is the time to validate against. The current time is used
+	// when zero.
+	CurrentTime time.Time
+
+	// SkipHostname disables checking the certificate against the DNS name.
+	SkipHostname bool
+
+	// Pins, when non-empty, lists the "sha256:HEX" fingerprints the leaf is
+	// expected to match. A leaf matching none of them is reported as
+	// PIN_MISMATCH.
+	Pins []string
+
+	// DistrustedRoots lists the "sha256:HEX" fingerprints of roots that are
+	// no longer trusted. A certificate whose verified chains all end in one
+	// of them is reported as DISTRUSTED_ROOT.
+	DistrustedRoots []string
+
+	// NotePartialRevocation reports certificates that offer only one of OCSP
+	// and CRLs for revocation checking, as OCSP_ONLY or CRL_ONLY warnings.
+	NotePartialRevocation bool
+}
+
+// ValidateCertificate validates leaf against dnsName and the trusted roots,
+// using the certificates in chain as intermediates. The chain may include the
+// leaf itself, as returned by a server. It reports whether the certificate is
+// valid together with every problem found.
+func ValidateCertificate(leaf *x509.Certificate, chain []*x509.Certificate, dnsName string, opts ValidationOptions) (bool, []ValidationError) {
+	var errs []ValidationError
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	timeInvalid := false
+	if now.After(leaf.NotAfter) {
+		timeInvalid = true
+		errs = append(errs, ValidationError{
+			Code:    CodeExpired,
+			Message: fmt.Sprintf("certificate expired at %s", leaf.NotAfter),
+		})
+	} else if now.Before(leaf.NotBefore) {
+		timeInvalid = true
+		errs = append(errs, ValidationError{
+			Code:    CodeNotYetValid,
+			Message: fmt.Sprintf("certificate is not valid before %s", leaf.NotBefore),
+		})
+	}
+
+	if !opts.SkipHostname && dnsName != "" {
+		if err := leaf.VerifyHostname(dnsName); err != nil {
+			errs = append(errs, ValidationError{Code: CodeHostnameMismatch, Message: err.Error()})
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain {
+		if cert != leaf {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		var unknownAuthority x509.UnknownAuthorityError
+		var invalid x509.CertificateInvalidError
+		switch {
+		case errors.As(err, &unknownAuthority):
+			errs = append(errs, ValidationError{Code: CodeUnknownAuthority, Message: err.Error()})
+		case errors.As(err, &invalid) && invalid.Reason == x509.Expired && timeInvalid:
+			// Already reported from the leaf validity period.
+		default:
+			errs = append(errs, ValidationError{Code: CodeInvalidChain, Message: err.Error()})
+		}
+	}
+
+	if chainErr := checkChainExpiry(leaf, chain); chainErr != nil {
+		errs = append(errs, *chainErr)
+	}
+
+	if distrustErr := checkDistrustedRoots(chains, opts.DistrustedRoots); distrustErr != nil {
+		errs = append(errs, *distrustErr)
+	}
+
+	if pinErr := checkPins(leaf, opts.Pins); pinErr != nil {
+		errs = append(errs, *pinErr)
+	}
+
+	if precertErr := checkPrecertificate(leaf); precertErr != nil {
+		errs = append(errs, *precertErr)
+	}
+
+	if revocationErr := checkRevocationInfo(leaf, opts.NotePartialRevocation); revocationErr != nil {
+		errs = append(errs, *revocationErr)
+	}
+
+	if cnErr := checkCNInSAN(leaf); cnErr != nil {
+		errs = append(errs, *cnErr)
+	}
+
+	errs = append(errs, checkRedundantSANs(leaf.DNSNames)...)
+
+	valid := true
+	for _, e := range errs {
+		if !e.Warning {
+			valid = false
+			break
+		}
+	}
+	return valid, errs
+}
+
+// matchesHostname reports whether the certificate name pattern covers host.
+// A leading "*." wildcard matches exactly one label.
+func matchesHostname(pattern, host string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if pattern == host {
+		return true
+	}
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	label, rest, found := strings.Cut(host, ".")
+	return found && label != "" && rest == pattern[2:]
+}
+
+// checkRedundantSANs reports SANs that are listed more than once, or that are
+// already covered by a wildcard SAN in the same certificate.
+func checkRedundantSANs(sans []string) []ValidationError {
+	var errs []ValidationError
+	seen := make(map[string]bool, len(sans))
+	for _, san := range sans {
+		name := strings.ToLower(san)
+		if seen[name] {
+			errs = append(errs, ValidationError{
+				Code:    CodeRedundantSAN,
+				Message: fmt.Sprintf("SAN %s is listed more than once", san),
+				Warning: true,
+			})
+			continue
+		}
+		seen[name] = true
+
+		if strings.HasPrefix(name, "*.") {
+			continue
+		}
+		for _, other := range sans {
+			if strings.HasPrefix(other, "*.") && matchesHostname(other, name) {
+				errs = append(errs, ValidationError{
+					Code:    CodeRedundantSAN,
+					Message: fmt.Sprintf("SAN %s is already covered by %s", san, other),
+					Warning: true,
+				})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// validate runs ValidateCertificate over the scraped chain, together with any
+// issuers fetched from AIA URLs, and records the outcome on the certificate
+// details.
+func (cd *CertDetails) validate(dnsName string, opts ValidationOptions) {
+	if len(cd.CertChain) == 0 {
+		return
+	}
+	chain := cd.CertChain
+	if len(cd.aiaIssuers) > 0 {
+		chain = append(append([]*x509.Certificate{}, cd.CertChain...), cd.aiaIssuers...)
+	}
+	cd.Valid, cd.ValidationErrors = ValidateCertificate(cd.GetLeafCert(), chain, dnsName, opts)
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	cd.ChainComplete = chainComplete(cd.GetLeafCert(), cd.CertChain, opts.Roots, now)
+	cd.LifetimeRemainingPct = lifetimeRemainingPct(cd.GetLeafCert(), now)
+	cd.DaysUntilExpiry = daysUntil(cd.GetLeafCert().NotAfter, now)
+	cd.Expired = now.After(cd.GetLeafCert().NotAfter)
+	cd.EffectiveExpiry = chainExpiry(cd.GetLeafCert(), cd.CertChain)
+	cd.DaysUntilChainExpiry = daysUntil(cd.EffectiveExpiry, now)
+}
+
+// hasValidationError reports whether errs include one with the given code.
+func hasValidationError(errs []ValidationError, code string) bool {
+	for _, e := range errs {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// lifetimeRemainingPct returns the percentage of the certificate's validity
+// period remaining at now, clamped to [0,100].
+func lifetimeRemainingPct(cert *x509.Certificate, now time.Time) float64 {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	if lifetime <= 0 {
+		return 0
+	}
+	pct := float64(cert.NotAfter.Sub(now)) / float64(lifetime) * 100
+	if pct < 0 {
+		return 0
+	}
+	if pct > 100 {
+		return 100
+	}
+	return pct
+}
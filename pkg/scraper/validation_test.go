@@ -0,0 +1,216 @@
+package scraper
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+)
+
+func validationCodes(errs []ValidationError) []string {
+	var codes []string
+	for _, e := range errs {
+		codes = append(codes, e.Code)
+	}
+	return codes
+}
+
+func TestValidateCertificate(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	leaf := newTestLeaf(t, ca, caKey, "example.com", "www.example.com")
+	expired, _ := issueTestCert(t, &x509.Certificate{
+		Subject:    pkix.Name{CommonName: "example.com"},
+		DNSNames:   []string{"example.com"},
+		NotBefore:  time.Now().Add(-48 * time.Hour),
+		NotAfter:   time.Now().Add(-24 * time.Hour),
+		OCSPServer: []string{"http://ocsp.example.com"},
+	}, ca, caKey)
+	otherCA, otherKey := newTestCA(t, "Untrusted CA")
+	untrusted := newTestLeaf(t, otherCA, otherKey, "example.com")
+
+	tests := []struct {
+		name          string
+		leaf          *x509.Certificate
+		dnsName       string
+		opts          ValidationOptions
+		expectedValid bool
+		expectedCodes []string
+	}{
+		{
+			name:          "valid certificate",
+			leaf:          leaf,
+			dnsName:       "www.example.com",
+			opts:          ValidationOptions{Roots: roots},
+			expectedValid: true,
+		},
+		{
+			name:          "hostname mismatch",
+			leaf:          leaf,
+			dnsName:       "other.example.org",
+			opts:          ValidationOptions{Roots: roots},
+			expectedCodes: []string{CodeHostnameMismatch},
+		},
+		{
+			name:          "hostname check skipped",
+			leaf:          leaf,
+			dnsName:       "other.example.org",
+			opts:          ValidationOptions{Roots: roots, SkipHostname: true},
+			expectedValid: true,
+		},
+		{
+			name:          "expired certificate",
+			leaf:          expired,
+			dnsName:       "example.com",
+			opts:          ValidationOptions{Roots: roots},
+			expectedCodes: []string{CodeExpired},
+		},
+		{
+			name:          "not yet valid at the given time",
+			leaf:          leaf,
+			dnsName:       "example.com",
+			opts:          ValidationOptions{Roots: roots, CurrentTime: leaf.NotBefore.Add(-time.Hour)},
+			expectedCodes: []string{CodeNotYetValid},
+		},
+		{
+			name:          "unknown authority",
+			leaf:          untrusted,
+			dnsName:       "example.com",
+			opts:          ValidationOptions{Roots: roots},
+			expectedCodes: []string{CodeUnknownAuthority},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, errs := ValidateCertificate(tt.leaf, []*x509.Certificate{tt.leaf}, tt.dnsName, tt.opts)
+			if valid != tt.expectedValid {
+				t.Errorf("expected valid %t, got %t (%v)", tt.expectedValid, valid, errs)
+			}
+			codes := validationCodes(errs)
+			if len(codes) != len(tt.expectedCodes) {
+				t.Fatalf("expected codes %v, got %v", tt.expectedCodes, codes)
+			}
+			for i := range codes {
+				if codes[i] != tt.expectedCodes[i] {
+					t.Errorf("expected codes %v, got %v", tt.expectedCodes, codes)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRedundantSANs(t *testing.T) {
+	tests := []struct {
+		name          string
+		sans          []string
+		expectedCount int
+	}{
+		{name: "distinct SANs", sans: []string{"example.com", "www.example.com"}},
+		{name: "duplicate SAN", sans: []string{"example.com", "EXAMPLE.com"}, expectedCount: 1},
+		{name: "wildcard covers name", sans: []string{"*.example.com", "www.example.com"}, expectedCount: 1},
+		{name: "wildcard does not cover apex", sans: []string{"*.example.com", "example.com"}},
+		{name: "wildcard does not cover deeper names", sans: []string{"*.example.com", "a.b.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := checkRedundantSANs(tt.sans)
+			if len(errs) != tt.expectedCount {
+				t.Fatalf("expected %d notes, got %v", tt.expectedCount, errs)
+			}
+			for _, e := range errs {
+				if e.Code != CodeRedundantSAN || !e.Warning {
+					t.Errorf("expected a %s warning, got %+v", CodeRedundantSAN, e)
+				}
+			}
+		})
+	}
+}
+
+func TestRedundantSANIsNonFatal(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leaf := newTestLeaf(t, ca, caKey, "*.example.com", "www.example.com")
+
+	valid, errs := ValidateCertificate(leaf, []*x509.Certificate{leaf}, "www.example.com", ValidationOptions{Roots: roots})
+	if !valid {
+		t.Errorf("expected a redundant SAN not to invalidate the certificate, got %v", errs)
+	}
+	if codes := validationCodes(errs); len(codes) != 1 || codes[0] != CodeRedundantSAN {
+		t.Errorf("expected a single %s note, got %v", CodeRedundantSAN, codes)
+	}
+}
+
+func TestLifetimeRemainingPct(t *testing.T) {
+	notBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(100 * time.Hour)}
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		now      time.Time
+		expected float64
+	}{
+		{name: "at issuance", cert: cert, now: notBefore, expected: 100},
+		{name: "a quarter used", cert: cert, now: notBefore.Add(25 * time.Hour), expected: 75},
+		{name: "at expiry", cert: cert, now: cert.NotAfter, expected: 0},
+		{name: "not yet valid", cert: cert, now: notBefore.Add(-time.Hour), expected: 100},
+		{name: "expired", cert: cert, now: cert.NotAfter.Add(time.Hour), expected: 0},
+		{name: "empty validity period", cert: &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore}, now: notBefore, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if pct := lifetimeRemainingPct(tt.cert, tt.now); pct != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, pct)
+			}
+		})
+	}
+}
+
+func TestValidatePopulatesLifetimeRemaining(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leaf := newTestLeaf(t, ca, caKey, "example.com")
+
+	cd := &CertDetails{CertChain: []*x509.Certificate{leaf}}
+	cd.validate("example.com", ValidationOptions{Roots: roots})
+	if cd.LifetimeRemainingPct <= 0 || cd.LifetimeRemainingPct > 100 {
+		t.Errorf("expected a remaining lifetime in (0,100], got %v", cd.LifetimeRemainingPct)
+	}
+}
+
+func TestValidatePopulatesDaysUntilExpiry(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test Root CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leaf := newTestLeaf(t, ca, caKey, "example.com")
+
+	tests := []struct {
+		name            string
+		now             time.Time
+		expectedDays    int
+		expectedExpired bool
+	}{
+		{"expires in the future", leaf.NotAfter.Add(-30*24*time.Hour - time.Hour), 30, false},
+		{"expires today", leaf.NotAfter.Add(-time.Hour), 0, false},
+		{"expired", leaf.NotAfter.Add(time.Hour), -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &CertDetails{CertChain: []*x509.Certificate{leaf}}
+			cd.validate("example.com", ValidationOptions{Roots: roots, CurrentTime: tt.now})
+			if cd.DaysUntilExpiry != tt.expectedDays {
+				t.Errorf("expected %d days until expiry, got %d", tt.expectedDays, cd.DaysUntilExpiry)
+			}
+			if cd.Expired != tt.expectedExpired {
+				t.Errorf("expected expired to be %v, got %v", tt.expectedExpired, cd.Expired)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MaxScanDomains is the most domains a single POST /scan may ask for.
+const MaxScanDomains = 1000
+
+// MaxScanConcurrency is the most concurrent connections a POST /scan may ask
+// for.
+const MaxScanConcurrency = 100
+
+// BatchScraper scrapes many targets at once, as scraper.Scanner does.
+type BatchScraper interface {
+	Scraper
+	ScrapeDomainContext(ctx context.Context, domain string) (*scraper.CertDetails, error)
+	RefreshContext(ctx context.Context, domain string) (*scraper.CertDetails, error)
+	ScrapeTLSContext(ctx context.Context, websites []string) ([]*scraper.CertDetails, error)
+}
+
+// ScannerFactory returns a scanner that connects to port with at most
+// concurrency connections at once. Either is 0 when the request didn't set
+// it, for the factory to choose its default.
+type ScannerFactory func(port, concurrency int) BatchScraper
+
+// scannerKey identifies the scanner that serves requests for a port and
+// concurrency.
+type scannerKey struct {
+	port        int
+	concurrency int
+}
+
+// scanRequest is the body of a POST /scan.
+type scanRequest struct {
+	Domains     []string `json:"domains"`
+	Port        int      `json:"port"`
+	Concurrency int      `json:"concurrency"`
+}
+
+// requestError is the body of a response to a request that was refused.
+type requestError struct {
+	Error string `json:"error"`
+}
+
+// ScanHandler serves scrapes over HTTP on /scan, for running tls-scrape as a
+// long-lived service:
+//
+//   - POST /scan with {"domains": [...], "port": 443, "concurrency": 10}
+//     scrapes the domains and returns a JSON array with the details of each,
+//     followed by a {"domain", "error"} object for each that failed. Port
+//     and concurrency are optional.
+//   - GET /scan?host=example.com, with an optional port, scrapes a single
+//     host and returns its details, or its error with status 502. With
+//     refresh=true, a cached result for the host is ignored and replaced.
+//
+// Invalid requests get status 400 and an {"error"} object.
+type ScanHandler struct {
+	newScanner ScannerFactory
+
+	mu       sync.Mutex
+	scanners map[scannerKey]BatchScraper
+}
+
+// NewScanHandler returns a ScanHandler that scrapes with the scanners made by
+// newScanner. Requests for the same port and concurrency share a scanner, so
+// that its cache, rate limit and circuit breaker apply across requests.
+func NewScanHandler(newScanner ScannerFactory) *ScanHandler {
+	return &ScanHandler{newScanner: newScanner, scanners: make(map[scannerKey]BatchScraper)}
+}
+
+// scanner returns the scanner for port and concurrency, making it on first
+// use.
+func (h *ScanHandler) scanner(port, concurrency int) BatchScraper {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := scannerKey{port: port, concurrency: concurrency}
+	scanner, ok := h.scanners[key]
+	if !ok {
+		scanner = h.newScanner(port, concurrency)
+		h.scanners[key] = scanner
+	}
+	return scanner
+}
+
+func (h *ScanHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.scanOne(w, r)
+	case http.MethodPost:
+		h.scanMany(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, requestError{Error: "method not allowed"})
+	}
+}
+
+// scanOne serves GET /scan.
+func (h *ScanHandler) scanOne(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		writeJSON(w, http.StatusBadRequest, requestError{Error: "missing host"})
+		return
+	}
+	port := 0
+	if value := r.URL.Query().Get("port"); value != "" {
+		var err error
+		if port, err = strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+			writeJSON(w, http.StatusBadRequest, requestError{Error: fmt.Sprintf("invalid port %q", value)})
+			return
+		}
+	}
+
+	refresh := false
+	if value := r.URL.Query().Get("refresh"); value != "" {
+		var err error
+		if refresh, err = strconv.ParseBool(value); err != nil {
+			writeJSON(w, http.StatusBadRequest, requestError{Error: fmt.Sprintf("invalid refresh %q", value)})
+			return
+		}
+	}
+
+	scanner := h.scanner(port, 0)
+	scrape := scanner.ScrapeDomainContext
+	if refresh {
+		scrape = scanner.RefreshContext
+	}
+	details, err := scrape(r.Context(), host)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, targetError{Domain: host, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+// scanMany serves POST /scan.
+func (h *ScanHandler) scanMany(w http.ResponseWriter, r *http.Request) {
+	var req scanRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, requestError{Error: fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+	if err := req.check(); err != nil {
+		writeJSON(w, http.StatusBadRequest, requestError{Error: err.Error()})
+		return
+	}
+
+	details, err := h.scanner(req.Port, req.Concurrency).ScrapeTLSContext(r.Context(), req.Domains)
+	results := make([]interface{}, 0, len(req.Domains))
+	for _, detail := range details {
+		results = append(results, detail)
+	}
+	var multiErr *scraper.MultiError
+	if errors.As(err, &multiErr) {
+		domains := make([]string, 0, len(multiErr.Errors))
+		for domain := range multiErr.Errors {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+		for _, domain := range domains {
+			results = append(results, targetError{Domain: domain, Error: multiErr.Errors[domain].Error()})
+		}
+	} else if err != nil {
+		writeJSON(w, http.StatusInternalServerError, requestError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// check reports what is wrong with a POST /scan request, if anything.
+func (req scanRequest) check() error {
+	if len(req.Domains) == 0 {
+		return errors.New("no domains given")
+	}
+	if len(req.Domains) > MaxScanDomains {
+		return fmt.Errorf("%d domains given, at most %d are allowed", len(req.Domains), MaxScanDomains)
+	}
+	for _, domain := range req.Domains {
+		if domain == "" {
+			return errors.New("empty domain")
+		}
+	}
+	if req.Port < 0 || req.Port > 65535 {
+		return fmt.Errorf("invalid port %d", req.Port)
+	}
+	if req.Concurrency < 0 || req.Concurrency > MaxScanConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", MaxScanConcurrency)
+	}
+	return nil
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
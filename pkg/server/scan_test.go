@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeBatchScraper scrapes with a fakeScraper and records the port and
+// concurrency it was made with, and the domains it refreshed.
+type fakeBatchScraper struct {
+	fakeScraper
+	port        int
+	concurrency int
+	refreshed   []string
+}
+
+func (f *fakeBatchScraper) ScrapeDomainContext(ctx context.Context, domain string) (*scraper.CertDetails, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.ScrapeDomain(domain)
+}
+
+func (f *fakeBatchScraper) RefreshContext(ctx context.Context, domain string) (*scraper.CertDetails, error) {
+	f.refreshed = append(f.refreshed, domain)
+	return f.ScrapeDomainContext(ctx, domain)
+}
+
+func (f *fakeBatchScraper) ScrapeTLSContext(ctx context.Context, websites []string) ([]*scraper.CertDetails, error) {
+	var results []*scraper.CertDetails
+	errs := make(map[string]error)
+	for _, website := range websites {
+		details, err := f.ScrapeDomain(website)
+		if err != nil {
+			errs[website] = err
+			continue
+		}
+		results = append(results, details)
+	}
+	if len(errs) > 0 {
+		return results, &scraper.MultiError{Errors: errs}
+	}
+	return results, nil
+}
+
+func TestScanHandler(t *testing.T) {
+	handler := NewScanHandler(func(port, concurrency int) BatchScraper {
+		return &fakeBatchScraper{port: port, concurrency: concurrency}
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		status   int
+		expected string
+	}{
+		{"get", http.MethodGet, "/scan?host=example.com", "", http.StatusOK, `{"domain":"example.com"`},
+		{"get failure", http.MethodGet, "/scan?host=gone.invalid", "", http.StatusBadGateway, `{"domain":"gone.invalid","error":"no such host"}`},
+		{"get without host", http.MethodGet, "/scan", "", http.StatusBadRequest, `{"error":"missing host"}`},
+		{"get bad port", http.MethodGet, "/scan?host=example.com&port=0", "", http.StatusBadRequest, `{"error":"invalid port \"0\""}`},
+		{"get refresh", http.MethodGet, "/scan?host=example.com&refresh=true", "", http.StatusOK, `{"domain":"example.com"`},
+		{"get bad refresh", http.MethodGet, "/scan?host=example.com&refresh=maybe", "", http.StatusBadRequest, `{"error":"invalid refresh \"maybe\""}`},
+		{"post", http.MethodPost, "/scan", `{"domains":["b.example.com","gone.invalid","a.example.com"],"port":8443,"concurrency":2}`, http.StatusOK, `[{"domain":"b.example.com"`},
+		{"post without domains", http.MethodPost, "/scan", `{"domains":[]}`, http.StatusBadRequest, `{"error":"no domains given"}`},
+		{"post bad json", http.MethodPost, "/scan", `{"domains":`, http.StatusBadRequest, `{"error":"invalid request body`},
+		{"post unknown field", http.MethodPost, "/scan", `{"hosts":["example.com"]}`, http.StatusBadRequest, `{"error":"invalid request body`},
+		{"post bad port", http.MethodPost, "/scan", `{"domains":["example.com"],"port":70000}`, http.StatusBadRequest, `{"error":"invalid port 70000"}`},
+		{"post bad concurrency", http.MethodPost, "/scan", `{"domains":["example.com"],"concurrency":-1}`, http.StatusBadRequest, `{"error":"concurrency must be between 1 and 100"}`},
+		{"delete", http.MethodDelete, "/scan", "", http.StatusMethodNotAllowed, `{"error":"method not allowed"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected a JSON content type, got %q", got)
+			}
+			if !strings.HasPrefix(rec.Body.String(), tt.expected) {
+				t.Errorf("expected a body starting with %s, got %s", tt.expected, rec.Body)
+			}
+		})
+	}
+}
+
+func TestScanHandlerPost(t *testing.T) {
+	var last *fakeBatchScraper
+	handler := NewScanHandler(func(port, concurrency int) BatchScraper {
+		last = &fakeBatchScraper{port: port, concurrency: concurrency}
+		return last
+	})
+
+	body := `{"domains":["a.example.com","z.invalid","b.example.com","c.invalid"],"port":8443,"concurrency":2}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if last.port != 8443 || last.concurrency != 2 {
+		t.Errorf("expected a scanner for port 8443 with concurrency 2, got port %d with concurrency %d", last.port, last.concurrency)
+	}
+
+	var results []struct {
+		Domain string `json:"domain"`
+		Valid  bool   `json:"valid"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("expected a JSON array, got %s: %v", rec.Body, err)
+	}
+	var got []string
+	for _, result := range results {
+		if result.Error != "" {
+			got = append(got, result.Domain+": "+result.Error)
+		} else if result.Valid {
+			got = append(got, result.Domain)
+		}
+	}
+	// Results come first, then failures sorted by domain.
+	expected := "a.example.com,b.example.com,c.invalid: no such host,z.invalid: no such host"
+	if strings.Join(got, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(got, ","))
+	}
+}
+
+func TestScanHandlerSharesScanners(t *testing.T) {
+	made := make(map[int]*fakeBatchScraper)
+	handler := NewScanHandler(func(port, concurrency int) BatchScraper {
+		if made[port] != nil {
+			t.Errorf("expected one scanner for port %d, made another", port)
+		}
+		made[port] = &fakeBatchScraper{port: port, concurrency: concurrency}
+		return made[port]
+	})
+
+	for _, target := range []string{
+		"/scan?host=example.com",
+		"/scan?host=example.com&refresh=1",
+		"/scan?host=example.org",
+		"/scan?host=example.com&port=8443",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d: %s", target, rec.Code, rec.Body)
+		}
+	}
+	if len(made) != 2 {
+		t.Errorf("expected a scanner for each of 2 ports, got %d", len(made))
+	}
+	if got := made[0].refreshed; len(got) != 1 || got[0] != "example.com" {
+		t.Errorf("expected example.com to be refreshed, got %v", got)
+	}
+}
+
+func TestScanHandlerGetContext(t *testing.T) {
+	handler := NewScanHandler(func(port, concurrency int) BatchScraper {
+		return &fakeBatchScraper{}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scan?host=example.com", nil).WithContext(ctx))
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), context.Canceled.Error()) {
+		t.Errorf("expected the request's cancellation to end the scrape, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,88 @@
+// Package server runs the HTTP endpoints exposed by tls-scrape, shutting down
+// gracefully so that in-flight requests can complete, and the target listener
+// used to feed targets to tls-scrape over a socket.
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultGracePeriod is how long in-flight requests are given to complete
+// once shutdown starts, when Server.GracePeriod is not set.
+const DefaultGracePeriod = 30 * time.Second
+
+// Server is an HTTP server that drains in-flight requests on shutdown.
+type Server struct {
+	// GracePeriod bounds how long in-flight requests may take to finish once
+	// shutdown starts. Requests still running afterwards have their contexts
+	// cancelled. DefaultGracePeriod is used when zero.
+	GracePeriod time.Duration
+
+	httpServer *http.Server
+}
+
+// New returns a Server serving handler on addr.
+func New(addr string, handler http.Handler) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// ListenAndServe listens on the server's address and serves until ctx is
+// cancelled. See Serve.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is cancelled. It then stops
+// accepting new connections and waits up to the grace period for in-flight
+// requests to finish, after which their contexts are cancelled and the
+// remaining connections closed.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	s.httpServer.BaseContext = func(net.Listener) context.Context {
+		return requestCtx
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.httpServer.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	grace := s.GracePeriod
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	err := s.httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		cancelRequests()
+		_ = s.httpServer.Close()
+	}
+
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Scraper scrapes a single target, as scraper.Scanner does.
+type Scraper interface {
+	ScrapeDomain(domain string) (*scraper.CertDetails, error)
+}
+
+// targetError is written in place of the result for a target that could not
+// be scraped.
+type targetError struct {
+	Domain string `json:"domain"`
+	Error  string `json:"error"`
+}
+
+// TargetServer scrapes targets sent over a stream connection, such as a Unix
+// domain socket, so that a co-located controller can feed targets without the
+// overhead of HTTP. Clients write one target per line and read back one JSON
+// result per line, in the order the scrapes complete. Once a client closes
+// its side of the connection, the remaining results are written and the
+// connection is closed.
+type TargetServer struct {
+	scraper Scraper
+
+	// concurrency bounds the scrapes in flight for each connection.
+	concurrency int
+}
+
+// NewTargetServer returns a TargetServer that scrapes with s, running at most
+// concurrency scrapes at once for each connection.
+func NewTargetServer(s Scraper, concurrency int) *TargetServer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &TargetServer{scraper: s, concurrency: concurrency}
+}
+
+// Serve accepts connections on ln until ctx is cancelled, then closes the
+// listener and every open connection and waits for their handlers to return.
+func (s *TargetServer) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		ln.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = true
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handle scrapes the targets read from conn and writes their results back.
+func (s *TargetServer) handle(conn net.Conn) {
+	var wg sync.WaitGroup
+	var writeMu sync.Mutex
+	sem := make(chan struct{}, s.concurrency)
+	encoder := json.NewEncoder(conn)
+
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		target := strings.TrimSpace(lines.Text())
+		if target == "" {
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			details, err := s.scraper.ScrapeDomain(target)
+			<-sem
+
+			var result interface{} = details
+			if err != nil {
+				result = targetError{Domain: target, Error: err.Error()}
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			_ = encoder.Encode(result)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/scotta01/tls-scrape/pkg/scraper"
+	"net"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScraper fails for targets ending in ".invalid" and records the highest
+// number of scrapes it saw running at once.
+type fakeScraper struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (f *fakeScraper) ScrapeDomain(domain string) (*scraper.CertDetails, error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	f.mu.Lock()
+	f.running--
+	f.mu.Unlock()
+
+	if filepath.Ext(domain) == ".invalid" {
+		return nil, errors.New("no such host")
+	}
+	return &scraper.CertDetails{Domain: domain, Valid: true}, nil
+}
+
+func TestTargetServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tls-scrape.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	fake := &fakeScraper{}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- NewTargetServer(fake, 2).Serve(ctx, ln)
+	}()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	targets := []string{"a.example.com", "b.example.com", "", "c.example.com", "missing.invalid", "d.example.com"}
+	for _, target := range targets {
+		if _, err := conn.Write([]byte(target + "\n")); err != nil {
+			t.Fatalf("failed to write target: %v", err)
+		}
+	}
+	if err := conn.(*net.UnixConn).CloseWrite(); err != nil {
+		t.Fatalf("failed to close the write side: %v", err)
+	}
+
+	var domains []string
+	var failed []string
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		var result struct {
+			Domain string `json:"domain"`
+			Valid  bool   `json:"valid"`
+			Error  string `json:"error"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode %s: %v", lines.Bytes(), err)
+		}
+		if result.Error != "" {
+			failed = append(failed, result.Domain)
+			continue
+		}
+		domains = append(domains, result.Domain)
+	}
+
+	sort.Strings(domains)
+	if len(domains) != 4 || domains[0] != "a.example.com" || domains[3] != "d.example.com" {
+		t.Errorf("expected results for the four valid targets, got %v", domains)
+	}
+	if len(failed) != 1 || failed[0] != "missing.invalid" {
+		t.Errorf("expected an error for missing.invalid, got %v", failed)
+	}
+	if fake.peak > 2 {
+		t.Errorf("expected at most 2 concurrent scrapes, got %d", fake.peak)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown, got: %v", err)
+	}
+}